@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+)
+
+var errRequestFailed = errors.New("admin request failed")
+
+// adminClient calls the admin API of a running server, the server owns the storage connections
+// and the state maintenance commands work on.
+type adminClient struct {
+	endpoint string
+	token    string
+	http     *http.Client
+}
+
+func newAdminClient(endpoint, token string) *adminClient {
+	return &adminClient{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		token:    token,
+		// Jobs run within the request, so there is no timeout, Ctrl+C cancels them
+		http: &http.Client{}, //nolint:exhaustruct
+	}
+}
+
+// call sends req as JSON, if it is not nil, and decodes the JSON response into resp, if it is not nil.
+func (c *adminClient) call(ctx context.Context, method, path string, req, resp any) error {
+	var body io.Reader
+
+	if req != nil {
+		data, err := json.Marshal(req)
+
+		if err != nil {
+			return fmt.Errorf("unable to encode request: %w", err)
+		}
+
+		body = bytes.NewReader(data)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.endpoint+path, body)
+
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if req != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	res, err := c.http.Do(httpReq)
+
+	if err != nil {
+		return fmt.Errorf("unable to reach admin API: %w", err)
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var errResp response.ErrorResponse
+
+		if err := json.NewDecoder(res.Body).Decode(&errResp); err != nil {
+			return fmt.Errorf("%w: %s", errRequestFailed, res.Status)
+		}
+
+		return fmt.Errorf("%w: %s: %s (request %s)", errRequestFailed, errResp.Code, errResp.Error, errResp.RequestID)
+	}
+
+	if resp == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return fmt.Errorf("unable to decode response: %w", err)
+	}
+
+	return nil
+}
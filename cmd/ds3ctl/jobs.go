@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/admin"
+)
+
+func scrub(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("scrub", flag.ContinueOnError)
+	batch := flags.Int("batch", 0, "number of uploads loaded at once")
+
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	var report admin.ScrubResponse
+
+	err := api.call(ctx, http.MethodPost, "/admin/jobs/scrub", admin.ScrubRequest{BatchSize: int32(*batch)}, &report)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Verified %d uploads, %d parts, %d bytes\n", report.Uploads, report.Parts, report.Bytes)
+
+	for _, id := range report.CorruptUploads {
+		fmt.Fprintf(os.Stdout, "Corrupt upload %s\n", id)
+	}
+
+	if len(report.CorruptUploads) > 0 {
+		return fmt.Errorf("%w: %d uploads failed verification", errCorruptUpload, len(report.CorruptUploads))
+	}
+
+	return nil
+}
+
+func collectGarbage(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report orphaned data without deleting it")
+	grace := flags.Duration("grace", 24*time.Hour, "keep data modified within this period") //nolint:gomnd
+
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	var report admin.GCResponse
+
+	err := api.call(ctx, http.MethodPost, "/admin/jobs/gc",
+		admin.GCRequest{GracePeriod: grace.String(), DryRun: *dryRun}, &report,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Scanned %d objects on %d servers, orphaned %d parts and %d volumes, %d bytes\n",
+		report.Scanned, report.Servers, report.OrphanedParts, report.OrphanedVolumes, report.OrphanedBytes,
+	)
+
+	for _, server := range report.FailedServers {
+		fmt.Fprintf(os.Stdout, "Failed server %s\n", server)
+	}
+
+	return nil
+}
+
+func rebalance(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("rebalance", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "only report data that would be moved")
+	batch := flags.Int("batch", 0, "number of blobs loaded at once")
+
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	var report admin.RebalanceResponse
+
+	err := api.call(ctx, http.MethodPost, "/admin/jobs/rebalance",
+		admin.RebalanceRequest{BatchSize: int32(*batch), DryRun: *dryRun}, &report,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	if len(report.Servers) == 0 {
+		fmt.Fprintln(os.Stdout, "No draining servers")
+
+		return nil
+	}
+
+	fmt.Fprintf(os.Stdout, "Moved %d blobs (%d parts) and %d volumes, %d bytes off %v, %d failed\n",
+		report.MovedBlobs, report.MovedParts, report.MovedVolumes, report.MovedBytes, report.Servers, report.Failed,
+	)
+
+	return nil
+}
+
+func compactVolumes(ctx context.Context, api *adminClient, args []string) error {
+	if err := parse(flag.NewFlagSet("compact", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	var report admin.CompactResponse
+
+	if err := api.call(ctx, http.MethodPost, "/admin/jobs/compact", nil, &report); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Compacted %d volumes\n", report.Volumes)
+
+	return nil
+}
+
+func cleanDangleUploads(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	interval := flags.Duration("interval", 0, "keep cleaning with this interval, run once if zero")
+	batch := flags.Int("batch", 0, "number of uploads or part cleanups loaded at once")
+	maxAttempts := flags.Int("max-attempts", 0, "failed cleanups of a part before giving up")
+	dryRun := flags.Bool("dry-run", false, "only report what would be cleaned")
+
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	req := admin.CleanupRequest{BatchSize: int32(*batch), MaxAttempts: int32(*maxAttempts), DryRun: *dryRun}
+
+	if *interval == 0 {
+		return cleanOnce(ctx, api, req)
+	}
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	for {
+		if err := cleanOnce(ctx, api, req); err != nil {
+			fmt.Fprintln(os.Stderr, "Cleanup failed:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func cleanOnce(ctx context.Context, api *adminClient, req admin.CleanupRequest) error {
+	var report admin.CleanupResponse
+
+	if err := api.call(ctx, http.MethodPost, "/admin/jobs/cleanup", req, &report); err != nil {
+		return err
+	}
+
+	if report.Skipped {
+		fmt.Fprintln(os.Stdout, "Dangle uploads are cleaned by another instance, skipping")
+
+		return nil
+	}
+
+	fmt.Fprintf(os.Stdout, "Cleaned %d uploads, %d parts, %d volumes, %d bytes, %d parts and %d volumes failed\n",
+		report.Uploads, report.CleanedParts, report.CleanedVolumes, report.ReclaimedBytes,
+		report.FailedParts, report.FailedVolumes,
+	)
+
+	return nil
+}
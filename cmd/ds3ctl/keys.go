@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/admin"
+)
+
+func rotateKeys(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+	newMasterKey := flags.Bool("new-master-key", false, "generate a new active master key before rewrapping")
+
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	var resp admin.RotateKeysResponse
+
+	err := api.call(ctx, http.MethodPost, "/admin/keys/rotate",
+		admin.RotateKeysRequest{NewMasterKey: *newMasterKey}, &resp,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Rewrapped data keys of %d uploads with master key %s\n", resp.Rewrapped, resp.ActiveKeyID)
+
+	return nil
+}
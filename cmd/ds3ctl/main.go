@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	usage = `Usage: ds3ctl [-admin-url url] [-token token] <command> [flags] [args]
+
+Commands are run by the server at -admin-url (DS3_ADMIN_URL, ` + defaultAdminURL + ` by default),
+the admin token is taken from ADMIN_TOKEN if -token is not passed.
+
+Commands:
+  uploads list [-bucket b] [-prefix p] [-after id] [-limit n]   list uploads of all statuses
+  uploads inspect <id>                                          show upload metadata
+  uploads parts <id>                                            show where each part of the upload lives
+  uploads delete <id>                                           delete the upload with its data
+  uploads verify [-sse-c-key base64] <id>                       read the upload back and check its parts
+  servers list                                                  list storage servers and their health
+  servers add <url>                                             add a storage server to the cluster
+  servers drain <url>                                           stop placing new parts on the server
+  servers undrain <url>                                         place new parts on a drained server again
+  keys rotate [-new-master-key]                                 rewrap data keys with the active master key
+  scrub [-batch n]                                              verify every committed upload
+  gc [-dry-run] [-grace d]                                      delete data no part references
+  rebalance [-dry-run] [-batch n]                               move data off draining servers
+  compact                                                       rewrite volumes with many deleted needles
+  cleanup [-interval d] [-batch n] [-max-attempts n] [-dry-run] delete uploads not finished in MAX_UPLOAD_TIME
+`
+
+	defaultAdminURL = "http://127.0.0.1:8090"
+)
+
+var errUsage = errors.New("invalid usage")
+
+type command func(ctx context.Context, api *adminClient, args []string) error
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprint(os.Stderr, usage)
+		}
+
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	flags := flag.NewFlagSet("ds3ctl", flag.ContinueOnError)
+	adminURL := flags.String("admin-url", envOr("DS3_ADMIN_URL", defaultAdminURL), "admin API of the server")
+	token := flags.String("token", os.Getenv("ADMIN_TOKEN"), "admin token")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	args = flags.Args()
+
+	if len(args) == 0 {
+		return fmt.Errorf("%w: command is required", errUsage)
+	}
+
+	cmd, args, err := lookup(args)
+
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	return cmd(ctx, newAdminClient(*adminURL, *token), args)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return fallback
+}
+
+// lookup returns the command named by the first one or two args and the rest of args.
+func lookup(args []string) (command, []string, error) {
+	groups := map[string]map[string]command{
+		"uploads": {
+			"list":    listUploads,
+			"inspect": inspectUpload,
+			"parts":   uploadParts,
+			"delete":  deleteUpload,
+			"verify":  verifyUpload,
+		},
+		"servers": {
+			"list":    listServers,
+			"add":     addServer,
+			"drain":   drainServer,
+			"undrain": undrainServer,
+		},
+		"keys": {
+			"rotate": rotateKeys,
+		},
+	}
+	commands := map[string]command{
+		"scrub":     scrub,
+		"gc":        collectGarbage,
+		"rebalance": rebalance,
+		"compact":   compactVolumes,
+		"cleanup":   cleanDangleUploads,
+	}
+
+	if cmd, ok := commands[args[0]]; ok {
+		return cmd, args[1:], nil
+	}
+
+	group, ok := groups[args[0]]
+
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+	}
+
+	if len(args) < 2 { //nolint:gomnd
+		return nil, nil, fmt.Errorf("%w: %s subcommand is required", errUsage, args[0])
+	}
+
+	cmd, ok := group[args[1]]
+
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: unknown command %q", errUsage, args[0]+" "+args[1])
+	}
+
+	return cmd, args[2:], nil
+}
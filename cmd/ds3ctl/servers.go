@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/admin"
+)
+
+func listServers(ctx context.Context, api *adminClient, args []string) error {
+	if err := parse(flag.NewFlagSet("servers list", flag.ContinueOnError), args, 0); err != nil {
+		return err
+	}
+
+	var resp admin.ClusterStatusResponse
+
+	if err := api.call(ctx, http.MethodGet, "/admin/cluster/status", nil, &resp); err != nil {
+		return err
+	}
+
+	out := newTable()
+	fmt.Fprintln(out, "SERVER\tSTATE\tDRAINING\tFAILURE RATE\tLATENCY\tPARTS\tPART BYTES\tUSED\tCAPACITY")
+
+	for _, server := range resp.Servers {
+		used, capacity := fmt.Sprint(server.UsedBytes), fmt.Sprint(server.CapacityBytes)
+
+		if server.StatError != "" {
+			used, capacity = "unreachable", "-"
+		} else if server.CapacityBytes == 0 {
+			capacity = "unlimited"
+		}
+
+		fmt.Fprintf(out, "%s\t%s\t%t\t%.2f\t%.0fms\t%d\t%d\t%s\t%s\n",
+			server.ServerURL, server.State, server.Draining, server.FailureRate,
+			server.AvgLatencyMs, server.Parts, server.PartBytes, used, capacity,
+		)
+	}
+
+	return out.Flush() //nolint:wrapcheck
+}
+
+func addServer(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("servers add", flag.ContinueOnError)
+
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+
+	err := api.call(ctx, http.MethodPost, "/admin/servers/add", admin.ServerRequest{ServerURL: flags.Arg(0)}, nil)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Server %s is added, new parts are placed on it\n", flags.Arg(0))
+
+	return nil
+}
+
+func drainServer(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("servers drain", flag.ContinueOnError)
+
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+
+	err := api.call(ctx, http.MethodPost, "/admin/servers/drain", admin.ServerRequest{ServerURL: flags.Arg(0)}, nil)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Server %s is draining, run rebalance to move its data\n", flags.Arg(0))
+
+	return nil
+}
+
+func undrainServer(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("servers undrain", flag.ContinueOnError)
+
+	if err := parse(flags, args, 1); err != nil {
+		return err
+	}
+
+	err := api.call(ctx, http.MethodPost, "/admin/servers/undrain", admin.ServerRequest{ServerURL: flags.Arg(0)}, nil)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Server %s gets new parts\n", flags.Arg(0))
+
+	return nil
+}
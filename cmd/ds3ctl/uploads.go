@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/httpapi/admin"
+)
+
+const defaultListLimit = 100
+
+var errCorruptUpload = errors.New("upload is corrupt")
+
+func listUploads(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("uploads list", flag.ContinueOnError)
+	bucket := flags.String("bucket", "", "list only uploads of the bucket")
+	prefix := flags.String("prefix", "", "list only uploads with names starting with the prefix")
+	after := flags.String("after", "", "list uploads with IDs after the given one, used for paging")
+	limit := flags.Int("limit", defaultListLimit, "maximum number of uploads")
+
+	if err := parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	if *after != "" {
+		if _, err := uuid.Parse(*after); err != nil {
+			return fmt.Errorf("%w: invalid -after: %w", errUsage, err)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("bucket", *bucket)
+	query.Set("prefix", *prefix)
+	query.Set("after", *after)
+	query.Set("limit", strconv.Itoa(*limit))
+
+	var resp admin.ListUploadsResponse
+
+	if err := api.call(ctx, http.MethodGet, "/admin/uploads?"+query.Encode(), nil, &resp); err != nil {
+		return err
+	}
+
+	out := newTable()
+	fmt.Fprintln(out, "ID\tBUCKET\tNAME\tSIZE\tSTATUS\tLATEST\tCREATED")
+
+	for _, upload := range resp.Uploads {
+		name := upload.Name
+		if upload.IsDeleteMarker {
+			name += " (delete marker)"
+		}
+
+		fmt.Fprintf(out, "%s\t%s\t%s\t%d\t%s\t%t\t%s\n",
+			upload.ID, upload.Bucket, name, upload.Size, upload.Status, upload.IsLatest,
+			upload.CreatedAt.Format(time.RFC3339),
+		)
+	}
+
+	return out.Flush() //nolint:wrapcheck
+}
+
+func inspectUpload(ctx context.Context, api *adminClient, args []string) error {
+	resp, err := getUpload(ctx, api, flag.NewFlagSet("uploads inspect", flag.ContinueOnError), args)
+
+	if err != nil {
+		return err
+	}
+
+	upload := resp.Upload
+	encryption := upload.Encryption
+
+	if upload.MasterKeyID != "" {
+		encryption += ", master key " + upload.MasterKeyID
+	}
+
+	retainUntil := time.Time{}
+	if upload.RetainUntil != nil {
+		retainUntil = *upload.RetainUntil
+	}
+
+	out := newTable()
+	fmt.Fprintf(out, "ID:\t%s\n", upload.ID)
+	fmt.Fprintf(out, "Bucket:\t%s\n", upload.Bucket)
+	fmt.Fprintf(out, "Name:\t%s\n", upload.Name)
+	fmt.Fprintf(out, "Size:\t%d\n", upload.Size)
+	fmt.Fprintf(out, "Status:\t%s\n", upload.Status)
+	fmt.Fprintf(out, "Latest:\t%t\n", upload.IsLatest)
+	fmt.Fprintf(out, "Delete marker:\t%t\n", upload.IsDeleteMarker)
+	fmt.Fprintf(out, "Retain until:\t%s\n", formatTime(retainUntil))
+	fmt.Fprintf(out, "Legal hold:\t%t\n", upload.LegalHold)
+	fmt.Fprintf(out, "Encryption:\t%s\n", encryption)
+	fmt.Fprintf(out, "Owner:\t%s\n", upload.Owner)
+	fmt.Fprintf(out, "Created:\t%s\n", formatTime(upload.CreatedAt))
+	fmt.Fprintf(out, "Updated:\t%s\n", formatTime(upload.UpdatedAt))
+	fmt.Fprintf(out, "Parts:\t%d\n", len(resp.Parts))
+
+	return out.Flush() //nolint:wrapcheck
+}
+
+func uploadParts(ctx context.Context, api *adminClient, args []string) error {
+	resp, err := getUpload(ctx, api, flag.NewFlagSet("uploads parts", flag.ContinueOnError), args)
+
+	if err != nil {
+		return err
+	}
+
+	printParts(resp.Parts)
+
+	return nil
+}
+
+func deleteUpload(ctx context.Context, api *adminClient, args []string) error {
+	id, err := parseID(flag.NewFlagSet("uploads delete", flag.ContinueOnError), args)
+
+	if err != nil {
+		return err
+	}
+
+	if err := api.call(ctx, http.MethodDelete, "/admin/uploads/"+id.String(), nil, nil); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stdout, "Upload %s deleted\n", id)
+
+	return nil
+}
+
+func verifyUpload(ctx context.Context, api *adminClient, args []string) error {
+	flags := flag.NewFlagSet("uploads verify", flag.ContinueOnError)
+	customerKey := flags.String("sse-c-key", "", "base64 encoded customer key of an SSE-C upload, "+
+		"without it parts are only checked to be readable")
+
+	id, err := parseID(flags, args)
+
+	if err != nil {
+		return err
+	}
+
+	var report admin.VerifyResponse
+
+	err = api.call(ctx, http.MethodPost, "/admin/uploads/"+id.String()+"/verify",
+		admin.VerifyRequest{CustomerKey: *customerKey}, &report,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	out := newTable()
+	fmt.Fprintln(out, "PART\tSERVER\tERROR")
+
+	for _, failure := range report.Failures {
+		fmt.Fprintf(out, "%d\t%s\t%s\n", failure.Number, failure.ServerURL, failure.Error)
+	}
+
+	if err := out.Flush(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(os.Stdout, "Verified %d of %d parts, %d bytes\n",
+		report.Parts-len(report.Failures), report.Parts, report.Bytes,
+	)
+
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%w: %d parts failed verification", errCorruptUpload, len(report.Failures))
+	}
+
+	return nil
+}
+
+func getUpload(ctx context.Context, api *adminClient, flags *flag.FlagSet, args []string) (admin.UploadResponse, error) {
+	id, err := parseID(flags, args)
+
+	if err != nil {
+		return admin.UploadResponse{}, err
+	}
+
+	var resp admin.UploadResponse
+
+	err = api.call(ctx, http.MethodGet, "/admin/uploads/"+id.String(), nil, &resp)
+
+	return resp, err
+}
+
+func printParts(parts []admin.Part) {
+	out := newTable()
+	fmt.Fprintln(out, "NUMBER\tSERVER\tSIZE\tSTORED\tCODEC\tSTATUS\tLOCATION")
+
+	for _, part := range parts {
+		location := "blob " + part.BlobID
+		if part.VolumeID != "" {
+			location = fmt.Sprintf("volume %s @%d+%d", part.VolumeID, part.VolumeOffset, part.VolumeLength)
+		}
+
+		fmt.Fprintf(out, "%d\t%s\t%d\t%d\t%s\t%s\t%s\n",
+			part.Number, part.ServerURL, part.Size, part.CompressedSize, part.Codec, part.Status, location,
+		)
+	}
+
+	_ = out.Flush()
+}
+
+// parse parses flags and checks that exactly n positional args are left.
+func parse(flags *flag.FlagSet, args []string, n int) error {
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if flags.NArg() != n {
+		return fmt.Errorf("%w: %s expects %d arguments, got %d", errUsage, flags.Name(), n, flags.NArg())
+	}
+
+	return nil
+}
+
+func parseID(flags *flag.FlagSet, args []string) (uuid.UUID, error) {
+	if err := parse(flags, args, 1); err != nil {
+		return uuid.Nil, err
+	}
+
+	id, err := uuid.Parse(flags.Arg(0))
+
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid upload ID: %w", errUsage, err)
+	}
+
+	return id, nil
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:gomnd
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/pressly/goose/v3"
+	"github.com/quolpr/distributeds3/internal/config"
+	"github.com/quolpr/distributeds3/postgresql"
+)
+
+var errUnknownCommand = errors.New("unknown command, expected up, down, status or redo")
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: migrate up|down|status|redo")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	cfg, err := config.Load()
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	db, err := sql.Open("pgx", cfg.DBURL)
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	defer db.Close()
+
+	migrator, err := postgresql.NewMigrator(db)
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if err := run(ctx, migrator, flag.Arg(0), slog.Default()); err != nil {
+		panic(err.Error())
+	}
+}
+
+func run(ctx context.Context, migrator *goose.Provider, command string, logger *slog.Logger) error {
+	switch command {
+	case "up":
+		results, err := migrator.Up(ctx)
+		logResults(logger, results...)
+
+		return err //nolint:wrapcheck
+	case "down":
+		result, err := migrator.Down(ctx)
+		logResults(logger, result)
+
+		return err //nolint:wrapcheck
+	case "redo":
+		result, err := migrator.Down(ctx)
+		logResults(logger, result)
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		result, err = migrator.ApplyVersion(ctx, result.Source.Version, true)
+		logResults(logger, result)
+
+		return err //nolint:wrapcheck
+	case "status":
+		statuses, err := migrator.Status(ctx)
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		for _, status := range statuses {
+			logger.Info("Migration",
+				"version", status.Source.Version,
+				"file", status.Source.Path,
+				"state", status.State,
+				"applied_at", status.AppliedAt,
+			)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: %q", errUnknownCommand, command)
+	}
+}
+
+func logResults(logger *slog.Logger, results ...*goose.MigrationResult) {
+	for _, result := range results {
+		if result == nil || result.Error != nil {
+			continue
+		}
+
+		logger.Info("Migration applied",
+			"version", result.Source.Version,
+			"file", result.Source.Path,
+			"direction", result.Direction,
+			"duration", result.Duration,
+		)
+	}
+
+	if len(results) == 0 {
+		logger.Info("No migrations to apply")
+	}
+}
@@ -1,173 +1,91 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
-	"log"
-	"mime/multipart"
-	"net/http"
 	"os"
-	"path/filepath"
-	"strconv"
-)
-
-type UploadResponse struct {
-	UploadID string `json:"upload_id"`
-}
-
-func main() { //nolint:funlen
-	filePath := "./test-file.txt"
-
-	ctx := context.Background()
-	url := "http://localhost:8080/uploads"
-	method := "POST"
-
-	fi, err := os.Stat(filePath)
-	if err != nil {
-		log.Println(err.Error())
+	"os/signal"
+	"syscall"
 
-		return
-	}
-
-	payload := &bytes.Buffer{}
-	writer := multipart.NewWriter(payload)
-	_ = writer.WriteField("file_size", strconv.Itoa(int(fi.Size())))
-	file, err := os.Open(filePath)
+	"github.com/quolpr/distributeds3/pkg/client"
+)
 
-	if err != nil {
-		log.Println(err.Error())
+const usage = `Usage: go run ./cmd/usage [-endpoint url] [-quiet] <command> [flags] [args]
 
-		return
-	}
+Commands:
+  put [-p n] <file>... <bucket/key>                      upload files, a key ending with / is a prefix
+                                                         the file names are appended to
+  get [-version id] [-range a-b] [-resume] <bucket/key> [file]
+                                                         download an object, -resume continues a partial file
+  ls <bucket>[/prefix]                                   list latest versions of objects
+  rm [-version id] <bucket/key>...                       delete objects or a version of an object
+  stat [-version id] <bucket/key>                        show size, version and ETag of an object
+  sync [-p n] [-delete] [-dry-run] <dir> <bucket>[/prefix]
+                                                         upload new and changed files of the directory
 
-	fileContent, err := io.ReadAll(file)
-	if err != nil {
-		log.Println(err.Error())
+Flags:
+`
 
-		return
-	}
+const defaultEndpoint = "http://localhost:8080"
 
-	_, err = file.Seek(0, io.SeekStart)
-	if err != nil {
-		log.Println(err.Error())
+var errUsage = errors.New("invalid usage")
 
-		return
-	}
+// options are global flags passed to every command.
+type options struct {
+	api   *client.Client
+	quiet bool
+}
 
-	defer func() {
-		err := file.Close()
+type command func(ctx context.Context, opts options, args []string) error
 
-		if err != nil {
-			log.Println(err.Error())
+func main() {
+	if err := run(); err != nil {
+		if errors.Is(err, errUsage) {
+			flag.Usage()
 		}
-	}()
-
-	part2, err := writer.CreateFormFile("file", filepath.Base("./test-file.txt"))
-
-	if err != nil {
-		log.Println(err.Error())
-
-		return
-	}
-
-	_, err = io.Copy(part2, file)
-	if err != nil {
-		log.Println(err.Error())
 
-		return
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
+}
 
-	err = writer.Close()
-	if err != nil {
-		log.Println(err.Error())
-
-		return
-	}
-
-	client := &http.Client{} //nolint:exhaustruct
-	req, err := http.NewRequestWithContext(ctx, method, url, payload)
-
-	if err != nil {
-		log.Println(err.Error())
-
-		return
+func run() error {
+	endpoint := os.Getenv("DS3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = defaultEndpoint
 	}
 
-	req.Header.Set("Content-Type", writer.FormDataContentType())
-
-	log.Println("Uploading...")
-
-	res, err := client.Do(req)
-	if err != nil {
-		log.Println(err.Error())
-
-		return
+	flag.StringVar(&endpoint, "endpoint", endpoint, "server URL, DS3_ENDPOINT env var by default")
+	quiet := flag.Bool("quiet", false, "don't render progress bars")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
 	}
-
-	defer func() {
-		err := res.Body.Close()
-
-		if err != nil {
-			log.Println(err.Error())
-		}
-	}()
-
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		log.Println(err.Error())
-
-		return
-	}
-
-	log.Println("Got response from server: " + string(body))
-
-	response := new(UploadResponse)
-	err = json.Unmarshal(body, &response)
-
-	if err != nil {
-		log.Println(err.Error())
-
-		return
+	flag.Parse()
+
+	commands := map[string]command{
+		"put":  put,
+		"get":  get,
+		"ls":   list,
+		"rm":   remove,
+		"stat": stat,
+		"sync": syncDir,
 	}
 
-	log.Println("Getting file back...")
-
-	req, err = http.NewRequestWithContext(
-		ctx, http.MethodGet, fmt.Sprintf("http://localhost:8080/uploads/%s", response.UploadID), nil,
-	)
-
-	if err != nil {
-		log.Println(err.Error())
+	if flag.NArg() == 0 {
+		return fmt.Errorf("%w: command is required", errUsage)
 	}
 
-	res, err = client.Do(req)
-	if err != nil {
-		log.Println(err.Error())
+	cmd, ok := commands[flag.Arg(0)]
 
-		return
+	if !ok {
+		return fmt.Errorf("%w: unknown command %q", errUsage, flag.Arg(0))
 	}
 
-	defer func() {
-		err := res.Body.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-		if err != nil {
-			log.Println(err.Error())
-		}
-	}()
-
-	body, err = io.ReadAll(res.Body)
-	if err != nil {
-		log.Println(err.Error())
-
-		return
-	}
-
-	if string(fileContent) == string(body) {
-		log.Println("Got file from server. Files are equal!")
-	} else {
-		log.Println("Got file from server. Files are not equal!")
-	}
+	return cmd(ctx, options{api: client.New(endpoint, client.DefaultConfig()), quiet: *quiet}, flag.Args()[1:])
 }
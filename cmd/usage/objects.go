@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/quolpr/distributeds3/pkg/client"
+)
+
+const defaultParallelism = 4
+
+var errTransfersFailed = errors.New("transfers failed")
+
+func put(ctx context.Context, opts options, args []string) error {
+	flags := flag.NewFlagSet("put", flag.ContinueOnError)
+	parallelism := flags.Int("p", defaultParallelism, "number of files uploaded at once")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if flags.NArg() < 2 { //nolint:gomnd
+		return fmt.Errorf("%w: put expects files and a destination", errUsage)
+	}
+
+	files := flags.Args()[:flags.NArg()-1]
+	bucket, key, err := parseObjectPath(flags.Arg(flags.NArg() - 1))
+
+	if err != nil {
+		return err
+	}
+
+	if len(files) > 1 && key != "" && !strings.HasSuffix(key, "/") {
+		return fmt.Errorf("%w: destination of several files must end with /", errUsage)
+	}
+
+	var total int64
+
+	for _, file := range files {
+		info, err := os.Stat(file)
+
+		if err != nil {
+			return fmt.Errorf("unable to stat file: %w", err)
+		}
+
+		total += info.Size()
+	}
+
+	bar := startProgress("put", total, opts.quiet)
+
+	err = parallel(*parallelism, len(files), func(i int) error {
+		objectKey := key
+		if objectKey == "" || strings.HasSuffix(objectKey, "/") {
+			objectKey += filepath.Base(files[i])
+		}
+
+		object, err := opts.api.UploadFile(ctx, bucket, objectKey, files[i], client.UploadOptions{ //nolint:exhaustruct
+			Progress: bar.Track(),
+		})
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", files[i], err)
+		}
+
+		fmt.Fprintf(os.Stdout, "Uploaded %s to %s/%s, version %s\n", files[i], bucket, object.Key, object.VersionID)
+
+		return nil
+	})
+
+	bar.Finish()
+
+	return err
+}
+
+func get(ctx context.Context, opts options, args []string) error {
+	flags := flag.NewFlagSet("get", flag.ContinueOnError)
+	versionID := flags.String("version", "", "version to download instead of the latest one")
+	byteRange := flags.String("range", "", "download only bytes from a to b inclusive, a- downloads till the end")
+	resume := flags.Bool("resume", false, "continue downloading into an existing partial file")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if flags.NArg() < 1 || flags.NArg() > 2 || (*resume && *byteRange != "") {
+		return fmt.Errorf("%w: get expects an object, an optional file and -range or -resume", errUsage)
+	}
+
+	bucket, key, err := parseObjectPath(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	file := flags.Arg(1)
+	if file == "" {
+		file = path.Base(key)
+	}
+
+	// The version is pinned, so the progress bar has the size of the downloaded one
+	object, err := opts.api.Stat(ctx, bucket, key, *versionID)
+
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if *byteRange != "" {
+		return downloadRange(ctx, opts, object, bucket, file, *byteRange)
+	}
+
+	download, total := opts.api.DownloadFile, object.Size
+
+	if *resume {
+		download = opts.api.ResumeDownload
+
+		if info, err := os.Stat(file); err == nil {
+			total -= min(info.Size(), total)
+		}
+	}
+
+	bar := startProgress("get", total, opts.quiet)
+	_, err = download(ctx, bucket, key, file, client.DownloadOptions{ //nolint:exhaustruct
+		VersionID: object.VersionID,
+		Progress:  bar.Track(),
+	})
+	bar.Finish()
+
+	if err != nil && !errors.Is(err, client.ErrChecksumMismatch) {
+		return fmt.Errorf("%w, run get -resume to continue", err)
+	}
+
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(os.Stdout, "Downloaded %s/%s to %s, version %s\n", bucket, key, file, object.VersionID)
+
+	return nil
+}
+
+// downloadRange writes the range of the object to the file. The range can't be checked against the ETag.
+func downloadRange(
+	ctx context.Context, opts options, object client.ObjectInfo, bucket, file, byteRange string,
+) error {
+	first, last, found := strings.Cut(byteRange, "-")
+	offset, err := strconv.ParseInt(first, 10, 64)
+
+	if !found || err != nil || offset < 0 {
+		return fmt.Errorf("%w: range must be a-b or a-", errUsage)
+	}
+
+	length := int64(0)
+
+	if last != "" {
+		end, err := strconv.ParseInt(last, 10, 64)
+
+		if err != nil || end < offset {
+			return fmt.Errorf("%w: range must be a-b or a-", errUsage)
+		}
+
+		length = end - offset + 1
+	}
+
+	total := max(object.Size-offset, 0)
+	if length > 0 {
+		total = min(length, total)
+	}
+
+	bar := startProgress("get", total, opts.quiet)
+	_, err = opts.api.DownloadFile(ctx, bucket, object.Key, file, client.DownloadOptions{ //nolint:exhaustruct
+		VersionID: object.VersionID,
+		Offset:    offset,
+		Length:    length,
+		Progress:  bar.Track(),
+	})
+	bar.Finish()
+
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(os.Stdout, "Downloaded bytes %s of %s/%s to %s\n", byteRange, bucket, object.Key, file)
+
+	return nil
+}
+
+func list(ctx context.Context, opts options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: ls expects a bucket with an optional prefix", errUsage)
+	}
+
+	bucket, prefix, err := parseObjectPath(args[0])
+
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:gomnd
+	count, size := 0, int64(0)
+
+	err = opts.api.ListAll(ctx, bucket, prefix, func(object client.ObjectInfo) error {
+		fmt.Fprintf(out, "%s\t%d\t%s\t%s\n",
+			object.LastModified.Format(time.RFC3339), object.Size, object.ETag, object.Key,
+		)
+
+		count++
+		size += object.Size
+
+		return nil
+	})
+
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := out.Flush(); err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(os.Stdout, "%d objects, %s\n", count, formatBytes(size))
+
+	return nil
+}
+
+func remove(ctx context.Context, opts options, args []string) error {
+	flags := flag.NewFlagSet("rm", flag.ContinueOnError)
+	versionID := flags.String("version", "", "remove the version permanently instead of putting a delete marker")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if flags.NArg() == 0 || (*versionID != "" && flags.NArg() > 1) {
+		return fmt.Errorf("%w: rm expects objects, -version is allowed for a single one", errUsage)
+	}
+
+	for _, arg := range flags.Args() {
+		bucket, key, err := parseObjectPath(arg)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := opts.api.Delete(ctx, bucket, key, *versionID); err != nil {
+			return fmt.Errorf("%s: %w", arg, err)
+		}
+
+		fmt.Fprintf(os.Stdout, "Deleted %s\n", arg)
+	}
+
+	return nil
+}
+
+func stat(ctx context.Context, opts options, args []string) error {
+	flags := flag.NewFlagSet("stat", flag.ContinueOnError)
+	versionID := flags.String("version", "", "version to show instead of the latest one")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: stat expects an object", errUsage)
+	}
+
+	bucket, key, err := parseObjectPath(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	object, err := opts.api.Stat(ctx, bucket, key, *versionID)
+
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint:gomnd
+	fmt.Fprintf(out, "Object:\t%s/%s\n", bucket, object.Key)
+	fmt.Fprintf(out, "Version:\t%s\n", object.VersionID)
+	fmt.Fprintf(out, "Size:\t%d (%s)\n", object.Size, formatBytes(object.Size))
+	fmt.Fprintf(out, "ETag:\t%s\n", object.ETag)
+	fmt.Fprintf(out, "Modified:\t%s\n", object.LastModified.Format(time.RFC3339))
+
+	return out.Flush() //nolint:wrapcheck
+}
+
+// parseObjectPath splits bucket/key, the key may be empty.
+func parseObjectPath(value string) (string, string, error) {
+	bucket, key, _ := strings.Cut(value, "/")
+
+	if bucket == "" {
+		return "", "", fmt.Errorf("%w: %q must be bucket/key", errUsage, value)
+	}
+
+	return bucket, key, nil
+}
+
+// parallel runs fn for indexes below count, at most n at once. Failures don't stop other runs,
+// they are reported to stderr as they happen.
+func parallel(n, count int, fn func(i int) error) error {
+	var (
+		wg     sync.WaitGroup
+		failed atomic.Int64
+	)
+
+	indexes := make(chan int)
+
+	for range max(n, 1) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				if err := fn(i); err != nil {
+					fmt.Fprintln(os.Stderr, "Failed:", err)
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+
+	for i := range count {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	if failed.Load() > 0 {
+		return fmt.Errorf("%w: %d of %d", errTransfersFailed, failed.Load(), count)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	progressWidth    = 30
+	progressInterval = 200 * time.Millisecond
+)
+
+// progress renders a single bar of bytes transferred by all running transfers to stderr.
+// Nothing is rendered if stderr is not a terminal or the output is quiet.
+type progress struct {
+	label string
+	total atomic.Int64
+	done  atomic.Int64
+	start time.Time
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func startProgress(label string, total int64, quiet bool) *progress {
+	p := &progress{ //nolint:exhaustruct
+		label: label,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+	}
+	p.total.Store(total)
+
+	if quiet || !isTerminal(os.Stderr) {
+		return p
+	}
+
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				p.render()
+			case <-p.stop:
+				p.render()
+				fmt.Fprintln(os.Stderr)
+
+				return
+			}
+		}
+	}()
+
+	return p
+}
+
+// Add grows the total, e.g. when the size of a transfer becomes known.
+func (p *progress) Add(n int64) {
+	p.total.Add(n)
+}
+
+// Track returns a progress callback of a single transfer. It is passed the bytes transferred so far,
+// which go back when a retry starts over.
+func (p *progress) Track() func(n int64) {
+	var last int64
+
+	return func(n int64) {
+		p.done.Add(n - last)
+		last = n
+	}
+}
+
+// Finish renders the final state and stops rendering.
+func (p *progress) Finish() {
+	close(p.stop)
+	p.wg.Wait()
+}
+
+func (p *progress) render() {
+	done, total := p.done.Load(), p.total.Load()
+	ratio := 1.0
+
+	if total > 0 {
+		ratio = min(float64(done)/float64(total), 1)
+	}
+
+	filled := int(ratio * progressWidth)
+	speed := float64(done) / max(time.Since(p.start).Seconds(), 1e-3) //nolint:gomnd
+
+	fmt.Fprintf(os.Stderr, "\r%s [%s%s] %3.0f%% %s/%s %s/s ",
+		p.label, strings.Repeat("#", filled), strings.Repeat(".", progressWidth-filled), ratio*100, //nolint:gomnd
+		formatBytes(done), formatBytes(total), formatBytes(int64(speed)),
+	)
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+
+	value, exp := float64(n)/unit, 0
+
+	for value >= unit && exp < 4 { //nolint:gomnd
+		value /= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f%ciB", value, "KMGTP"[exp])
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"github.com/quolpr/distributeds3/pkg/client"
+)
+
+type localFile struct {
+	path string
+	key  string
+	size int64
+}
+
+// syncDir uploads files of the directory that are missing under the prefix or differ from their objects.
+// Files are compared by size and MD5 with the ETag, so unchanged files are not uploaded again.
+func syncDir(ctx context.Context, opts options, args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	parallelism := flags.Int("p", defaultParallelism, "number of files checked and uploaded at once")
+	deleteExtra := flags.Bool("delete", false, "delete objects under the prefix that have no local file")
+	dryRun := flags.Bool("dry-run", false, "only print what would be uploaded and deleted")
+
+	if err := flags.Parse(args); err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if flags.NArg() != 2 { //nolint:gomnd
+		return fmt.Errorf("%w: sync expects a directory and a bucket with an optional prefix", errUsage)
+	}
+
+	bucket, prefix, err := parseObjectPath(flags.Arg(1))
+
+	if err != nil {
+		return err
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	files, err := localFiles(flags.Arg(0), prefix)
+
+	if err != nil {
+		return err
+	}
+
+	remote := make(map[string]client.ObjectInfo)
+
+	err = opts.api.ListAll(ctx, bucket, prefix, func(object client.ObjectInfo) error {
+		remote[object.Key] = object
+
+		return nil
+	})
+
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	var uploaded, unchanged, deleted atomic.Int64
+
+	bar := startProgress("sync", 0, opts.quiet || *dryRun)
+
+	err = parallel(*parallelism, len(files), func(i int) error {
+		file := files[i]
+		changed, err := fileChanged(file, remote[file.key])
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.path, err)
+		}
+
+		if !changed {
+			unchanged.Add(1)
+
+			return nil
+		}
+
+		if *dryRun {
+			fmt.Fprintf(os.Stdout, "Would upload %s to %s/%s\n", file.path, bucket, file.key)
+			uploaded.Add(1)
+
+			return nil
+		}
+
+		bar.Add(file.size)
+
+		_, err = opts.api.UploadFile(ctx, bucket, file.key, file.path, client.UploadOptions{ //nolint:exhaustruct
+			Progress: bar.Track(),
+		})
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.path, err)
+		}
+
+		uploaded.Add(1)
+
+		return nil
+	})
+
+	bar.Finish()
+
+	if *deleteExtra {
+		err = errors.Join(err, deleteMissing(ctx, opts, bucket, files, remote, *dryRun, &deleted))
+	}
+
+	fmt.Fprintf(os.Stdout, "Uploaded %d, unchanged %d, deleted %d\n", uploaded.Load(), unchanged.Load(), deleted.Load())
+
+	return err
+}
+
+// localFiles returns regular files of the directory with keys made of the prefix and their slash separated paths.
+func localFiles(dir, prefix string) ([]localFile, error) {
+	var files []localFile
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+
+		info, err := entry.Info()
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		rel, err := filepath.Rel(dir, path)
+
+		if err != nil {
+			return err //nolint:wrapcheck
+		}
+
+		files = append(files, localFile{path: path, key: prefix + filepath.ToSlash(rel), size: info.Size()})
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to list %s: %w", dir, err)
+	}
+
+	return files, nil
+}
+
+// fileChanged reports whether the file differs from the object. Zero object means there is none.
+func fileChanged(file localFile, object client.ObjectInfo) (bool, error) {
+	if object.Key == "" || object.Size != file.size || object.ETag == "" {
+		return true, nil
+	}
+
+	sum, err := client.FileMD5(file.path)
+
+	if err != nil {
+		return false, err //nolint:wrapcheck
+	}
+
+	return client.ETag(sum) != object.ETag, nil
+}
+
+func deleteMissing(
+	ctx context.Context, opts options, bucket string, files []localFile, remote map[string]client.ObjectInfo,
+	dryRun bool, deleted *atomic.Int64,
+) error {
+	for _, file := range files {
+		delete(remote, file.key)
+	}
+
+	keys := make([]string, 0, len(remote))
+	for key := range remote {
+		keys = append(keys, key)
+	}
+
+	return parallel(defaultParallelism, len(keys), func(i int) error {
+		if dryRun {
+			fmt.Fprintf(os.Stdout, "Would delete %s/%s\n", bucket, keys[i])
+		} else if _, err := opts.api.Delete(ctx, bucket, keys[i], ""); err != nil {
+			return fmt.Errorf("%s/%s: %w", bucket, keys[i], err)
+		}
+
+		deleted.Add(1)
+
+		return nil
+	})
+}
@@ -6,7 +6,12 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"time"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/requestid"
+	"github.com/quolpr/distributeds3/internal/service/kms/filekeyring"
 )
 
 type App struct {
@@ -31,42 +36,116 @@ func (app *App) Logger() *slog.Logger {
 
 func (app *App) ServeHTTP(ctx context.Context) error {
 	logger := app.ServiceProvider.Logger
-
-	port := ":8080"
+	config := app.ServiceProvider.Config
 
 	httpServer := &http.Server{ //nolint:exhaustruct
-		Addr:         port,
-		ReadTimeout:  10 * time.Second, //nolint:gomnd
-		WriteTimeout: 30 * time.Second, //nolint:gomnd
-		Handler:      newRoutes(app.ServiceProvider),
+		Addr:         config.HTTPAddr,
+		ReadTimeout:  config.HTTPReadTimeout,
+		WriteTimeout: config.HTTPWriteTimeout,
+		Handler:      requestid.Middleware(newRoutes(app.ServiceProvider)),
 	}
 
-	serverErrorCh := make(chan error)
-	go func() {
-		defer close(serverErrorCh)
+	// Uploads left in flight by the previous run must be recovered before new ones are accepted
+	report, err := app.ServiceProvider.UploadSvc.RecoverUploads(ctx)
 
-		logger.Info("Server started", "port", port)
+	if err != nil {
+		return fmt.Errorf("error while recover uploads: %w", err)
+	}
 
-		err := httpServer.ListenAndServe()
-		if err != nil && !errors.Is(err, http.ErrServerClosed) {
-			logger.Error("Server closing", "error", err)
-		}
+	logger.Info(
+		"Uploads recovered", "resumed", report.Resumed, "rolled_back", report.RolledBack, "skipped", report.Skipped,
+	)
 
-		select {
-		case serverErrorCh <- err:
-			return
-		default:
-			return
+	defer func() {
+		//nolint: contextcheck
+		if err := app.ServiceProvider.ShutdownTracing(context.Background()); err != nil {
+			logger.Error("Failed to flush spans", "error", err)
 		}
 	}()
 
+	go app.ServiceProvider.LifecycleSvc.Run(ctx)
+	go app.ServiceProvider.UploadSvc.RunRecovery(ctx)
+
+	if app.ServiceProvider.Keyring != nil {
+		// Every upload has its own data key, so encrypted parts never match stored blobs
+		logger.Warn("Server-side encryption is enabled, uploads are not deduplicated")
+
+		go reloadKeyringOnHangup(ctx, logger, app.ServiceProvider.Keyring)
+	}
+
+	servers := []*http.Server{httpServer}
+
+	if config.AdminHTTPAddr != "" {
+		servers = append(servers, &http.Server{ //nolint:exhaustruct
+			Addr:        config.AdminHTTPAddr,
+			ReadTimeout: config.HTTPReadTimeout,
+			// Admin jobs like scrub run within the request, they take as long as the data they go through
+			WriteTimeout: 0,
+			Handler:      requestid.Middleware(newAdminRoutes(app.ServiceProvider)),
+		})
+	}
+
+	serverErrorCh := make(chan error, len(servers))
+
+	for _, server := range servers {
+		go func() {
+			logger.Info("Server started", "addr", server.Addr)
+
+			err := server.ListenAndServe()
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logger.Error("Server closing", "addr", server.Addr, "error", err)
+			}
+
+			serverErrorCh <- err
+		}()
+	}
+
+	var serveErr error
+
+	// A failed listener stops the other one too, so the instance doesn't keep running half-served
 	select {
-	case err := <-serverErrorCh:
-		return err
+	case serveErr = <-serverErrorCh:
 	case <-ctx.Done():
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second*30) //nolint:gomnd
-		defer cancel()
-		//nolint: contextcheck
-		return httpServer.Shutdown(ctx) //nolint:wrapcheck
 	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+	defer cancel()
+
+	//nolint: contextcheck
+	return errors.Join(serveErr, shutdown(shutdownCtx, servers))
+}
+
+// reloadKeyringOnHangup reloads the keyring on SIGHUP, so a master key rotated by another instance becomes active.
+func reloadKeyringOnHangup(ctx context.Context, logger *slog.Logger, keyring *filekeyring.Keyring) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	defer signal.Stop(hangup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+		}
+
+		if err := keyring.Reload(); err != nil {
+			logger.Error("Failed to reload keyring", "error", err)
+
+			continue
+		}
+
+		keyID, _ := keyring.ActiveKeyID(ctx)
+		logger.Info("Keyring reloaded", "active_key_id", keyID)
+	}
+}
+
+func shutdown(ctx context.Context, servers []*http.Server) error {
+	errs := make([]error, 0, len(servers))
+
+	for _, server := range servers {
+		errs = append(errs, server.Shutdown(ctx))
+	}
+
+	return errors.Join(errs...)
 }
@@ -1,12 +1,71 @@
 package app
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/admin"
+	"github.com/quolpr/distributeds3/internal/tracing"
+)
 
 func newRoutes(serviceProvider *serviceProvider) *http.ServeMux {
 	mux := http.NewServeMux()
+	handle := instrumented(mux, serviceProvider)
+
+	handle("POST /uploads", serviceProvider.UploadHandler.HandleUpload)
+	handle("GET /uploads/{id}", serviceProvider.UploadHandler.GetUpload)
+
+	handle("PUT /objects/{bucket}/{key...}", serviceProvider.UploadHandler.PutObject)
+	handle("GET /objects/{bucket}/{key...}", serviceProvider.UploadHandler.GetObject)
+	handle("HEAD /objects/{bucket}/{key...}", serviceProvider.UploadHandler.HeadObject)
+	handle("GET /objects/{bucket}", serviceProvider.UploadHandler.ListObjects)
+	handle("DELETE /objects/{bucket}/{key...}", serviceProvider.UploadHandler.DeleteObject)
+	handle("GET /versions/{bucket}/{key...}", serviceProvider.UploadHandler.ListObjectVersions)
+	handle("PUT /retention/{bucket}/{key...}", serviceProvider.UploadHandler.PutRetention)
+	handle("PUT /legal-hold/{bucket}/{key...}", serviceProvider.UploadHandler.PutLegalHold)
+
+	handle("GET /lifecycle/{bucket}", serviceProvider.LifecycleHandler.GetConfiguration)
+	handle("PUT /lifecycle/{bucket}", serviceProvider.LifecycleHandler.PutConfiguration)
 
-	mux.HandleFunc("POST /uploads", serviceProvider.UploadHandler.HandleUpload)
-	mux.HandleFunc("GET /uploads/{id}", serviceProvider.UploadHandler.GetUpload)
+	// Probes are not instrumented, they would drown real traffic
+	mux.HandleFunc("GET /healthz", serviceProvider.HealthHandler.Healthz)
+	mux.HandleFunc("GET /readyz", serviceProvider.HealthHandler.Readyz)
 
 	return mux
 }
+
+// newAdminRoutes are served by the admin listener only, they must not be reachable by API clients.
+func newAdminRoutes(serviceProvider *serviceProvider) http.Handler {
+	mux := http.NewServeMux()
+	handle := instrumented(mux, serviceProvider)
+
+	handle("GET /admin/storage/servers", serviceProvider.AdminHandler.GetStorageServers)
+	handle("GET /admin/cluster/status", serviceProvider.AdminHandler.GetClusterStatus)
+
+	handle("GET /admin/uploads", serviceProvider.AdminHandler.ListUploads)
+	handle("GET /admin/uploads/{id}", serviceProvider.AdminHandler.GetUpload)
+	handle("DELETE /admin/uploads/{id}", serviceProvider.AdminHandler.DeleteUpload)
+	handle("POST /admin/uploads/{id}/verify", serviceProvider.AdminHandler.VerifyUpload)
+
+	handle("POST /admin/servers/add", serviceProvider.AdminHandler.AddServer)
+	handle("POST /admin/servers/drain", serviceProvider.AdminHandler.DrainServer)
+	handle("POST /admin/servers/undrain", serviceProvider.AdminHandler.UndrainServer)
+
+	handle("POST /admin/jobs/scrub", serviceProvider.AdminHandler.Scrub)
+	handle("POST /admin/jobs/gc", serviceProvider.AdminHandler.CollectGarbage)
+	handle("POST /admin/jobs/rebalance", serviceProvider.AdminHandler.Rebalance)
+	handle("POST /admin/jobs/compact", serviceProvider.AdminHandler.CompactVolumes)
+	handle("POST /admin/jobs/cleanup", serviceProvider.AdminHandler.CleanDangleUploads)
+
+	handle("POST /admin/keys/rotate", serviceProvider.AdminHandler.RotateKeys)
+
+	// Metrics expose routes, servers and traffic, so they are private as the rest of the admin API
+	mux.Handle("GET /metrics", serviceProvider.Metrics.Handler())
+
+	return admin.RequireToken(serviceProvider.Config.AdminToken, mux)
+}
+
+func instrumented(mux *http.ServeMux, serviceProvider *serviceProvider) func(string, http.HandlerFunc) {
+	return func(pattern string, handler http.HandlerFunc) {
+		mux.HandleFunc(pattern, tracing.Instrument(pattern, serviceProvider.Metrics.Instrument(pattern, handler)))
+	}
+}
@@ -8,29 +8,62 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
-	"github.com/pressly/goose/v3"
 	"github.com/quolpr/distributeds3/internal/config"
+	"github.com/quolpr/distributeds3/internal/httpapi/admin"
+	"github.com/quolpr/distributeds3/internal/httpapi/health"
+	"github.com/quolpr/distributeds3/internal/httpapi/lifecycle"
 	"github.com/quolpr/distributeds3/internal/httpapi/upload"
+	"github.com/quolpr/distributeds3/internal/metrics"
 	"github.com/quolpr/distributeds3/internal/queries/pg"
+	healthSvc "github.com/quolpr/distributeds3/internal/service/health"
+	"github.com/quolpr/distributeds3/internal/service/kms"
+	"github.com/quolpr/distributeds3/internal/service/kms/filekeyring"
+	lifecycleSvc "github.com/quolpr/distributeds3/internal/service/lifecycle"
+	lifecycleRepo "github.com/quolpr/distributeds3/internal/service/lifecycle/repo"
 	"github.com/quolpr/distributeds3/internal/service/storage"
 	"github.com/quolpr/distributeds3/internal/service/storage/repo/inmemstorage"
 	uploadSvc "github.com/quolpr/distributeds3/internal/service/upload"
 	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+	"github.com/quolpr/distributeds3/internal/tracing"
+	"github.com/quolpr/distributeds3/pkg/chunker"
+	"github.com/quolpr/distributeds3/pkg/compression"
 	"github.com/quolpr/distributeds3/pkg/transaction"
 	"github.com/quolpr/distributeds3/postgresql"
 )
 
 type serviceProvider struct {
-	Logger        *slog.Logger
-	UploadHandler *upload.Handlers
-	UploadSvc     *uploadSvc.Service
+	Config           *config.Config
+	Logger           *slog.Logger
+	UploadHandler    *upload.Handlers
+	UploadSvc        *uploadSvc.Service
+	LifecycleHandler *lifecycle.Handlers
+	LifecycleSvc     *lifecycleSvc.Service
+	AdminHandler     *admin.Handlers
+	HealthHandler    *health.Handlers
+	// Keyring is nil if server-side encryption is disabled.
+	Keyring *filekeyring.Keyring
+	Metrics *metrics.Metrics
+	// ShutdownTracing flushes recorded spans.
+	ShutdownTracing func(ctx context.Context) error
 }
 
 func NewServiceProvider(ctx context.Context) (*serviceProvider, error) {
-	config, err := config.FromEnv()
+	config, err := config.Load()
 
 	if err != nil {
-		return nil, fmt.Errorf("error while parse env config: %w", err)
+		return nil, fmt.Errorf("error while load config: %w", err)
+	}
+
+	slog.Info("Effective config", "config", config.Redacted())
+
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    tracing.Exporter(config.TracingExporter),
+		ServiceName: config.TracingServiceName,
+		SampleRatio: config.TracingSampleRatio,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("error while setup tracing: %w", err)
 	}
 
 	postgresPool, err := providePostgresql(ctx, config.DBURL)
@@ -39,34 +72,110 @@ func NewServiceProvider(ctx context.Context) (*serviceProvider, error) {
 		return nil, fmt.Errorf("error while connect to postgreSQL: %w", err)
 	}
 
+	var (
+		keyring    *filekeyring.Keyring
+		keyManager kms.KMS
+	)
+
+	if config.SSEKeyringPath != "" {
+		keyring, err = filekeyring.Open(config.SSEKeyringPath)
+
+		if err != nil {
+			return nil, fmt.Errorf("error while open SSE keyring: %w", err)
+		}
+
+		keyManager = keyring
+	}
+
+	codec, err := compression.ParseCodec(config.CompressionCodec)
+
+	if err != nil {
+		return nil, fmt.Errorf("error while parse compression codec: %w", err)
+	}
+
+	chunking := chunker.Config{
+		Algorithm: chunker.Algorithm(config.Chunker),
+		MinSize:   config.ChunkMinSize,
+		AvgSize:   config.ChunkAvgSize,
+		MaxSize:   config.ChunkMaxSize,
+	}
+
+	if err := chunking.Validate(); err != nil {
+		return nil, fmt.Errorf("error while validate chunker config: %w", err)
+	}
+
+	pipeline := uploadSvc.PipelineConfig{
+		Concurrency:  config.TransferConcurrency,
+		MemoryBudget: config.TransferMemoryBudget,
+	}
+
+	if err := pipeline.Validate(); err != nil {
+		return nil, fmt.Errorf("error while validate transfer config: %w", err)
+	}
+
+	serviceMetrics := metrics.New()
+
+	if err := serviceMetrics.Register(metrics.NewPoolCollector(postgresPool)); err != nil {
+		return nil, fmt.Errorf("error while register pool metrics: %w", err)
+	}
+
 	queries := pg.NewTxQueries(pg.New(postgresPool))
-	storageService := storage.NewService(inmemstorage.NewInmemRepo())
+	serverRepo := repo.NewServerRepo(queries)
+	storageService := storage.NewService(inmemstorage.NewInmemRepo(config.StorageServers), serverRepo, storage.Policy{
+		Timeout:    config.StorageTimeout,
+		Retries:    config.StorageRetries,
+		Backoff:    config.StorageBackoff,
+		MaxBackoff: config.StorageMaxBackoff,
+	}, storage.BreakerConfig{
+		Window:      config.BreakerWindow,
+		FailureRate: config.BreakerFailureRate,
+		SlowCall:    config.BreakerSlowCall,
+		OpenTimeout: config.BreakerOpenTimeout,
+	}, serviceMetrics)
 	partRepo := repo.NewPartRepo(queries)
 	uploadRepo := repo.NewUploadRepo(queries, partRepo)
 	uploadService := uploadSvc.NewService(
-		partRepo, uploadRepo, storageService,
-		transaction.New(postgresPool),
+		partRepo, uploadRepo, repo.NewBlobRepo(queries), repo.NewVolumeRepo(queries),
+		repo.NewCleanupRepo(queries), serverRepo, storageService,
+		transaction.New(postgresPool), keyManager, codec, chunking,
+		uploadSvc.PackingConfig{
+			Threshold:       config.PackThreshold,
+			VolumeMaxSize:   config.VolumeMaxSize,
+			CompactionRatio: config.CompactionRatio,
+			DeleteDelay:     config.VolumeDeleteDelay,
+		},
+		pipeline, uploadSvc.RecoveryConfig{
+			InstanceID: config.InstanceID,
+			StaleAfter: config.RecoveryStaleAfter,
+			Interval:   config.RecoveryInterval,
+		}, serviceMetrics, config.MaxUploadTime,
+	)
+	lifecycleService := lifecycleSvc.NewService(
+		lifecycleRepo.NewRuleRepo(queries), uploadService,
+		transaction.New(postgresPool), config.LifecycleInterval,
 	)
 
-	if err := goose.SetDialect("postgres"); err != nil {
-		return nil, fmt.Errorf("failed set goose dialect: %w", err)
+	// The provider keeps its own handle of the pool to check pending migrations on readiness probes
+	migrations, err := postgresql.NewMigrator(stdlib.OpenDBFromPool(postgresPool))
+
+	if err != nil {
+		return nil, fmt.Errorf("error while create migrator: %w", err)
 	}
 
-	conn := stdlib.OpenDBFromPool(postgresPool)
-	defer func() {
-		if err := conn.Close(); err != nil {
-			slog.Error("Failed to close connection", "err", err)
-		}
-	}()
+	// NOTE: Usually migrations are applied by cmd/migrate on deploy,
+	// auto-migration is for easier docker-compose up
+	if config.AutoMigrate {
+		results, err := migrations.Up(ctx)
 
-	goose.SetBaseFS(postgresql.EmbedMigrations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate: %w", err)
+		}
 
-	// NOTE: Usual migrations are migrating in different container on deploy
-	// But for easier docker-compose up I managed to put it here
-	if err := goose.Up(conn, "migrations"); err != nil {
-		return nil, fmt.Errorf("failed to migrate: %w", err)
+		slog.Info("Migrations applied", "count", len(results))
 	}
 
+	healthService := healthSvc.NewService(postgresPool, migrations, storageService, config.ReadyMinStorageServers)
+
 	connPgx, err := postgresPool.Acquire(ctx)
 
 	if err != nil {
@@ -80,9 +189,17 @@ func NewServiceProvider(ctx context.Context) (*serviceProvider, error) {
 	}
 
 	return &serviceProvider{
-		Logger:        slog.Default(),
-		UploadHandler: upload.NewHandlers(uploadService),
-		UploadSvc:     uploadService,
+		Config:           config,
+		Logger:           slog.Default(),
+		UploadHandler:    upload.NewHandlers(uploadService, config.MaxUploadSize),
+		UploadSvc:        uploadService,
+		LifecycleHandler: lifecycle.NewHandlers(lifecycleService),
+		LifecycleSvc:     lifecycleService,
+		AdminHandler:     admin.NewHandlers(storageService, uploadService, keyring),
+		HealthHandler:    health.NewHandlers(healthService),
+		Metrics:          serviceMetrics,
+		ShutdownTracing:  shutdownTracing,
+		Keyring:          keyring,
 	}, nil
 }
 
@@ -93,6 +210,7 @@ func providePostgresql(ctx context.Context, dbURL string) (*pgxpool.Pool, error)
 	}
 
 	databaseConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+	databaseConfig.ConnConfig.Tracer = tracing.QueryTracer{}
 
 	pool, err := pgxpool.NewWithConfig(ctx, databaseConfig)
 	if err != nil {
@@ -0,0 +1,63 @@
+package apperror
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// Kind is a class of errors, the HTTP layer maps it to a status code.
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	// KindBadRequest is a malformed request: unparsable params, headers or body
+	KindBadRequest
+	// KindInvalidInput is a well-formed request the service refuses to apply
+	KindInvalidInput
+	KindNotFound
+	KindConflict
+	KindChecksumMismatch
+	// KindUnavailable is a failure of a dependency that is likely to pass on retry
+	KindUnavailable
+	// KindRangeNotSatisfiable is a requested range outside of the object
+	KindRangeNotSatisfiable
+	// KindUnauthorized is a request without valid credentials
+	KindUnauthorized
+)
+
+// Error is a domain error with a stable code. Details are added by wrapping it as "%w: detail",
+// errors.Is matches the wrapped error with the declared one.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var ErrDatabaseUnavailable = New(KindUnavailable, "database_unavailable", "database is unavailable")
+
+// From returns the domain error of the chain. Connection failures of the database are reported
+// as ErrDatabaseUnavailable. Returns nil if err is not a domain error.
+func From(err error) *Error {
+	var appErr *Error
+
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+
+	var connectErr *pgconn.ConnectError
+
+	if errors.As(err, &connectErr) || pgconn.Timeout(err) {
+		return ErrDatabaseUnavailable
+	}
+
+	return nil
+}
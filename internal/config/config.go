@@ -1,21 +1,210 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
+	"gopkg.in/yaml.v3"
 )
 
+var ErrInvalidConfig = errors.New("invalid config")
+
 type Config struct {
-	DBURL string `envconfig:"DB_URL" required:"true"`
+	DBURL string `envconfig:"DB_URL" yaml:"db_url"`
+	// HTTPAddr is the address the API listens on.
+	HTTPAddr         string        `envconfig:"HTTP_ADDR" yaml:"http_addr" default:":8080"`
+	HTTPReadTimeout  time.Duration `envconfig:"HTTP_READ_TIMEOUT" yaml:"http_read_timeout" default:"10s"`
+	HTTPWriteTimeout time.Duration `envconfig:"HTTP_WRITE_TIMEOUT" yaml:"http_write_timeout" default:"30s"`
+	// AdminHTTPAddr is the address of the admin API, keep it private. The admin API is disabled if empty.
+	AdminHTTPAddr string `envconfig:"ADMIN_HTTP_ADDR" yaml:"admin_http_addr" default:"127.0.0.1:8090"`
+	// AdminToken is the bearer token the admin API requires. Any request is let through if empty.
+	AdminToken string `envconfig:"ADMIN_TOKEN" yaml:"admin_token"`
+	// ShutdownTimeout is how long in-flight requests are waited for on shutdown.
+	ShutdownTimeout time.Duration `envconfig:"SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout" default:"30s"`
+	// StorageServers are URLs of storage servers parts are placed on.
+	StorageServers []string `envconfig:"STORAGE_SERVERS" yaml:"storage_servers" default:"http://localhost:8080,http://localhost:8081,http://localhost:8082"` //nolint:lll
+	// MaxUploadSize is the max size of an uploaded object in bytes.
+	MaxUploadSize int64 `envconfig:"MAX_UPLOAD_SIZE" yaml:"max_upload_size" default:"10737418240"`
+	// AutoMigrate applies migrations on start. Otherwise they are applied by cmd/migrate.
+	AutoMigrate bool `envconfig:"AUTO_MIGRATE" yaml:"auto_migrate" default:"false"`
+	// MaxUploadTime is the time after which unfinished uploads are deleted.
+	MaxUploadTime time.Duration `envconfig:"MAX_UPLOAD_TIME" yaml:"max_upload_time" default:"24h"`
+	// LifecycleInterval is how often the lifecycle engine applies bucket rules.
+	LifecycleInterval time.Duration `envconfig:"LIFECYCLE_INTERVAL" yaml:"lifecycle_interval" default:"1h"`
+	// SSEKeyringPath is a master keyring file for server-side encryption. Encryption is disabled if empty.
+	// Encrypted uploads are not deduplicated, so setting it turns deduplication off.
+	SSEKeyringPath string `envconfig:"SSE_KEYRING_PATH" yaml:"sse_keyring_path"`
+	// CompressionCodec is the default codec of parts: none, gzip or zstd.
+	CompressionCodec string `envconfig:"COMPRESSION_CODEC" yaml:"compression_codec" default:"none"`
+	// Chunker splits uploads into parts: fixed or fastcdc. Fixed chunker uses ChunkAvgSize.
+	Chunker      string `envconfig:"CHUNKER" yaml:"chunker" default:"fastcdc"`
+	ChunkMinSize int    `envconfig:"CHUNK_MIN_SIZE" yaml:"chunk_min_size" default:"1048576"`
+	ChunkAvgSize int    `envconfig:"CHUNK_AVG_SIZE" yaml:"chunk_avg_size" default:"4194304"`
+	ChunkMaxSize int    `envconfig:"CHUNK_MAX_SIZE" yaml:"chunk_max_size" default:"16777216"`
+	// PackThreshold is the size below which objects are packed into volumes. Packing is disabled if it is zero.
+	PackThreshold int64 `envconfig:"PACK_THRESHOLD" yaml:"pack_threshold" default:"0"`
+	VolumeMaxSize int64 `envconfig:"VOLUME_MAX_SIZE" yaml:"volume_max_size" default:"1073741824"`
+	// CompactionRatio is the share of deleted data in a volume after which it is compacted.
+	CompactionRatio float64 `envconfig:"COMPACTION_RATIO" yaml:"compaction_ratio" default:"0.5"`
+	// VolumeDeleteDelay is the time a compacted or moved volume is kept before the cleanup deletes it,
+	// so downloads that started before the move can finish.
+	VolumeDeleteDelay time.Duration `envconfig:"VOLUME_DELETE_DELAY" yaml:"volume_delete_delay" default:"1h"`
+	// TransferConcurrency is the number of parts one upload or download transfers at the same time.
+	TransferConcurrency int `envconfig:"TRANSFER_CONCURRENCY" yaml:"transfer_concurrency" default:"4"`
+	// TransferMemoryBudget is the max size of parts buffered by one upload or download.
+	TransferMemoryBudget int64 `envconfig:"TRANSFER_MEMORY_BUDGET" yaml:"transfer_memory_budget" default:"67108864"`
+	// StorageTimeout bounds a single call to a storage server.
+	StorageTimeout time.Duration `envconfig:"STORAGE_TIMEOUT" yaml:"storage_timeout" default:"30s"`
+	// StorageRetries is the number of retries of idempotent storage calls.
+	StorageRetries    int           `envconfig:"STORAGE_RETRIES" yaml:"storage_retries" default:"3"`
+	StorageBackoff    time.Duration `envconfig:"STORAGE_BACKOFF" yaml:"storage_backoff" default:"100ms"`
+	StorageMaxBackoff time.Duration `envconfig:"STORAGE_MAX_BACKOFF" yaml:"storage_max_backoff" default:"2s"`
+	// BreakerWindow is the number of last calls to a storage server its failure rate is computed on.
+	BreakerWindow int `envconfig:"BREAKER_WINDOW" yaml:"breaker_window" default:"20"`
+	// BreakerFailureRate removes a storage server from available ones. Zero disables circuit breakers.
+	BreakerFailureRate float64 `envconfig:"BREAKER_FAILURE_RATE" yaml:"breaker_failure_rate" default:"0.5"`
+	// BreakerSlowCall is the latency after which a call is counted as failed. Zero disables it.
+	BreakerSlowCall time.Duration `envconfig:"BREAKER_SLOW_CALL" yaml:"breaker_slow_call" default:"10s"`
+	// BreakerOpenTimeout is how long a failing server is removed before it is probed.
+	BreakerOpenTimeout time.Duration `envconfig:"BREAKER_OPEN_TIMEOUT" yaml:"breaker_open_timeout" default:"30s"`
+	// InstanceID owns uploads of this API instance, they are recovered on its restart. Defaults to the hostname.
+	InstanceID string `envconfig:"INSTANCE_ID" yaml:"instance_id"`
+	// RecoveryStaleAfter is the time without progress after which uploads of other instances are recovered.
+	RecoveryStaleAfter time.Duration `envconfig:"RECOVERY_STALE_AFTER" yaml:"recovery_stale_after" default:"15m"`
+	// RecoveryInterval is how often uploads of other instances are checked.
+	RecoveryInterval time.Duration `envconfig:"RECOVERY_INTERVAL" yaml:"recovery_interval" default:"1m"`
+	// ReadyMinStorageServers is the number of healthy storage servers the instance needs to be ready.
+	ReadyMinStorageServers int `envconfig:"READY_MIN_STORAGE_SERVERS" yaml:"ready_min_storage_servers" default:"1"`
+	// TracingExporter sends spans to none, stdout or otlp. OTLP is configured by OTEL_EXPORTER_OTLP_* env vars.
+	TracingExporter    string  `envconfig:"TRACING_EXPORTER" yaml:"tracing_exporter" default:"none"`
+	TracingServiceName string  `envconfig:"TRACING_SERVICE_NAME" yaml:"tracing_service_name" default:"distributeds3"`
+	TracingSampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" yaml:"tracing_sample_ratio" default:"1"`
 }
 
-func FromEnv() (*Config, error) {
-	cfg := new(Config)
+// Load reads the config from the YAML file at CONFIG_FILE, if it is set, and env vars.
+// Env vars take precedence over the file, defaults are used for values set in neither.
+func Load() (*Config, error) {
+	env := new(Config)
 
-	if err := envconfig.Process("", cfg); err != nil {
+	if err := envconfig.Process("", env); err != nil {
 		return nil, fmt.Errorf("error while parse env config | %w", err)
 	}
 
-	return cfg, nil
+	cfg := *env
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return nil, err
+		}
+
+		overrideFromEnv(&cfg, env)
+	}
+
+	if cfg.InstanceID == "" {
+		hostname, err := os.Hostname()
+
+		if err != nil {
+			return nil, fmt.Errorf("error while get hostname | %w", err)
+		}
+
+		cfg.InstanceID = hostname
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func readFile(path string, cfg *Config) error {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return fmt.Errorf("error while open config file | %w", err)
+	}
+
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("error while parse config file %s | %w", path, err)
+	}
+
+	return nil
+}
+
+// overrideFromEnv sets fields of cfg to the values of env vars that are set.
+func overrideFromEnv(cfg, env *Config) {
+	cfgValue := reflect.ValueOf(cfg).Elem()
+	envValue := reflect.ValueOf(env).Elem()
+
+	for i := range cfgValue.NumField() {
+		if _, ok := os.LookupEnv(cfgValue.Type().Field(i).Tag.Get("envconfig")); ok {
+			cfgValue.Field(i).Set(envValue.Field(i))
+		}
+	}
+}
+
+// Validate checks values that would otherwise fail deep in the service or, worse, be silently misused.
+func (c *Config) Validate() error {
+	var errs []error
+
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidConfig}, args...)...))
+		}
+	}
+
+	check(c.DBURL != "", "DB_URL is required")
+	check(c.HTTPAddr != "", "HTTP_ADDR is required")
+	check(c.AdminHTTPAddr != c.HTTPAddr, "ADMIN_HTTP_ADDR must differ from HTTP_ADDR")
+	check(c.HTTPReadTimeout >= 0 && c.HTTPWriteTimeout >= 0, "HTTP timeouts can't be negative")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	check(c.MaxUploadSize > 0, "MAX_UPLOAD_SIZE must be positive")
+	check(c.MaxUploadTime > 0, "MAX_UPLOAD_TIME must be positive")
+	check(c.LifecycleInterval > 0, "LIFECYCLE_INTERVAL must be positive")
+	check(len(c.StorageServers) > 0, "STORAGE_SERVERS can't be empty")
+
+	for _, server := range c.StorageServers {
+		u, err := url.Parse(server)
+
+		check(err == nil && u.Scheme != "" && u.Host != "", "storage server %q is not a valid URL", server)
+	}
+
+	check(c.PackThreshold >= 0 && c.PackThreshold <= c.VolumeMaxSize, "PACK_THRESHOLD must be within VOLUME_MAX_SIZE")
+	check(c.CompactionRatio > 0 && c.CompactionRatio <= 1, "COMPACTION_RATIO must be in (0, 1]")
+	check(c.VolumeDeleteDelay >= 0, "VOLUME_DELETE_DELAY can't be negative")
+	check(c.StorageTimeout >= 0, "storage timeout can't be negative")
+	check(c.StorageRetries >= 0, "STORAGE_RETRIES can't be negative")
+	check(c.StorageBackoff <= c.StorageMaxBackoff, "STORAGE_BACKOFF can't exceed STORAGE_MAX_BACKOFF")
+	check(c.BreakerFailureRate >= 0 && c.BreakerFailureRate <= 1, "BREAKER_FAILURE_RATE must be in [0, 1]")
+	check(c.RecoveryStaleAfter > 0 && c.RecoveryInterval > 0, "recovery intervals must be positive")
+	check(c.ReadyMinStorageServers >= 0, "READY_MIN_STORAGE_SERVERS can't be negative")
+	check(c.TracingSampleRatio >= 0 && c.TracingSampleRatio <= 1, "TRACING_SAMPLE_RATIO must be in [0, 1]")
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the config that is safe to print: the database password and the admin token are masked.
+func (c *Config) Redacted() Config {
+	redacted := *c
+
+	if c.AdminToken != "" {
+		redacted.AdminToken = "xxxxx"
+	}
+
+	if u, err := url.Parse(c.DBURL); err == nil {
+		redacted.DBURL = u.Redacted()
+	}
+
+	return redacted
 }
@@ -0,0 +1,33 @@
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+)
+
+var ErrUnauthorized = apperror.New(apperror.KindUnauthorized, "unauthorized", "admin token is missing or invalid")
+
+// RequireToken lets through only requests with "Authorization: Bearer <token>" header.
+// Empty token disables the check, the admin listener is then protected only by its address.
+func RequireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		passed, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		if !found || subtle.ConstantTimeCompare([]byte(passed), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			response.Error(w, ErrUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,139 @@
+package admin
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+	"github.com/quolpr/distributeds3/internal/service/kms/filekeyring"
+	"github.com/quolpr/distributeds3/internal/service/storage"
+	"github.com/quolpr/distributeds3/internal/service/upload"
+)
+
+type Handlers struct {
+	storage *storage.Service
+	upload  *upload.Service
+	// keyring is nil if server-side encryption is disabled
+	keyring *filekeyring.Keyring
+}
+
+func NewHandlers(storage *storage.Service, upload *upload.Service, keyring *filekeyring.Keyring) *Handlers {
+	return &Handlers{
+		storage: storage,
+		upload:  upload,
+		keyring: keyring,
+	}
+}
+
+type ServerHealth struct {
+	ServerURL    string     `json:"server_url"`
+	State        string     `json:"state"`
+	FailureRate  float64    `json:"failure_rate"`
+	AvgLatencyMs float64    `json:"avg_latency_ms"`
+	Calls        int64      `json:"calls"`
+	Failures     int64      `json:"failures"`
+	OpenedAt     *time.Time `json:"opened_at,omitempty"`
+}
+
+type ServerStatus struct {
+	ServerHealth
+	// CapacityBytes is zero if the server storage is not limited
+	CapacityBytes int64  `json:"capacity_bytes"`
+	UsedBytes     int64  `json:"used_bytes"`
+	StoredParts   int    `json:"stored_parts"`
+	StoredVolumes int    `json:"stored_volumes"`
+	StatError     string `json:"stat_error,omitempty"`
+	// Parts and PartBytes are parts placed on the server according to the database
+	Parts     int64 `json:"parts"`
+	PartBytes int64 `json:"part_bytes"`
+	Draining  bool  `json:"draining"`
+}
+
+type ClusterStatusResponse struct {
+	Servers        []ServerStatus `json:"servers"`
+	HealthyServers int            `json:"healthy_servers"`
+	UsedBytes      int64          `json:"used_bytes"`
+	Parts          int64          `json:"parts"`
+}
+
+// GetStorageServers returns circuit breaker states of storage servers.
+func (h *Handlers) GetStorageServers(w http.ResponseWriter, r *http.Request) {
+	servers, err := h.storage.ServersHealth(r.Context())
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	result := make([]ServerHealth, len(servers))
+
+	for i, server := range servers {
+		result[i] = toServerHealth(server)
+	}
+
+	response.JSON(w, result)
+}
+
+// GetClusterStatus returns health, usage and part counts of every storage server.
+func (h *Handlers) GetClusterStatus(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.upload.ClusterStatus(r.Context())
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	resp := ClusterStatusResponse{
+		Servers:        make([]ServerStatus, len(statuses)),
+		HealthyServers: 0,
+		UsedBytes:      0,
+		Parts:          0,
+	}
+
+	for i, status := range statuses {
+		resp.Servers[i] = ServerStatus{
+			ServerHealth:  toServerHealth(status.Health),
+			CapacityBytes: status.Stat.Capacity,
+			UsedBytes:     status.Stat.Used,
+			StoredParts:   status.Stat.Parts,
+			StoredVolumes: status.Stat.Volumes,
+			StatError:     "",
+			Parts:         status.Parts.Parts,
+			PartBytes:     status.Parts.Bytes,
+			Draining:      status.Draining,
+		}
+
+		if status.StatErr != nil {
+			resp.Servers[i].StatError = status.StatErr.Error()
+		}
+
+		if status.Health.State != storage.BreakerOpen {
+			resp.HealthyServers++
+		}
+
+		resp.UsedBytes += status.Stat.Used
+		resp.Parts += status.Parts.Parts
+	}
+
+	response.JSON(w, resp)
+}
+
+func toServerHealth(server storage.ServerHealth) ServerHealth {
+	var openedAt *time.Time
+
+	if !server.OpenedAt.IsZero() {
+		openedAt = &server.OpenedAt
+	}
+
+	return ServerHealth{
+		ServerURL:    server.ServerURL,
+		State:        string(server.State),
+		FailureRate:  server.FailureRate,
+		AvgLatencyMs: float64(server.AvgLatency) / float64(time.Millisecond),
+		Calls:        server.Calls,
+		Failures:     server.Failures,
+		OpenedAt:     openedAt,
+	}
+}
@@ -0,0 +1,256 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+	"github.com/quolpr/distributeds3/internal/service/upload"
+)
+
+const (
+	maxRequestSize     = 64 * 1024
+	defaultGracePeriod = 24 * time.Hour
+)
+
+type ScrubRequest struct {
+	// BatchSize is the number of uploads loaded at once, the server default if zero
+	BatchSize int32 `json:"batch_size"`
+}
+
+type ScrubResponse struct {
+	Uploads        int      `json:"uploads"`
+	Parts          int      `json:"parts"`
+	Bytes          int64    `json:"bytes"`
+	CorruptUploads []string `json:"corrupt_uploads"`
+}
+
+type GCRequest struct {
+	// GracePeriod is a Go duration, e.g. 48h. Data modified within it is kept, 24h if empty
+	GracePeriod string `json:"grace_period"`
+	DryRun      bool   `json:"dry_run"`
+}
+
+type GCResponse struct {
+	Servers         int      `json:"servers"`
+	Scanned         int      `json:"scanned"`
+	OrphanedParts   int      `json:"orphaned_parts"`
+	OrphanedVolumes int      `json:"orphaned_volumes"`
+	OrphanedBytes   int64    `json:"orphaned_bytes"`
+	FailedServers   []string `json:"failed_servers"`
+}
+
+type RebalanceRequest struct {
+	// BatchSize is the number of blobs loaded at once, the server default if zero
+	BatchSize int32 `json:"batch_size"`
+	DryRun    bool  `json:"dry_run"`
+}
+
+type RebalanceResponse struct {
+	Servers      []string `json:"servers"`
+	MovedBlobs   int      `json:"moved_blobs"`
+	MovedVolumes int      `json:"moved_volumes"`
+	MovedParts   int64    `json:"moved_parts"`
+	MovedBytes   int64    `json:"moved_bytes"`
+	Failed       int      `json:"failed"`
+}
+
+type CleanupRequest struct {
+	// BatchSize and MaxAttempts are the server defaults if zero
+	BatchSize   int32 `json:"batch_size"`
+	MaxAttempts int32 `json:"max_attempts"`
+	DryRun      bool  `json:"dry_run"`
+}
+
+type CleanupResponse struct {
+	// Skipped is set if the cleanup is running in another instance
+	Skipped        bool  `json:"skipped"`
+	Uploads        int   `json:"uploads"`
+	CleanedParts   int   `json:"cleaned_parts"`
+	FailedParts    int   `json:"failed_parts"`
+	CleanedVolumes int   `json:"cleaned_volumes"`
+	FailedVolumes  int   `json:"failed_volumes"`
+	ReclaimedBytes int64 `json:"reclaimed_bytes"`
+}
+
+type CompactResponse struct {
+	Volumes int `json:"volumes"`
+}
+
+// Scrub verifies every committed upload. Corrupt uploads are reported with 200.
+func (h *Handlers) Scrub(w http.ResponseWriter, r *http.Request) {
+	var req ScrubRequest
+
+	if err := decodeRequest(w, r, &req); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	report, err := h.upload.Scrub(r.Context(), upload.ScrubOptions{BatchSize: req.BatchSize})
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	resp := ScrubResponse{
+		Uploads:        report.Uploads,
+		Parts:          report.Parts,
+		Bytes:          report.Bytes,
+		CorruptUploads: make([]string, len(report.CorruptUploads)),
+	}
+
+	for i, id := range report.CorruptUploads {
+		resp.CorruptUploads[i] = id.String()
+	}
+
+	response.JSON(w, resp)
+}
+
+// CollectGarbage deletes data on storage servers no part references.
+func (h *Handlers) CollectGarbage(w http.ResponseWriter, r *http.Request) {
+	var req GCRequest
+
+	if err := decodeRequest(w, r, &req); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	grace := defaultGracePeriod
+
+	if req.GracePeriod != "" {
+		var err error
+		grace, err = time.ParseDuration(req.GracePeriod)
+
+		if err != nil || grace < 0 {
+			response.Error(w, fmt.Errorf("%w: invalid grace_period %q", response.ErrInvalidRequest, req.GracePeriod))
+
+			return
+		}
+	}
+
+	report, err := h.upload.CollectGarbage(r.Context(), upload.GCOptions{GracePeriod: grace, DryRun: req.DryRun})
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, GCResponse{
+		Servers:         report.Servers,
+		Scanned:         report.Scanned,
+		OrphanedParts:   report.OrphanedParts,
+		OrphanedVolumes: report.OrphanedVolumes,
+		OrphanedBytes:   report.OrphanedBytes,
+		FailedServers:   nonNil(report.FailedServers),
+	})
+}
+
+// Rebalance moves data off draining servers.
+func (h *Handlers) Rebalance(w http.ResponseWriter, r *http.Request) {
+	var req RebalanceRequest
+
+	if err := decodeRequest(w, r, &req); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	report, err := h.upload.Rebalance(r.Context(), upload.RebalanceOptions{BatchSize: req.BatchSize, DryRun: req.DryRun})
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, RebalanceResponse{
+		Servers:      nonNil(report.Servers),
+		MovedBlobs:   report.MovedBlobs,
+		MovedVolumes: report.MovedVolumes,
+		MovedParts:   report.MovedParts,
+		MovedBytes:   report.MovedBytes,
+		Failed:       report.Failed,
+	})
+}
+
+// CleanDangleUploads deletes uploads not committed within MAX_UPLOAD_TIME and retries failed part cleanups.
+func (h *Handlers) CleanDangleUploads(w http.ResponseWriter, r *http.Request) {
+	var req CleanupRequest
+
+	if err := decodeRequest(w, r, &req); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	opts := upload.DefaultCleanupOptions()
+	opts.DryRun = req.DryRun
+
+	if req.BatchSize > 0 {
+		opts.BatchSize = req.BatchSize
+	}
+
+	if req.MaxAttempts > 0 {
+		opts.MaxAttempts = req.MaxAttempts
+	}
+
+	report, err := h.upload.CleanDangleUploads(r.Context(), opts)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, CleanupResponse{
+		Skipped:        report.Skipped,
+		Uploads:        report.Uploads,
+		CleanedParts:   report.CleanedParts,
+		FailedParts:    report.FailedParts,
+		CleanedVolumes: report.CleanedVolumes,
+		FailedVolumes:  report.FailedVolumes,
+		ReclaimedBytes: report.ReclaimedBytes,
+	})
+}
+
+// CompactVolumes rewrites volumes with more than COMPACTION_RATIO of deleted data.
+func (h *Handlers) CompactVolumes(w http.ResponseWriter, r *http.Request) {
+	compacted, err := h.upload.CompactVolumes(r.Context())
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, CompactResponse{Volumes: compacted})
+}
+
+// decodeRequest decodes the JSON body into req. An empty body leaves req with zero values.
+func decodeRequest(w http.ResponseWriter, r *http.Request, req any) error {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(req)
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: invalid request body: %w", response.ErrInvalidRequest, err)
+	}
+
+	return nil
+}
+
+// nonNil makes empty lists encode as [] instead of null.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
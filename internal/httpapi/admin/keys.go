@@ -0,0 +1,68 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+)
+
+var ErrEncryptionDisabled = apperror.New(
+	apperror.KindInvalidInput, "encryption_disabled", "server-side encryption is disabled, SSE_KEYRING_PATH is not set",
+)
+
+type RotateKeysRequest struct {
+	// NewMasterKey generates a new active master key before data keys are rewrapped
+	NewMasterKey bool `json:"new_master_key"`
+}
+
+type RotateKeysResponse struct {
+	ActiveKeyID string `json:"active_key_id"`
+	// Rewrapped is the number of uploads whose data key was rewrapped with the active master key
+	Rewrapped int `json:"rewrapped"`
+}
+
+// RotateKeys rewraps data keys with the active master key, optionally generating a new one first.
+// Other instances sharing the keyring keep the previous active key till they reload it.
+func (h *Handlers) RotateKeys(w http.ResponseWriter, r *http.Request) {
+	if h.keyring == nil {
+		response.Error(w, ErrEncryptionDisabled)
+
+		return
+	}
+
+	var req RotateKeysRequest
+
+	if err := decodeRequest(w, r, &req); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	if req.NewMasterKey {
+		if _, err := h.keyring.Rotate(); err != nil {
+			response.Error(w, fmt.Errorf("unable to rotate master key: %w", err))
+
+			return
+		}
+	}
+
+	rewrapped, err := h.upload.RewrapDataKeys(r.Context())
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	activeKeyID, err := h.keyring.ActiveKeyID(r.Context())
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, RotateKeysResponse{ActiveKeyID: activeKeyID, Rewrapped: rewrapped})
+}
@@ -0,0 +1,54 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+)
+
+type ServerRequest struct {
+	ServerURL string `json:"server_url"`
+}
+
+// AddServer adds a storage server to the cluster, new parts are placed on it right away.
+func (h *Handlers) AddServer(w http.ResponseWriter, r *http.Request) {
+	h.updateServer(w, r, h.upload.AddServer)
+}
+
+// DrainServer stops placing new parts on the server, its data is moved by the rebalance job.
+func (h *Handlers) DrainServer(w http.ResponseWriter, r *http.Request) {
+	h.updateServer(w, r, h.upload.DrainServer)
+}
+
+// UndrainServer places new parts on a drained server again.
+func (h *Handlers) UndrainServer(w http.ResponseWriter, r *http.Request) {
+	h.updateServer(w, r, h.upload.UndrainServer)
+}
+
+func (h *Handlers) updateServer(
+	w http.ResponseWriter, r *http.Request, update func(ctx context.Context, serverURL string) error,
+) {
+	var req ServerRequest
+
+	if err := decodeRequest(w, r, &req); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	if req.ServerURL == "" {
+		response.Error(w, fmt.Errorf("%w: server_url is required", response.ErrInvalidRequest))
+
+		return
+	}
+
+	if err := update(r.Context(), req.ServerURL); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
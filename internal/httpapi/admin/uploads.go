@@ -0,0 +1,287 @@
+package admin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+)
+
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
+type Upload struct {
+	ID             uuid.UUID  `json:"id"`
+	Bucket         string     `json:"bucket"`
+	Name           string     `json:"name"`
+	Size           int64      `json:"size"`
+	Status         string     `json:"status"`
+	IsLatest       bool       `json:"is_latest"`
+	IsDeleteMarker bool       `json:"is_delete_marker"`
+	RetainUntil    *time.Time `json:"retain_until,omitempty"`
+	LegalHold      bool       `json:"legal_hold"`
+	// Encryption is none, sse or sse-c
+	Encryption  string    `json:"encryption"`
+	MasterKeyID string    `json:"master_key_id,omitempty"`
+	Owner       string    `json:"owner"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type Part struct {
+	Number         int32  `json:"number"`
+	ServerURL      string `json:"server_url"`
+	Size           int64  `json:"size"`
+	CompressedSize int64  `json:"compressed_size"`
+	Codec          string `json:"codec"`
+	Status         string `json:"status"`
+	BlobID         string `json:"blob_id"`
+	// VolumeID is set if the part is packed into a volume
+	VolumeID     string `json:"volume_id,omitempty"`
+	VolumeOffset int64  `json:"volume_offset,omitempty"`
+	VolumeLength int64  `json:"volume_length,omitempty"`
+}
+
+type ListUploadsResponse struct {
+	Uploads []Upload `json:"uploads"`
+}
+
+type UploadResponse struct {
+	Upload Upload `json:"upload"`
+	Parts  []Part `json:"parts"`
+}
+
+type VerifyRequest struct {
+	// CustomerKey is the base64 encoded key of an SSE-C upload, without it parts are only checked to be readable
+	CustomerKey string `json:"sse_c_key"`
+}
+
+type PartFailure struct {
+	Number    int32  `json:"number"`
+	ServerURL string `json:"server_url"`
+	Error     string `json:"error"`
+}
+
+type VerifyResponse struct {
+	Parts    int           `json:"parts"`
+	Bytes    int64         `json:"bytes"`
+	Failures []PartFailure `json:"failures"`
+}
+
+// ListUploads returns uploads of all statuses ordered by ID, the page starts after the "after" ID.
+func (h *Handlers) ListUploads(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	after := uuid.Nil
+	limit := defaultListLimit
+
+	if value := query.Get("after"); value != "" {
+		var err error
+		after, err = uuid.Parse(value)
+
+		if err != nil {
+			response.Error(w, fmt.Errorf("%w: invalid after: %w", response.ErrInvalidRequest, err))
+
+			return
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+
+		if err != nil || limit < 1 || limit > maxListLimit {
+			response.Error(w, fmt.Errorf("%w: limit must be from 1 to %d", response.ErrInvalidRequest, maxListLimit))
+
+			return
+		}
+	}
+
+	uploads, err := h.upload.ListUploads(r.Context(), query.Get("bucket"), query.Get("prefix"), after, int32(limit))
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	resp := ListUploadsResponse{Uploads: make([]Upload, len(uploads))}
+
+	for i, upload := range uploads {
+		resp.Uploads[i] = toUpload(upload)
+	}
+
+	response.JSON(w, resp)
+}
+
+// GetUpload returns the upload with its parts, so it can be seen where the data is placed.
+func (h *Handlers) GetUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUploadID(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	upload, parts, err := h.upload.UploadParts(r.Context(), id)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	resp := UploadResponse{Upload: toUpload(upload), Parts: make([]Part, len(parts))}
+
+	for i, part := range parts {
+		resp.Parts[i] = toPart(part)
+	}
+
+	response.JSON(w, resp)
+}
+
+// DeleteUpload removes a committed or failed upload with its data.
+func (h *Handlers) DeleteUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUploadID(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	if err := h.upload.DeleteUpload(r.Context(), id); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyUpload reads the upload back from storage servers. Failed parts are reported with 200,
+// an error status means the check couldn't run.
+func (h *Handlers) VerifyUpload(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUploadID(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	var req VerifyRequest
+
+	if err := decodeRequest(w, r, &req); err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	var customerKey []byte
+
+	if req.CustomerKey != "" {
+		customerKey, err = base64.StdEncoding.DecodeString(req.CustomerKey)
+
+		if err != nil {
+			response.Error(w, fmt.Errorf("%w: sse_c_key must be base64 encoded", response.ErrInvalidRequest))
+
+			return
+		}
+	}
+
+	report, err := h.upload.VerifyUpload(r.Context(), id, customerKey)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	resp := VerifyResponse{
+		Parts:    report.Parts,
+		Bytes:    report.Bytes,
+		Failures: make([]PartFailure, len(report.Failures)),
+	}
+
+	for i, failure := range report.Failures {
+		resp.Failures[i] = PartFailure{
+			Number:    failure.Part.Number,
+			ServerURL: failure.Part.ServerURL,
+			Error:     failure.Err.Error(),
+		}
+	}
+
+	response.JSON(w, resp)
+}
+
+func parseUploadID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue("id"))
+
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid upload ID: %w", response.ErrInvalidRequest, err)
+	}
+
+	return id, nil
+}
+
+func toUpload(upload model.Upload) Upload {
+	var retainUntil *time.Time
+
+	if !upload.RetainUntil.IsZero() {
+		retainUntil = &upload.RetainUntil
+	}
+
+	encryption := "none"
+
+	switch {
+	case len(upload.CustomerKeyFingerprint) > 0:
+		encryption = "sse-c"
+	case len(upload.EncryptedDataKey) > 0:
+		encryption = "sse"
+	}
+
+	return Upload{
+		ID:             upload.ID,
+		Bucket:         upload.Bucket,
+		Name:           upload.Name,
+		Size:           upload.Size,
+		Status:         string(upload.Status),
+		IsLatest:       upload.IsLatest,
+		IsDeleteMarker: upload.IsDeleteMarker,
+		RetainUntil:    retainUntil,
+		LegalHold:      upload.LegalHold,
+		Encryption:     encryption,
+		MasterKeyID:    upload.MasterKeyID,
+		Owner:          upload.Owner,
+		CreatedAt:      upload.CreatedAt,
+		UpdatedAt:      upload.UpdatedAt,
+	}
+}
+
+func toPart(part model.Part) Part {
+	resp := Part{ //nolint:exhaustruct
+		Number:         part.Number,
+		ServerURL:      part.ServerURL,
+		Size:           part.Size,
+		CompressedSize: part.CompressedSize,
+		Codec:          string(part.Codec),
+		Status:         string(part.Status),
+		BlobID:         part.BlobID.String(),
+	}
+
+	if part.IsPacked() {
+		resp.VolumeID = part.VolumeID.String()
+		resp.VolumeOffset = part.VolumeOffset
+		resp.VolumeLength = part.VolumeLength
+	}
+
+	return resp
+}
@@ -0,0 +1,65 @@
+package health
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"github.com/quolpr/distributeds3/internal/service/health"
+)
+
+type Handlers struct {
+	svc *health.Service
+}
+
+func NewHandlers(svc *health.Service) *Handlers {
+	return &Handlers{
+		svc: svc,
+	}
+}
+
+type CheckResponse struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type ReadyResponse struct {
+	Ready  bool            `json:"ready"`
+	Checks []CheckResponse `json:"checks"`
+}
+
+// Healthz reports that the process is alive, it doesn't check dependencies.
+func (h *Handlers) Healthz(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Readyz reports whether the instance can serve requests, it responds with 503 if any check fails.
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks, ready := h.svc.Ready(r.Context())
+
+	resp := ReadyResponse{
+		Ready:  ready,
+		Checks: make([]CheckResponse, len(checks)),
+	}
+
+	for i, check := range checks {
+		resp.Checks[i] = CheckResponse{Name: check.Name, OK: check.Err == nil, Error: ""}
+
+		if check.Err != nil {
+			resp.Checks[i].Error = check.Err.Error()
+		}
+	}
+
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		slog.Error("Unable to write response", "err", err)
+	}
+}
@@ -0,0 +1,97 @@
+package lifecycle
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+	"github.com/quolpr/distributeds3/internal/service/lifecycle"
+	"github.com/quolpr/distributeds3/internal/service/lifecycle/model"
+)
+
+const maxConfigurationSize = 1024 * 1024
+
+type Handlers struct {
+	svc *lifecycle.Service
+}
+
+func NewHandlers(svc *lifecycle.Service) *Handlers {
+	return &Handlers{
+		svc: svc,
+	}
+}
+
+type Rule struct {
+	Prefix                    string `json:"prefix"`
+	ExpirationDays            int32  `json:"expiration_days"`
+	NoncurrentExpirationDays  int32  `json:"noncurrent_expiration_days"`
+	AbortIncompleteUploadDays int32  `json:"abort_incomplete_upload_days"`
+}
+
+type Configuration struct {
+	Rules []Rule `json:"rules"`
+}
+
+func (h *Handlers) GetConfiguration(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.svc.GetBucketRules(r.Context(), r.PathValue("bucket"))
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, toConfiguration(rules))
+}
+
+// PutConfiguration replaces lifecycle rules of the bucket. Empty rules list removes them all.
+func (h *Handlers) PutConfiguration(w http.ResponseWriter, r *http.Request) {
+	var req Configuration
+
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxConfigurationSize)).Decode(&req)
+
+	if err != nil {
+		response.Error(w, fmt.Errorf("%w: invalid lifecycle configuration: %w", response.ErrInvalidRequest, err))
+
+		return
+	}
+
+	rules := make([]model.Rule, len(req.Rules))
+
+	for i, rule := range req.Rules {
+		rules[i] = model.Rule{ //nolint:exhaustruct
+			Prefix:                    rule.Prefix,
+			ExpirationDays:            rule.ExpirationDays,
+			NoncurrentExpirationDays:  rule.NoncurrentExpirationDays,
+			AbortIncompleteUploadDays: rule.AbortIncompleteUploadDays,
+		}
+	}
+
+	rules, err = h.svc.PutBucketRules(r.Context(), r.PathValue("bucket"), rules)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, toConfiguration(rules))
+}
+
+func toConfiguration(rules []model.Rule) Configuration {
+	resp := Configuration{
+		Rules: make([]Rule, len(rules)),
+	}
+
+	for i, rule := range rules {
+		resp.Rules[i] = Rule{
+			Prefix:                    rule.Prefix,
+			ExpirationDays:            rule.ExpirationDays,
+			NoncurrentExpirationDays:  rule.NoncurrentExpirationDays,
+			AbortIncompleteUploadDays: rule.AbortIncompleteUploadDays,
+		}
+	}
+
+	return resp
+}
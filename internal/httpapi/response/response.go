@@ -0,0 +1,128 @@
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/httpapi/requestid"
+)
+
+// ErrInvalidRequest is a malformed request, handlers wrap it with details.
+var ErrInvalidRequest = apperror.New(apperror.KindBadRequest, "invalid_request", "invalid request")
+
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id"`
+}
+
+// Error responds with the status and code of the domain error. Server side errors are logged,
+// their details are not exposed to the client.
+func Error(w http.ResponseWriter, err error) {
+	requestID := w.Header().Get(requestid.Header)
+	status, resp := toErrorResponse(err)
+	resp.RequestID = requestID
+
+	if status >= http.StatusInternalServerError {
+		slog.Error("Unable to handle request", "err", err, "request_id", requestID)
+	} else {
+		slog.Info("Request is rejected", "err", err, "request_id", requestID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	body, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("Unable to marshal error response", "err", err)
+	}
+
+	_, err = w.Write(body)
+
+	if err != nil {
+		slog.Error("Unable to write response", "err", err)
+	}
+}
+
+func toErrorResponse(err error) (int, ErrorResponse) {
+	var maxBytesErr *http.MaxBytesError
+
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge, ErrorResponse{ //nolint:exhaustruct
+			Error: fmt.Sprintf("request body is larger than %d bytes", maxBytesErr.Limit),
+			Code:  "request_too_large",
+		}
+	}
+
+	appErr := apperror.From(err)
+
+	if appErr == nil {
+		return http.StatusInternalServerError, ErrorResponse{ //nolint:exhaustruct
+			Error: "internal error",
+			Code:  "internal_error",
+		}
+	}
+
+	status := toStatus(appErr.Kind)
+
+	if status >= http.StatusInternalServerError {
+		return status, ErrorResponse{Error: appErr.Message, Code: appErr.Code} //nolint:exhaustruct
+	}
+
+	return status, ErrorResponse{Error: publicMessage(err, appErr), Code: appErr.Code} //nolint:exhaustruct
+}
+
+func toStatus(kind apperror.Kind) int {
+	switch kind {
+	case apperror.KindBadRequest, apperror.KindChecksumMismatch:
+		return http.StatusBadRequest
+	case apperror.KindInvalidInput:
+		return http.StatusUnprocessableEntity
+	case apperror.KindNotFound:
+		return http.StatusNotFound
+	case apperror.KindConflict:
+		return http.StatusConflict
+	case apperror.KindUnavailable:
+		return http.StatusServiceUnavailable
+	case apperror.KindRangeNotSatisfiable:
+		return http.StatusRequestedRangeNotSatisfiable
+	case apperror.KindUnauthorized:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// publicMessage cuts the internal context wrapped around the domain error, details wrapped
+// as "%w: detail" are kept.
+func publicMessage(err error, appErr *apperror.Error) string {
+	message := err.Error()
+
+	if i := strings.Index(message, appErr.Message); i >= 0 {
+		return message[i:]
+	}
+
+	return appErr.Message
+}
+
+func JSON(w http.ResponseWriter, resp any) {
+	jsonResponse, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, err = w.Write(jsonResponse)
+
+	if err != nil {
+		slog.Error("Unable to write response", "err", err)
+	}
+}
@@ -0,0 +1,51 @@
+package upload
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+	"github.com/quolpr/distributeds3/pkg/compression"
+)
+
+const codecHeader = "X-Compression-Codec"
+
+// parseCodec returns the compression codec requested by the client or empty string
+// to use the server default.
+func parseCodec(r *http.Request) (compression.Codec, error) {
+	value := r.Header.Get(codecHeader)
+
+	if value == "" {
+		return "", nil
+	}
+
+	codec, err := compression.ParseCodec(value)
+
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", response.ErrInvalidRequest, err)
+	}
+
+	return codec, nil
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip responses.
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, token := range strings.Split(value, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(token), ";")
+
+			if coding != "gzip" && coding != "*" {
+				continue
+			}
+
+			q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+
+			if !ok || strings.Trim(q, "0.") != "" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -2,66 +2,62 @@ package upload
 
 import (
 	"bufio"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
 	"github.com/quolpr/distributeds3/internal/service/upload"
 )
 
-const (
-	maxUploadSize = 1024*1024*1024*10 + 1024
-)
+// multipartOverhead is the room for form fields and boundaries of a multipart upload.
+const multipartOverhead = 1024
 
 type Handlers struct {
 	svc *upload.Service
+	// maxUploadSize is the max size of an object, bodies beyond it are rejected.
+	maxUploadSize int64
 }
 
-func NewHandlers(svc *upload.Service) *Handlers {
+func NewHandlers(svc *upload.Service, maxUploadSize int64) *Handlers {
 	return &Handlers{
-		svc: svc,
+		svc:           svc,
+		maxUploadSize: maxUploadSize,
 	}
 }
 
 type UploadResponse struct {
-	UploadID string `json:"upload_id"`
-}
-
-type UploadErrorResponse struct {
-	Error string `json:"error"`
+	UploadID  string `json:"upload_id"`
+	VersionID string `json:"version_id"`
 }
 
-func handleError(w http.ResponseWriter, err error) {
-	slog.Error("Unable to handle request", "err", err)
+func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
+	// function body of a http.HandlerFunc
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize+multipartOverhead)
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusBadRequest)
+	customerKey, err := parseCustomerKey(r)
 
-	resp, err := json.Marshal(UploadErrorResponse{
-		Error: err.Error(),
-	})
 	if err != nil {
-		slog.Error("Unable to marshal error response", "err", err)
+		response.Error(w, err)
+
+		return
 	}
 
-	_, err = w.Write(resp)
+	codec, err := parseCodec(r)
 
 	if err != nil {
-		slog.Error("Unable to write response", "err", err)
+		response.Error(w, err)
+
+		return
 	}
-}
 
-func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
-	// function body of a http.HandlerFunc
-	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
 	reader, err := r.MultipartReader()
 
 	if err != nil {
-		handleError(w, err)
+		response.Error(w, fmt.Errorf("%w: %w", response.ErrInvalidRequest, err))
 
 		return
 	}
@@ -70,91 +66,150 @@ func (h *Handlers) HandleUpload(w http.ResponseWriter, r *http.Request) {
 	p, err := reader.NextPart()
 
 	if err != nil {
-		handleError(w, err)
+		response.Error(w, fmt.Errorf("%w: %w", response.ErrInvalidRequest, err))
 
 		return
 	}
 
 	if p.FormName() != "file_size" {
-		handleError(w, errors.New("file_size is expected"))
+		response.Error(w, fmt.Errorf("%w: file_size is expected", response.ErrInvalidRequest))
 
 		return
 	}
 
 	n, err := p.Read(fileSizeStr)
 	if err != nil && !errors.Is(err, io.EOF) {
-		handleError(w, err)
+		response.Error(w, err)
 
 		return
 	}
 
-	fileSize, err := strconv.Atoi(string(fileSizeStr[:n]))
+	fileSize, err := strconv.ParseInt(string(fileSizeStr[:n]), 10, 64)
 	if err != nil {
-		handleError(w, err)
+		response.Error(w, fmt.Errorf("%w: invalid file_size: %w", response.ErrInvalidRequest, err))
+
+		return
+	}
+
+	if fileSize < 0 || fileSize > h.maxUploadSize {
+		response.Error(w, fmt.Errorf(
+			"%w: file_size must be from 0 to %d bytes", response.ErrInvalidRequest, h.maxUploadSize,
+		))
 
 		return
 	}
 
 	p, err = reader.NextPart()
 	if err != nil && !errors.Is(err, io.EOF) {
-		handleError(w, err)
+		response.Error(w, fmt.Errorf("%w: %w", response.ErrInvalidRequest, err))
 
 		return
 	}
 
 	if p.FormName() != "file" {
-		handleError(w, errors.New("file is expected"))
+		response.Error(w, fmt.Errorf("%w: file is expected", response.ErrInvalidRequest))
+
+		return
+	}
+
+	if p.FileName() == "" {
+		response.Error(w, fmt.Errorf("%w: file name is expected", response.ErrInvalidRequest))
 
 		return
 	}
 
 	buf := bufio.NewReader(p)
 
-	// TODO: parse file name
 	// TODO: parse content type
-	upload, err := h.svc.CreateUpload(r.Context(), int64(fileSize), "my-file", buf)
+	upload, err := h.svc.CreateUpload(
+		r.Context(), upload.DefaultBucket, fileSize, p.FileName(), buf,
+		upload.UploadOptions{CustomerKey: customerKey, Codec: codec},
+	)
 
 	if err != nil {
-		handleError(w, err)
+		response.Error(w, err)
 
 		return
 	}
 
-	jsonResponse, err := json.Marshal(UploadResponse{
-		UploadID: upload.ID.String(),
+	setCustomerKeyHeaders(w, customerKey)
+	response.JSON(w, UploadResponse{
+		UploadID:  upload.ID.String(),
+		VersionID: upload.ID.String(),
 	})
+}
+
+func (h *Handlers) GetUpload(w http.ResponseWriter, r *http.Request) {
+	idString := r.PathValue("id")
+
+	id, err := uuid.Parse(idString)
+
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, fmt.Errorf("%w: invalid upload id: %w", response.ErrInvalidRequest, err))
 
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	_, err = w.Write(jsonResponse)
+	customerKey, err := parseCustomerKey(r)
 
 	if err != nil {
-		slog.Error("Unable to write response", "err", err)
+		response.Error(w, err)
+
+		return
 	}
+
+	h.streamUpload(w, r, id, customerKey)
 }
-func (h *Handlers) GetUpload(w http.ResponseWriter, r *http.Request) {
-	idString := r.PathValue("id")
 
-	id, err := uuid.Parse(idString)
+// streamUpload writes the upload or the range requested by Range header to the response. Gzip compressed
+// parts are sent as is if the client accepts gzip and the whole upload is requested.
+func (h *Handlers) streamUpload(w http.ResponseWriter, r *http.Request, id uuid.UUID, customerKey []byte) {
+	reader, err := h.svc.OpenUpload(r.Context(), id, upload.ReadOptions{
+		CustomerKey: customerKey,
+		AcceptGzip:  acceptsGzip(r) && r.Header.Get(rangeHeader) == "",
+	})
 
 	if err != nil {
-		handleError(w, err)
+		response.Error(w, err)
 
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/octet-stream")
-
-	err = h.svc.ReadUpload(r.Context(), id, w)
+	size := reader.Upload().Size
+	byteRange, partial, err := parseRange(r, size)
 
 	if err != nil {
-		handleError(w, err)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		response.Error(w, err)
 
 		return
 	}
+
+	writer := newStreamWriter(w)
+
+	setObjectHeaders(writer, reader.Upload())
+	writer.Header().Set("Content-Type", "application/octet-stream")
+	writer.Header().Add("Vary", "Accept-Encoding")
+	setCustomerKeyHeaders(writer, customerKey)
+
+	switch {
+	case partial:
+		// Only the requested bytes of plain parts are read from storage. Compressed and encrypted
+		// parts can't be sliced, so the ones overlapping the range are still read whole.
+		writer.Header().Set("Content-Range", byteRange.contentRange(size))
+		writer.Header().Set("Content-Length", strconv.FormatInt(byteRange.length, 10))
+		writer.WriteHeader(http.StatusPartialContent)
+
+		sendStream(w, r, writer, func(out io.Writer) error {
+			return reader.StreamRange(r.Context(), out, byteRange.offset, byteRange.length)
+		})
+	case reader.ContentEncoding() != "":
+		writer.Header().Set("Content-Encoding", reader.ContentEncoding())
+
+		sendStream(w, r, writer, func(out io.Writer) error { return reader.Stream(r.Context(), out) })
+	default:
+		writer.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+
+		sendStream(w, r, writer, func(out io.Writer) error { return reader.Stream(r.Context(), out) })
+	}
 }
@@ -0,0 +1,105 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+)
+
+const maxLockRequestSize = 1024
+
+type RetentionRequest struct {
+	RetainUntil time.Time `json:"retain_until"`
+}
+
+type LegalHoldRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+type LockResponse struct {
+	VersionID   string     `json:"version_id"`
+	RetainUntil *time.Time `json:"retain_until,omitempty"`
+	LegalHold   bool       `json:"legal_hold"`
+}
+
+// PutRetention sets WORM retention of the latest version or the one passed in versionId query param.
+func (h *Handlers) PutRetention(w http.ResponseWriter, r *http.Request) {
+	versionID, err := parseVersionID(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	var req RetentionRequest
+
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLockRequestSize)).Decode(&req)
+
+	if err != nil {
+		response.Error(w, fmt.Errorf("%w: invalid retention: %w", response.ErrInvalidRequest, err))
+
+		return
+	}
+
+	upload, err := h.svc.SetRetention(r.Context(), r.PathValue("bucket"), r.PathValue("key"), versionID, req.RetainUntil)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, toLockResponse(upload))
+}
+
+// PutLegalHold places or releases legal hold of the latest version or the one passed in versionId query param.
+func (h *Handlers) PutLegalHold(w http.ResponseWriter, r *http.Request) {
+	versionID, err := parseVersionID(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	var req LegalHoldRequest
+
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxLockRequestSize)).Decode(&req)
+
+	if err != nil {
+		response.Error(w, fmt.Errorf("%w: invalid legal hold: %w", response.ErrInvalidRequest, err))
+
+		return
+	}
+
+	upload, err := h.svc.SetLegalHold(r.Context(), r.PathValue("bucket"), r.PathValue("key"), versionID, req.Enabled)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	response.JSON(w, toLockResponse(upload))
+}
+
+func toLockResponse(upload model.Upload) LockResponse {
+	return LockResponse{
+		VersionID:   upload.ID.String(),
+		RetainUntil: retainUntilPtr(upload),
+		LegalHold:   upload.LegalHold,
+	}
+}
+
+func retainUntilPtr(upload model.Upload) *time.Time {
+	if upload.RetainUntil.IsZero() {
+		return nil
+	}
+
+	return &upload.RetainUntil
+}
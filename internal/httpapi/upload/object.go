@@ -0,0 +1,330 @@
+package upload
+
+import (
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+	"github.com/quolpr/distributeds3/internal/service/upload"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+)
+
+const (
+	versionIDHeader  = "X-Version-Id"
+	contentMD5Header = "Content-MD5"
+
+	defaultMaxKeys = 1000
+)
+
+type VersionResponse struct {
+	VersionID      string     `json:"version_id"`
+	Size           int64      `json:"size"`
+	IsLatest       bool       `json:"is_latest"`
+	IsDeleteMarker bool       `json:"is_delete_marker"`
+	RetainUntil    *time.Time `json:"retain_until,omitempty"`
+	LegalHold      bool       `json:"legal_hold"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ListVersionsResponse struct {
+	Versions []VersionResponse `json:"versions"`
+}
+
+type ObjectResponse struct {
+	Key       string `json:"key"`
+	VersionID string `json:"version_id"`
+	Size      int64  `json:"size"`
+	// ETag is the quoted hex MD5 of the data, it is empty for objects written before it was recorded
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+type ListObjectsResponse struct {
+	Objects []ObjectResponse `json:"objects"`
+	// NextAfter is passed as after query param to get the next page, it is empty on the last page
+	NextAfter string `json:"next_after,omitempty"`
+}
+
+// PutObject stores the request body as a new version of the object. Body size must be known upfront.
+func (h *Handlers) PutObject(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxUploadSize)
+
+	if r.ContentLength < 0 {
+		response.Error(w, fmt.Errorf("%w: content length is required", response.ErrInvalidRequest))
+
+		return
+	}
+
+	customerKey, err := parseCustomerKey(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	codec, err := parseCodec(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	contentMD5, err := parseContentMD5(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	upload, err := h.svc.CreateUpload(
+		r.Context(), r.PathValue("bucket"), r.ContentLength, r.PathValue("key"), r.Body,
+		upload.UploadOptions{CustomerKey: customerKey, Codec: codec, ContentMD5: contentMD5},
+	)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	setObjectHeaders(w, upload)
+	setCustomerKeyHeaders(w, customerKey)
+	response.JSON(w, UploadResponse{
+		UploadID:  upload.ID.String(),
+		VersionID: upload.ID.String(),
+	})
+}
+
+// GetObject returns the latest version of the object, or the one passed in versionId query param.
+func (h *Handlers) GetObject(w http.ResponseWriter, r *http.Request) {
+	versionID, err := parseVersionID(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	customerKey, err := parseCustomerKey(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	object, err := h.svc.GetObjectVersion(r.Context(), r.PathValue("bucket"), r.PathValue("key"), versionID)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	h.streamUpload(w, r, object.ID, customerKey)
+}
+
+// HeadObject returns headers of GetObject without reading the data.
+func (h *Handlers) HeadObject(w http.ResponseWriter, r *http.Request) {
+	versionID, err := parseVersionID(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	object, err := h.svc.GetObjectVersion(r.Context(), r.PathValue("bucket"), r.PathValue("key"), versionID)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	setObjectHeaders(w, object)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// ListObjects returns latest versions of objects of the bucket ordered by key. Query params: prefix,
+// after (the key to start after) and max-keys (1000 at most).
+func (h *Handlers) ListObjects(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	maxKeys := defaultMaxKeys
+
+	if value := query.Get("max-keys"); value != "" {
+		var err error
+		maxKeys, err = strconv.Atoi(value)
+
+		if err != nil || maxKeys <= 0 {
+			response.Error(w, fmt.Errorf("%w: max-keys must be a positive number", response.ErrInvalidRequest))
+
+			return
+		}
+
+		maxKeys = min(maxKeys, defaultMaxKeys)
+	}
+
+	objects, err := h.svc.ListObjects(
+		r.Context(), r.PathValue("bucket"), query.Get("prefix"), query.Get("after"), int32(maxKeys),
+	)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	resp := ListObjectsResponse{
+		Objects:   make([]ObjectResponse, len(objects)),
+		NextAfter: "",
+	}
+
+	for i, object := range objects {
+		resp.Objects[i] = ObjectResponse{
+			Key:          object.Name,
+			VersionID:    object.ID.String(),
+			Size:         object.Size,
+			ETag:         etag(object),
+			LastModified: object.CreatedAt,
+		}
+	}
+
+	if len(objects) == maxKeys {
+		resp.NextAfter = objects[len(objects)-1].Name
+	}
+
+	response.JSON(w, resp)
+}
+
+// DeleteObject puts a delete marker on top of the object. With versionId query param
+// the version is removed permanently instead.
+func (h *Handlers) DeleteObject(w http.ResponseWriter, r *http.Request) {
+	versionID, err := parseVersionID(r)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+
+	if versionID != uuid.Nil {
+		err = h.svc.DeleteObjectVersion(r.Context(), bucket, key, versionID)
+
+		if err != nil {
+			response.Error(w, err)
+
+			return
+		}
+
+		w.Header().Set(versionIDHeader, versionID.String())
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	marker, err := h.svc.DeleteObject(r.Context(), bucket, key)
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	w.Header().Set(versionIDHeader, marker.ID.String())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) ListObjectVersions(w http.ResponseWriter, r *http.Request) {
+	uploads, err := h.svc.ListObjectVersions(r.Context(), r.PathValue("bucket"), r.PathValue("key"))
+
+	if err != nil {
+		response.Error(w, err)
+
+		return
+	}
+
+	resp := ListVersionsResponse{
+		Versions: make([]VersionResponse, len(uploads)),
+	}
+
+	for i, upload := range uploads {
+		resp.Versions[i] = VersionResponse{
+			VersionID:      upload.ID.String(),
+			Size:           upload.Size,
+			IsLatest:       upload.IsLatest,
+			IsDeleteMarker: upload.IsDeleteMarker,
+			RetainUntil:    retainUntilPtr(upload),
+			LegalHold:      upload.LegalHold,
+			CreatedAt:      upload.CreatedAt,
+		}
+	}
+
+	response.JSON(w, resp)
+}
+
+func parseVersionID(r *http.Request) (uuid.UUID, error) {
+	versionIDString := r.URL.Query().Get("versionId")
+
+	if versionIDString == "" {
+		return uuid.Nil, nil
+	}
+
+	versionID, err := uuid.Parse(versionIDString)
+
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("%w: invalid versionId: %w", response.ErrInvalidRequest, err)
+	}
+
+	return versionID, nil
+}
+
+// parseContentMD5 returns the digest of Content-MD5 header or nil if it is not passed.
+func parseContentMD5(r *http.Request) ([]byte, error) {
+	value := r.Header.Get(contentMD5Header)
+
+	if value == "" {
+		return nil, nil
+	}
+
+	digest, err := base64.StdEncoding.DecodeString(value)
+
+	if err != nil || len(digest) != md5.Size {
+		return nil, fmt.Errorf("%w: Content-MD5 must be a base64 encoded MD5 digest", response.ErrInvalidRequest)
+	}
+
+	return digest, nil
+}
+
+// setObjectHeaders sets version, ETag and modification time of the object.
+func setObjectHeaders(w http.ResponseWriter, object model.Upload) {
+	w.Header().Set(versionIDHeader, object.ID.String())
+	w.Header().Set("Last-Modified", object.CreatedAt.UTC().Format(http.TimeFormat))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	if tag := etag(object); tag != "" {
+		w.Header().Set("ETag", tag)
+	}
+}
+
+// etag returns the quoted hex MD5 of the object data or empty string if it is not known.
+func etag(object model.Upload) string {
+	if len(object.ContentMD5) == 0 {
+		return ""
+	}
+
+	return `"` + hex.EncodeToString(object.ContentMD5) + `"`
+}
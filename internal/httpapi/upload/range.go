@@ -0,0 +1,84 @@
+package upload
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/quolpr/distributeds3/internal/apperror"
+)
+
+const rangeHeader = "Range"
+
+var errRangeNotSatisfiable = apperror.New(
+	apperror.KindRangeNotSatisfiable, "range_not_satisfiable", "range not satisfiable",
+)
+
+type byteRange struct {
+	offset int64
+	length int64
+}
+
+// contentRange returns the value of Content-Range header of a partial response.
+func (b byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", b.offset, b.offset+b.length-1, size)
+}
+
+// parseRange returns the single byte range requested by Range header. ok is false if the whole object
+// must be sent: the header is not passed, malformed or asks for several ranges, those are ignored as
+// allowed by RFC 9110.
+func parseRange(r *http.Request, size int64) (byteRange, bool, error) {
+	spec, found := strings.CutPrefix(r.Header.Get(rangeHeader), "bytes=")
+
+	if !found || strings.Contains(spec, ",") {
+		return byteRange{}, false, nil
+	}
+
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+
+	if !found {
+		return byteRange{}, false, nil
+	}
+
+	if first == "" {
+		// Suffix range: the last n bytes
+		n, err := strconv.ParseInt(last, 10, 64)
+
+		if err != nil || n < 0 {
+			return byteRange{}, false, nil
+		}
+
+		if n == 0 || size == 0 {
+			return byteRange{}, false, fmt.Errorf("%w: object is %d bytes", errRangeNotSatisfiable, size)
+		}
+
+		n = min(n, size)
+
+		return byteRange{offset: size - n, length: n}, true, nil
+	}
+
+	offset, err := strconv.ParseInt(first, 10, 64)
+
+	if err != nil || offset < 0 {
+		return byteRange{}, false, nil
+	}
+
+	end := size - 1
+
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+
+		if err != nil || end < offset {
+			return byteRange{}, false, nil
+		}
+
+		end = min(end, size-1)
+	}
+
+	if offset >= size {
+		return byteRange{}, false, fmt.Errorf("%w: object is %d bytes", errRangeNotSatisfiable, size)
+	}
+
+	return byteRange{offset: offset, length: end - offset + 1}, true, nil
+}
@@ -0,0 +1,149 @@
+package upload
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	const size = 100
+
+	tests := []struct {
+		header  string
+		size    int64
+		want    byteRange
+		partial bool
+		err     error
+	}{
+		{header: "", size: size},
+		{header: "bytes=0-9", size: size, want: byteRange{offset: 0, length: 10}, partial: true},
+		{header: "bytes=10-", size: size, want: byteRange{offset: 10, length: 90}, partial: true},
+		{header: "bytes=99-99", size: size, want: byteRange{offset: 99, length: 1}, partial: true},
+		{header: "bytes=90-500", size: size, want: byteRange{offset: 90, length: 10}, partial: true},
+		{header: "bytes=-10", size: size, want: byteRange{offset: 90, length: 10}, partial: true},
+		{header: "bytes=-500", size: size, want: byteRange{offset: 0, length: 100}, partial: true},
+		{header: "bytes= 5-6", size: size, want: byteRange{offset: 5, length: 2}, partial: true},
+		{header: "bytes=100-", size: size, err: errRangeNotSatisfiable},
+		{header: "bytes=100-200", size: size, err: errRangeNotSatisfiable},
+		{header: "bytes=-0", size: size, err: errRangeNotSatisfiable},
+		{header: "bytes=0-", size: 0, err: errRangeNotSatisfiable},
+		{header: "bytes=-5", size: 0, err: errRangeNotSatisfiable},
+		// Malformed and multi-range headers are ignored, the whole object is sent
+		{header: "bytes=0-1,5-6", size: size},
+		{header: "items=0-1", size: size},
+		{header: "bytes=5", size: size},
+		{header: "bytes=a-b", size: size},
+		{header: "bytes=9-5", size: size},
+		{header: "bytes=-1-5", size: size},
+		{header: "bytes=-", size: size},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/objects/b/k", nil)
+			r.Header.Set(rangeHeader, tt.header)
+
+			got, partial, err := parseRange(r, tt.size)
+
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("expected error %v, got %v", tt.err, err)
+			}
+
+			if got != tt.want || partial != tt.partial {
+				t.Errorf("expected %+v partial %t, got %+v partial %t", tt.want, tt.partial, got, partial)
+			}
+		})
+	}
+}
+
+func TestContentRange(t *testing.T) {
+	got := byteRange{offset: 10, length: 5}.contentRange(100)
+
+	if got != "bytes 10-14/100" {
+		t.Errorf("unexpected Content-Range %q", got)
+	}
+}
+
+var errStream = errors.New("stream failed")
+
+// streamRange sends the first length bytes of a 1MiB object with stream the way the download handler does.
+func streamRange(w http.ResponseWriter, r *http.Request, length int64, stream func(io.Writer) error) {
+	writer := newStreamWriter(w)
+	writer.Header().Set("Content-Range", byteRange{offset: 0, length: length}.contentRange(1<<20))
+	writer.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+	writer.WriteHeader(http.StatusPartialContent)
+
+	sendStream(w, r, writer, stream)
+}
+
+func TestStreamRangeFailsBeforeFirstByte(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	streamRange(w, httptest.NewRequest("GET", "/objects/b/k", nil), 10, func(io.Writer) error { return errStream })
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+
+	// The headers of the range must not leak into the error response
+	if got := w.Header().Get("Content-Range"); got != "" {
+		t.Errorf("unexpected Content-Range %q", got)
+	}
+
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("expected JSON error, got Content-Type %q", got)
+	}
+}
+
+func TestStreamRangeAbortsMidStream(t *testing.T) {
+	// Sent part is larger than the buffer of the server, so the status and the data reach the client
+	sent := make([]byte, 64<<10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		streamRange(w, r, 2*int64(len(sent)), func(writer io.Writer) error {
+			if _, err := writer.Write(sent); err != nil {
+				return err
+			}
+
+			return errStream
+		})
+	}))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL) //nolint:noctx
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		t.Fatalf("expected status %d, got %d", http.StatusPartialContent, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+
+	// The client must see a broken body, not a JSON error appended to the data
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+
+	if len(body) != len(sent) {
+		t.Errorf("expected %d bytes, got %d", len(sent), len(body))
+	}
+}
+
+func TestStreamEmptyBody(t *testing.T) {
+	w := httptest.NewRecorder()
+	writer := newStreamWriter(w)
+	writer.Header().Set("Content-Length", strconv.Itoa(0))
+
+	sendStream(w, httptest.NewRequest("GET", "/objects/b/k", nil), writer, func(io.Writer) error { return nil })
+
+	if w.Code != http.StatusOK || w.Header().Get("Content-Length") != "0" {
+		t.Errorf("expected empty 200 response, got %d with headers %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,64 @@
+package upload
+
+import (
+	"crypto/md5" //nolint:gosec
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/quolpr/distributeds3/internal/apperror"
+)
+
+const (
+	customerAlgorithmHeader = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	customerKeyHeader       = "X-Amz-Server-Side-Encryption-Customer-Key"
+	customerKeyMD5Header    = "X-Amz-Server-Side-Encryption-Customer-Key-MD5"
+
+	customerAlgorithm = "AES256"
+)
+
+var errInvalidCustomerKeyHeaders = apperror.New(apperror.KindBadRequest, "invalid_sse_c_headers", "invalid SSE-C headers")
+
+// parseCustomerKey returns the customer provided encryption key (SSE-C) or nil if it is not passed.
+func parseCustomerKey(r *http.Request) ([]byte, error) {
+	algorithm := r.Header.Get(customerAlgorithmHeader)
+	encodedKey := r.Header.Get(customerKeyHeader)
+	encodedMD5 := r.Header.Get(customerKeyMD5Header)
+
+	if algorithm == "" && encodedKey == "" && encodedMD5 == "" {
+		return nil, nil
+	}
+
+	if algorithm != customerAlgorithm {
+		return nil, fmt.Errorf("%w: only %s algorithm is supported", errInvalidCustomerKeyHeaders, customerAlgorithm)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encodedKey)
+
+	if err != nil || len(key) == 0 {
+		return nil, fmt.Errorf("%w: key must be base64 encoded", errInvalidCustomerKeyHeaders)
+	}
+
+	if encodedMD5 != "" {
+		sum := md5.Sum(key) //nolint:gosec
+
+		if subtle.ConstantTimeCompare([]byte(base64.StdEncoding.EncodeToString(sum[:])), []byte(encodedMD5)) != 1 {
+			return nil, fmt.Errorf("%w: key MD5 doesn't match", errInvalidCustomerKeyHeaders)
+		}
+	}
+
+	return key, nil
+}
+
+// setCustomerKeyHeaders confirms to the client which key was used.
+func setCustomerKeyHeaders(w http.ResponseWriter, key []byte) {
+	if key == nil {
+		return
+	}
+
+	sum := md5.Sum(key) //nolint:gosec
+
+	w.Header().Set(customerAlgorithmHeader, customerAlgorithm)
+	w.Header().Set(customerKeyMD5Header, base64.StdEncoding.EncodeToString(sum[:]))
+}
@@ -0,0 +1,77 @@
+package upload
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/quolpr/distributeds3/internal/httpapi/requestid"
+	"github.com/quolpr/distributeds3/internal/httpapi/response"
+)
+
+// streamWriter holds back the status and headers of the response until the first byte of the body,
+// so an error that happens before it can still be sent as a regular error response.
+type streamWriter struct {
+	w       http.ResponseWriter
+	header  http.Header
+	status  int
+	started bool
+}
+
+func newStreamWriter(w http.ResponseWriter) *streamWriter {
+	return &streamWriter{w: w, header: make(http.Header), status: http.StatusOK, started: false}
+}
+
+func (s *streamWriter) Header() http.Header {
+	return s.header
+}
+
+func (s *streamWriter) WriteHeader(status int) {
+	s.status = status
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.start()
+
+	return s.w.Write(p) //nolint:wrapcheck
+}
+
+// start sends the status and headers of the response if they are not sent yet.
+func (s *streamWriter) start() {
+	if s.started {
+		return
+	}
+
+	s.started = true
+
+	for key, values := range s.header {
+		s.w.Header()[key] = values
+	}
+
+	s.w.WriteHeader(s.status)
+}
+
+// sendStream writes the body with stream. If stream fails before the first byte, the error is sent
+// as the response. Once the body is started the client already has the status and part of the data,
+// so the connection is aborted instead: the client sees a broken response and not a complete one.
+func sendStream(w http.ResponseWriter, r *http.Request, writer *streamWriter, stream func(io.Writer) error) {
+	err := stream(writer)
+
+	if err == nil {
+		// Empty bodies never call Write
+		writer.start()
+
+		return
+	}
+
+	if !writer.started {
+		response.Error(w, err)
+
+		return
+	}
+
+	slog.Error("Unable to stream response, aborting it", "err", err,
+		"request_id", w.Header().Get(requestid.Header), "path", r.URL.Path)
+
+	panic(http.ErrAbortHandler)
+}
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "distributeds3"
+
+// Metrics holds collectors of the service. Every instance has its own registry, so metrics are not global.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	// HTTPRequests is labeled by route pattern, method and status code
+	HTTPRequests *prometheus.CounterVec
+	// HTTPDuration is labeled by route pattern and method
+	HTTPDuration *prometheus.HistogramVec
+
+	UploadedBytes   prometheus.Counter
+	DownloadedBytes prometheus.Counter
+	// ActiveUploads is the number of uploads being written by this instance
+	ActiveUploads prometheus.Gauge
+
+	// StorageDuration and StorageErrors are labeled by storage server and operation
+	StorageDuration *prometheus.HistogramVec
+	StorageErrors   *prometheus.CounterVec
+
+	CleanupUploads prometheus.Counter
+	// CleanupParts is labeled by result: cleaned or failed
+	CleanupParts          *prometheus.CounterVec
+	CleanupReclaimedBytes prometheus.Counter
+}
+
+func New() *Metrics {
+	registry := prometheus.NewRegistry()
+	factory := promauto.With(registry)
+
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}), //nolint:exhaustruct
+	)
+
+	return &Metrics{
+		registry: registry,
+		HTTPRequests: factory.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "Number of handled HTTP requests.",
+		}, []string{"route", "method", "code"}),
+		HTTPDuration: factory.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of HTTP requests.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		UploadedBytes: factory.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "upload",
+			Name:      "uploaded_bytes_total",
+			Help:      "Size of committed uploads.",
+		}),
+		DownloadedBytes: factory.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "upload",
+			Name:      "downloaded_bytes_total",
+			Help:      "Bytes of uploads streamed to clients.",
+		}),
+		ActiveUploads: factory.NewGauge(prometheus.GaugeOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "upload",
+			Name:      "active_uploads",
+			Help:      "Number of uploads being written.",
+		}),
+		StorageDuration: factory.NewHistogramVec(prometheus.HistogramOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_duration_seconds",
+			Help:      "Duration of single attempts of storage server calls.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"server", "operation"}),
+		StorageErrors: factory.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "storage",
+			Name:      "operation_errors_total",
+			Help:      "Number of failed attempts of storage server calls.",
+		}, []string{"server", "operation"}),
+		CleanupUploads: factory.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "cleanup",
+			Name:      "uploads_total",
+			Help:      "Number of deleted dangle uploads.",
+		}),
+		CleanupParts: factory.NewCounterVec(prometheus.CounterOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "cleanup",
+			Name:      "parts_total",
+			Help:      "Number of part data cleanups by result.",
+		}, []string{"result"}),
+		CleanupReclaimedBytes: factory.NewCounter(prometheus.CounterOpts{ //nolint:exhaustruct
+			Namespace: namespace,
+			Subsystem: "cleanup",
+			Name:      "reclaimed_bytes_total",
+			Help:      "Size of cleaned part data.",
+		}),
+	}
+}
+
+// Register adds collectors, e.g. of the database pool, to the registry.
+func (m *Metrics) Register(collector prometheus.Collector) error {
+	return m.registry.Register(collector) //nolint:wrapcheck
+}
+
+// Handler serves metrics in the Prometheus format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}) //nolint:exhaustruct
+}
@@ -15,8 +15,12 @@ import (
 type UploadStatus string
 
 const (
-	UploadStatusInProgress UploadStatus = "in_progress"
-	UploadStatusDone       UploadStatus = "done"
+	UploadStatusPending   UploadStatus = "pending"
+	UploadStatusWriting   UploadStatus = "writing"
+	UploadStatusWritten   UploadStatus = "written"
+	UploadStatusCommitted UploadStatus = "committed"
+	UploadStatusFailed    UploadStatus = "failed"
+	UploadStatusAborting  UploadStatus = "aborting"
 )
 
 func (e *UploadStatus) Scan(src interface{}) error {
@@ -54,20 +58,95 @@ func (ns NullUploadStatus) Value() (driver.Value, error) {
 	return string(ns.UploadStatus), nil
 }
 
+type Blob struct {
+	ID             uuid.UUID
+	ServerUrl      string
+	Hash           []byte
+	Codec          string
+	Size           int64
+	CompressedSize int64
+	RefCount       int32
+	CreatedAt      pgtype.Timestamptz
+}
+
+type LifecycleRule struct {
+	ID                        uuid.UUID
+	Bucket                    string
+	Prefix                    string
+	ExpirationDays            int32
+	NoncurrentExpirationDays  int32
+	AbortIncompleteUploadDays int32
+	CreatedAt                 pgtype.Timestamptz
+}
+
 type Part struct {
-	ID        uuid.UUID
+	ID             uuid.UUID
+	ServerUrl      string
+	UploadID       uuid.UUID
+	Number         int32
+	Size           int64
+	CreatedAt      pgtype.Timestamptz
+	Status         UploadStatus
+	Codec          string
+	CompressedSize int64
+	BlobID         uuid.UUID
+	VolumeID       uuid.UUID
+	VolumeOffset   int64
+	VolumeLength   int64
+}
+
+type PartCleanup struct {
+	BlobID    uuid.UUID
 	ServerUrl string
-	UploadID  uuid.UUID
-	Number    int32
 	Size      int64
+	Attempts  int32
+	LastError string
 	CreatedAt pgtype.Timestamptz
-	Status    UploadStatus
+	UpdatedAt pgtype.Timestamptz
+}
+
+type StorageServer struct {
+	Url       string
+	Draining  bool
+	CreatedAt pgtype.Timestamptz
+	UpdatedAt pgtype.Timestamptz
 }
 
 type Upload struct {
+	ID                     uuid.UUID
+	Name                   string
+	Size                   int64
+	Status                 UploadStatus
+	CreatedAt              pgtype.Timestamptz
+	Bucket                 string
+	IsLatest               bool
+	IsDeleteMarker         bool
+	RetainUntil            pgtype.Timestamptz
+	LegalHold              bool
+	EncryptedDataKey       []byte
+	MasterKeyID            string
+	CustomerKeyFingerprint []byte
+	Owner                  string
+	UpdatedAt              pgtype.Timestamptz
+	ContentMd5             []byte
+}
+
+type Volume struct {
 	ID        uuid.UUID
-	Name      string
+	ServerUrl string
 	Size      int64
-	Status    UploadStatus
+	LiveSize  int64
+	Sealed    bool
 	CreatedAt pgtype.Timestamptz
 }
+
+type VolumeCleanup struct {
+	VolumeID    uuid.UUID
+	ServerUrl   string
+	Size        int64
+	DeleteAfter pgtype.Timestamptz
+	Attempts    int32
+	LastError   string
+	CreatedAt   pgtype.Timestamptz
+	UpdatedAt   pgtype.Timestamptz
+}
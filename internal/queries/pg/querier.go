@@ -8,19 +8,79 @@ import (
 	"context"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
+	AcquireBlob(ctx context.Context, arg AcquireBlobParams) (Blob, error)
+	AddVolumeNeedle(ctx context.Context, arg AddVolumeNeedleParams) error
+	ClaimStaleInFlightUploads(ctx context.Context, arg ClaimStaleInFlightUploadsParams) ([]Upload, error)
+	DeleteBucketLifecycleRules(ctx context.Context, bucket string) error
+	DeletePartCleanup(ctx context.Context, blobID uuid.UUID) error
+	DeleteUnreferencedBlob(ctx context.Context, id uuid.UUID) error
 	DeleteUploadsByIds(ctx context.Context, ids []uuid.UUID) error
-	GetOldInProgressParts(ctx context.Context, createdAt pgtype.Timestamptz) ([]Part, error)
-	GetOldInProgressUploads(ctx context.Context, createdAt pgtype.Timestamptz) ([]Upload, error)
+	DeleteVolume(ctx context.Context, id uuid.UUID) error
+	DeleteVolumeCleanup(ctx context.Context, volumeID uuid.UUID) error
+	GetBlob(ctx context.Context, id uuid.UUID) (Blob, error)
+	GetBucketLifecycleRules(ctx context.Context, bucket string) ([]LifecycleRule, error)
+	GetDueVolumeCleanups(ctx context.Context, arg GetDueVolumeCleanupsParams) ([]VolumeCleanup, error)
+	GetExpiredCurrentUploads(ctx context.Context, arg GetExpiredCurrentUploadsParams) ([]Upload, error)
+	GetExpiredDeleteMarkers(ctx context.Context, arg GetExpiredDeleteMarkersParams) ([]Upload, error)
+	GetExpiredNoncurrentUploads(ctx context.Context, arg GetExpiredNoncurrentUploadsParams) ([]Upload, error)
+	GetLatestUpload(ctx context.Context, arg GetLatestUploadParams) (Upload, error)
+	GetNewestUploadVersion(ctx context.Context, arg GetNewestUploadVersionParams) (Upload, error)
+	GetOldInFlightUploads(ctx context.Context, arg GetOldInFlightUploadsParams) ([]Upload, error)
+	GetOldIncompleteUploads(ctx context.Context, arg GetOldIncompleteUploadsParams) ([]Upload, error)
+	GetOwnedInFlightUploads(ctx context.Context, owner string) ([]Upload, error)
+	GetPartCleanups(ctx context.Context, arg GetPartCleanupsParams) ([]PartCleanup, error)
+	GetServerBlobIDs(ctx context.Context, arg GetServerBlobIDsParams) ([]uuid.UUID, error)
+	GetServerPartStats(ctx context.Context) ([]GetServerPartStatsRow, error)
+	GetServerVolumes(ctx context.Context, serverUrl string) ([]Volume, error)
+	GetStorageServers(ctx context.Context) ([]StorageServer, error)
+	GetStoredBlobIDs(ctx context.Context, arg GetStoredBlobIDsParams) ([]uuid.UUID, error)
+	GetStoredVolumeIDs(ctx context.Context, arg GetStoredVolumeIDsParams) ([]uuid.UUID, error)
 	GetUpload(ctx context.Context, id uuid.UUID) (Upload, error)
 	GetUploadParts(ctx context.Context, id uuid.UUID) ([]Part, error)
+	GetUploadsWithStaleDataKey(ctx context.Context, arg GetUploadsWithStaleDataKeyParams) ([]Upload, error)
+	GetVolumeParts(ctx context.Context, volumeID uuid.UUID) ([]Part, error)
+	GetVolumesToCompact(ctx context.Context, garbageRatio float64) ([]Volume, error)
+	GetWritableVolume(ctx context.Context, arg GetWritableVolumeParams) (Volume, error)
+	InsertLifecycleRule(ctx context.Context, arg InsertLifecycleRuleParams) error
 	InsertPart(ctx context.Context, arg InsertPartParams) error
+	InsertPartCleanup(ctx context.Context, arg InsertPartCleanupParams) error
+	InsertStorageServer(ctx context.Context, url string) (int64, error)
 	InsertUpload(ctx context.Context, arg InsertUploadParams) error
-	UpdatePartAsDone(ctx context.Context, id uuid.UUID) error
-	UpdateUploadAsDone(ctx context.Context, id uuid.UUID) error
+	InsertVolume(ctx context.Context, arg InsertVolumeParams) error
+	InsertVolumeCleanup(ctx context.Context, arg InsertVolumeCleanupParams) error
+	ListLifecycleRules(ctx context.Context) ([]LifecycleRule, error)
+	ListObjects(ctx context.Context, arg ListObjectsParams) ([]Upload, error)
+	ListUploadVersions(ctx context.Context, arg ListUploadVersionsParams) ([]Upload, error)
+	ListUploads(ctx context.Context, arg ListUploadsParams) ([]Upload, error)
+	LockUploadKey(ctx context.Context, arg LockUploadKeyParams) error
+	LockVolume(ctx context.Context, id uuid.UUID) (Volume, error)
+	ReferenceBlob(ctx context.Context, arg ReferenceBlobParams) (Blob, error)
+	ReleaseBlob(ctx context.Context, id uuid.UUID) (Blob, error)
+	ReleaseVolumeNeedle(ctx context.Context, arg ReleaseVolumeNeedleParams) error
+	SealVolume(ctx context.Context, id uuid.UUID) error
+	TouchUpload(ctx context.Context, arg TouchUploadParams) (int64, error)
+	UnsetLatestUpload(ctx context.Context, arg UnsetLatestUploadParams) error
+	UpdateBlobServer(ctx context.Context, arg UpdateBlobServerParams) error
+	UpdateOwnedUploadAsFailed(ctx context.Context, arg UpdateOwnedUploadAsFailedParams) (int64, error)
+	UpdatePartAsPacked(ctx context.Context, arg UpdatePartAsPackedParams) error
+	UpdatePartAsWritten(ctx context.Context, arg UpdatePartAsWrittenParams) error
+	UpdatePartCleanupFailure(ctx context.Context, arg UpdatePartCleanupFailureParams) error
+	UpdatePartStatus(ctx context.Context, arg UpdatePartStatusParams) error
+	UpdatePartVolume(ctx context.Context, arg UpdatePartVolumeParams) error
+	UpdatePartsServer(ctx context.Context, arg UpdatePartsServerParams) (int64, error)
+	UpdateUploadAsCommitted(ctx context.Context, arg UpdateUploadAsCommittedParams) (int64, error)
+	UpdateUploadAsLatest(ctx context.Context, id uuid.UUID) error
+	UpdateUploadAsWritten(ctx context.Context, arg UpdateUploadAsWrittenParams) (int64, error)
+	UpdateUploadDataKey(ctx context.Context, arg UpdateUploadDataKeyParams) error
+	UpdateUploadLegalHold(ctx context.Context, arg UpdateUploadLegalHoldParams) error
+	UpdateUploadPartsAsCommitted(ctx context.Context, uploadID uuid.UUID) error
+	UpdateUploadRetention(ctx context.Context, arg UpdateUploadRetentionParams) error
+	UpdateUploadStatus(ctx context.Context, arg UpdateUploadStatusParams) (int64, error)
+	UpdateVolumeCleanupFailure(ctx context.Context, arg UpdateVolumeCleanupFailureParams) error
+	UpsertStorageServer(ctx context.Context, arg UpsertStorageServerParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -12,6 +12,58 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const claimStaleInFlightUploads = `-- name: ClaimStaleInFlightUploads :many
+update uploads set owner = $1, updated_at = now()
+where id in (
+    select id from uploads
+    where owner <> $1 and status <> 'committed' and updated_at < $2
+    for update skip locked
+)
+returning id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5
+`
+
+type ClaimStaleInFlightUploadsParams struct {
+	Owner     string
+	UpdatedAt pgtype.Timestamptz
+}
+
+func (q *Queries) ClaimStaleInFlightUploads(ctx context.Context, arg ClaimStaleInFlightUploadsParams) ([]Upload, error) {
+	rows, err := q.db.Query(ctx, claimStaleInFlightUploads, arg.Owner, arg.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Upload
+	for rows.Next() {
+		var i Upload
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Size,
+			&i.Status,
+			&i.CreatedAt,
+			&i.Bucket,
+			&i.IsLatest,
+			&i.IsDeleteMarker,
+			&i.RetainUntil,
+			&i.LegalHold,
+			&i.EncryptedDataKey,
+			&i.MasterKeyID,
+			&i.CustomerKeyFingerprint,
+			&i.Owner,
+			&i.UpdatedAt,
+			&i.ContentMd5,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const deleteUploadsByIds = `-- name: DeleteUploadsByIds :exec
 delete from uploads where id = ANY($1::uuid[])
 `
@@ -21,27 +73,114 @@ func (q *Queries) DeleteUploadsByIds(ctx context.Context, ids []uuid.UUID) error
 	return err
 }
 
-const getOldInProgressParts = `-- name: GetOldInProgressParts :many
-select id, server_url, upload_id, number, size, created_at, status from parts where created_at < $1 and status = 'in_progress'
+const getLatestUpload = `-- name: GetLatestUpload :one
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads where bucket = $1 and name = $2 and is_latest
+`
+
+type GetLatestUploadParams struct {
+	Bucket string
+	Name   string
+}
+
+func (q *Queries) GetLatestUpload(ctx context.Context, arg GetLatestUploadParams) (Upload, error) {
+	row := q.db.QueryRow(ctx, getLatestUpload, arg.Bucket, arg.Name)
+	var i Upload
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Size,
+		&i.Status,
+		&i.CreatedAt,
+		&i.Bucket,
+		&i.IsLatest,
+		&i.IsDeleteMarker,
+		&i.RetainUntil,
+		&i.LegalHold,
+		&i.EncryptedDataKey,
+		&i.MasterKeyID,
+		&i.CustomerKeyFingerprint,
+		&i.Owner,
+		&i.UpdatedAt,
+		&i.ContentMd5,
+	)
+	return i, err
+}
+
+const getNewestUploadVersion = `-- name: GetNewestUploadVersion :one
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads
+where bucket = $1 and name = $2 and status = 'committed'
+order by created_at desc, id desc
+limit 1
 `
 
-func (q *Queries) GetOldInProgressParts(ctx context.Context, createdAt pgtype.Timestamptz) ([]Part, error) {
-	rows, err := q.db.Query(ctx, getOldInProgressParts, createdAt)
+type GetNewestUploadVersionParams struct {
+	Bucket string
+	Name   string
+}
+
+func (q *Queries) GetNewestUploadVersion(ctx context.Context, arg GetNewestUploadVersionParams) (Upload, error) {
+	row := q.db.QueryRow(ctx, getNewestUploadVersion, arg.Bucket, arg.Name)
+	var i Upload
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Size,
+		&i.Status,
+		&i.CreatedAt,
+		&i.Bucket,
+		&i.IsLatest,
+		&i.IsDeleteMarker,
+		&i.RetainUntil,
+		&i.LegalHold,
+		&i.EncryptedDataKey,
+		&i.MasterKeyID,
+		&i.CustomerKeyFingerprint,
+		&i.Owner,
+		&i.UpdatedAt,
+		&i.ContentMd5,
+	)
+	return i, err
+}
+
+const getOldInFlightUploads = `-- name: GetOldInFlightUploads :many
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads
+where created_at < $1 and status <> 'committed' and id > $2
+order by id
+limit $3
+`
+
+type GetOldInFlightUploadsParams struct {
+	CreatedAt pgtype.Timestamptz
+	After     uuid.UUID
+	MaxCount  int32
+}
+
+func (q *Queries) GetOldInFlightUploads(ctx context.Context, arg GetOldInFlightUploadsParams) ([]Upload, error) {
+	rows, err := q.db.Query(ctx, getOldInFlightUploads, arg.CreatedAt, arg.After, arg.MaxCount)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Part
+	var items []Upload
 	for rows.Next() {
-		var i Part
+		var i Upload
 		if err := rows.Scan(
 			&i.ID,
-			&i.ServerUrl,
-			&i.UploadID,
-			&i.Number,
+			&i.Name,
 			&i.Size,
-			&i.CreatedAt,
 			&i.Status,
+			&i.CreatedAt,
+			&i.Bucket,
+			&i.IsLatest,
+			&i.IsDeleteMarker,
+			&i.RetainUntil,
+			&i.LegalHold,
+			&i.EncryptedDataKey,
+			&i.MasterKeyID,
+			&i.CustomerKeyFingerprint,
+			&i.Owner,
+			&i.UpdatedAt,
+			&i.ContentMd5,
 		); err != nil {
 			return nil, err
 		}
@@ -53,12 +192,12 @@ func (q *Queries) GetOldInProgressParts(ctx context.Context, createdAt pgtype.Ti
 	return items, nil
 }
 
-const getOldInProgressUploads = `-- name: GetOldInProgressUploads :many
-select id, name, size, status, created_at from uploads where created_at < $1 and status = 'in_progress'
+const getOwnedInFlightUploads = `-- name: GetOwnedInFlightUploads :many
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads where owner = $1 and status <> 'committed'
 `
 
-func (q *Queries) GetOldInProgressUploads(ctx context.Context, createdAt pgtype.Timestamptz) ([]Upload, error) {
-	rows, err := q.db.Query(ctx, getOldInProgressUploads, createdAt)
+func (q *Queries) GetOwnedInFlightUploads(ctx context.Context, owner string) ([]Upload, error) {
+	rows, err := q.db.Query(ctx, getOwnedInFlightUploads, owner)
 	if err != nil {
 		return nil, err
 	}
@@ -72,6 +211,87 @@ func (q *Queries) GetOldInProgressUploads(ctx context.Context, createdAt pgtype.
 			&i.Size,
 			&i.Status,
 			&i.CreatedAt,
+			&i.Bucket,
+			&i.IsLatest,
+			&i.IsDeleteMarker,
+			&i.RetainUntil,
+			&i.LegalHold,
+			&i.EncryptedDataKey,
+			&i.MasterKeyID,
+			&i.CustomerKeyFingerprint,
+			&i.Owner,
+			&i.UpdatedAt,
+			&i.ContentMd5,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getServerBlobIDs = `-- name: GetServerBlobIDs :many
+select distinct blob_id from parts
+where server_url = $1 and volume_id = '00000000-0000-0000-0000-000000000000'
+	and status in ('written', 'committed') and blob_id > $2
+order by blob_id
+limit $3
+`
+
+type GetServerBlobIDsParams struct {
+	ServerUrl string
+	After     uuid.UUID
+	MaxCount  int32
+}
+
+func (q *Queries) GetServerBlobIDs(ctx context.Context, arg GetServerBlobIDsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.Query(ctx, getServerBlobIDs, arg.ServerUrl, arg.After, arg.MaxCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var blob_id uuid.UUID
+		if err := rows.Scan(&blob_id); err != nil {
+			return nil, err
+		}
+		items = append(items, blob_id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getServerPartStats = `-- name: GetServerPartStats :many
+select server_url, count(*) as parts, coalesce(sum(compressed_size), 0)::bigint as bytes
+from parts
+group by server_url
+`
+
+type GetServerPartStatsRow struct {
+	ServerUrl string
+	Parts     int64
+	Bytes     int64
+}
+
+func (q *Queries) GetServerPartStats(ctx context.Context) ([]GetServerPartStatsRow, error) {
+	rows, err := q.db.Query(ctx, getServerPartStats)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetServerPartStatsRow
+	for rows.Next() {
+		var i GetServerPartStatsRow
+		if err := rows.Scan(
+			&i.ServerUrl,
+			&i.Parts,
+			&i.Bytes,
 		); err != nil {
 			return nil, err
 		}
@@ -84,7 +304,7 @@ func (q *Queries) GetOldInProgressUploads(ctx context.Context, createdAt pgtype.
 }
 
 const getUpload = `-- name: GetUpload :one
-select id, name, size, status, created_at from uploads where id = $1
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads where id = $1
 `
 
 func (q *Queries) GetUpload(ctx context.Context, id uuid.UUID) (Upload, error) {
@@ -96,12 +316,23 @@ func (q *Queries) GetUpload(ctx context.Context, id uuid.UUID) (Upload, error) {
 		&i.Size,
 		&i.Status,
 		&i.CreatedAt,
+		&i.Bucket,
+		&i.IsLatest,
+		&i.IsDeleteMarker,
+		&i.RetainUntil,
+		&i.LegalHold,
+		&i.EncryptedDataKey,
+		&i.MasterKeyID,
+		&i.CustomerKeyFingerprint,
+		&i.Owner,
+		&i.UpdatedAt,
+		&i.ContentMd5,
 	)
 	return i, err
 }
 
 const getUploadParts = `-- name: GetUploadParts :many
-select id, server_url, upload_id, number, size, created_at, status from parts where upload_id = $1
+select id, server_url, upload_id, number, size, created_at, status, codec, compressed_size, blob_id, volume_id, volume_offset, volume_length from parts where upload_id = $1 order by number
 `
 
 func (q *Queries) GetUploadParts(ctx context.Context, id uuid.UUID) ([]Part, error) {
@@ -121,6 +352,60 @@ func (q *Queries) GetUploadParts(ctx context.Context, id uuid.UUID) ([]Part, err
 			&i.Size,
 			&i.CreatedAt,
 			&i.Status,
+			&i.Codec,
+			&i.CompressedSize,
+			&i.BlobID,
+			&i.VolumeID,
+			&i.VolumeOffset,
+			&i.VolumeLength,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUploadsWithStaleDataKey = `-- name: GetUploadsWithStaleDataKey :many
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads
+where encrypted_data_key is not null and master_key_id <> $1
+limit $2
+`
+
+type GetUploadsWithStaleDataKeyParams struct {
+	MasterKeyID string
+	MaxCount    int32
+}
+
+func (q *Queries) GetUploadsWithStaleDataKey(ctx context.Context, arg GetUploadsWithStaleDataKeyParams) ([]Upload, error) {
+	rows, err := q.db.Query(ctx, getUploadsWithStaleDataKey, arg.MasterKeyID, arg.MaxCount)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Upload
+	for rows.Next() {
+		var i Upload
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Size,
+			&i.Status,
+			&i.CreatedAt,
+			&i.Bucket,
+			&i.IsLatest,
+			&i.IsDeleteMarker,
+			&i.RetainUntil,
+			&i.LegalHold,
+			&i.EncryptedDataKey,
+			&i.MasterKeyID,
+			&i.CustomerKeyFingerprint,
+			&i.Owner,
+			&i.UpdatedAt,
+			&i.ContentMd5,
 		); err != nil {
 			return nil, err
 		}
@@ -133,8 +418,8 @@ func (q *Queries) GetUploadParts(ctx context.Context, id uuid.UUID) ([]Part, err
 }
 
 const insertPart = `-- name: InsertPart :exec
-insert into parts (id, server_url, upload_id, number, size, status)
-values ($1, $2, $3, $4, $5, $6)
+insert into parts (id, server_url, upload_id, number, size, codec, blob_id, status)
+values ($1, $2, $3, $4, $5, $6, $7, $8)
 `
 
 type InsertPartParams struct {
@@ -143,6 +428,8 @@ type InsertPartParams struct {
 	UploadID  uuid.UUID
 	Number    int32
 	Size      int64
+	Codec     string
+	BlobID    uuid.UUID
 	Status    UploadStatus
 }
 
@@ -153,49 +440,447 @@ func (q *Queries) InsertPart(ctx context.Context, arg InsertPartParams) error {
 		arg.UploadID,
 		arg.Number,
 		arg.Size,
+		arg.Codec,
+		arg.BlobID,
 		arg.Status,
 	)
 	return err
 }
 
 const insertUpload = `-- name: InsertUpload :exec
-insert into uploads (id, name, size, status, created_at)
-values ($1, $2, $3, $4, $5)
+insert into uploads (
+	id, bucket, name, size, status, is_latest, is_delete_marker,
+	encrypted_data_key, master_key_id, customer_key_fingerprint, owner, created_at, updated_at
+)
+values (
+	$1, $2, $3, $4, $5, $6, $7,
+	$8, $9, $10, $11, $12, $12
+)
 `
 
 type InsertUploadParams struct {
-	ID        uuid.UUID
-	Name      string
-	Size      int64
-	Status    UploadStatus
-	CreatedAt pgtype.Timestamptz
+	ID                     uuid.UUID
+	Bucket                 string
+	Name                   string
+	Size                   int64
+	Status                 UploadStatus
+	IsLatest               bool
+	IsDeleteMarker         bool
+	EncryptedDataKey       []byte
+	MasterKeyID            string
+	CustomerKeyFingerprint []byte
+	Owner                  string
+	CreatedAt              pgtype.Timestamptz
 }
 
 func (q *Queries) InsertUpload(ctx context.Context, arg InsertUploadParams) error {
 	_, err := q.db.Exec(ctx, insertUpload,
 		arg.ID,
+		arg.Bucket,
 		arg.Name,
 		arg.Size,
 		arg.Status,
+		arg.IsLatest,
+		arg.IsDeleteMarker,
+		arg.EncryptedDataKey,
+		arg.MasterKeyID,
+		arg.CustomerKeyFingerprint,
+		arg.Owner,
 		arg.CreatedAt,
 	)
 	return err
 }
 
-const updatePartAsDone = `-- name: UpdatePartAsDone :exec
-update parts set status = 'done' where id = $1
+const listObjects = `-- name: ListObjects :many
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads
+where bucket = $1 and is_latest and not is_delete_marker and starts_with(name, $2::text) and name > $3::text
+order by name
+limit $4
 `
 
-func (q *Queries) UpdatePartAsDone(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.Exec(ctx, updatePartAsDone, id)
+type ListObjectsParams struct {
+	Bucket   string
+	Prefix   string
+	After    string
+	MaxCount int32
+}
+
+func (q *Queries) ListObjects(ctx context.Context, arg ListObjectsParams) ([]Upload, error) {
+	rows, err := q.db.Query(ctx, listObjects,
+		arg.Bucket,
+		arg.Prefix,
+		arg.After,
+		arg.MaxCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Upload
+	for rows.Next() {
+		var i Upload
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Size,
+			&i.Status,
+			&i.CreatedAt,
+			&i.Bucket,
+			&i.IsLatest,
+			&i.IsDeleteMarker,
+			&i.RetainUntil,
+			&i.LegalHold,
+			&i.EncryptedDataKey,
+			&i.MasterKeyID,
+			&i.CustomerKeyFingerprint,
+			&i.Owner,
+			&i.UpdatedAt,
+			&i.ContentMd5,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUploadVersions = `-- name: ListUploadVersions :many
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads
+where bucket = $1 and name = $2 and status = 'committed'
+order by created_at desc, id desc
+`
+
+type ListUploadVersionsParams struct {
+	Bucket string
+	Name   string
+}
+
+func (q *Queries) ListUploadVersions(ctx context.Context, arg ListUploadVersionsParams) ([]Upload, error) {
+	rows, err := q.db.Query(ctx, listUploadVersions, arg.Bucket, arg.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Upload
+	for rows.Next() {
+		var i Upload
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Size,
+			&i.Status,
+			&i.CreatedAt,
+			&i.Bucket,
+			&i.IsLatest,
+			&i.IsDeleteMarker,
+			&i.RetainUntil,
+			&i.LegalHold,
+			&i.EncryptedDataKey,
+			&i.MasterKeyID,
+			&i.CustomerKeyFingerprint,
+			&i.Owner,
+			&i.UpdatedAt,
+			&i.ContentMd5,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUploads = `-- name: ListUploads :many
+select id, name, size, status, created_at, bucket, is_latest, is_delete_marker, retain_until, legal_hold, encrypted_data_key, master_key_id, customer_key_fingerprint, owner, updated_at, content_md5 from uploads
+where ($1::text = '' or bucket = $1) and starts_with(name, $2::text) and id > $3
+order by id
+limit $4
+`
+
+type ListUploadsParams struct {
+	Bucket   string
+	Prefix   string
+	After    uuid.UUID
+	MaxCount int32
+}
+
+func (q *Queries) ListUploads(ctx context.Context, arg ListUploadsParams) ([]Upload, error) {
+	rows, err := q.db.Query(ctx, listUploads,
+		arg.Bucket,
+		arg.Prefix,
+		arg.After,
+		arg.MaxCount,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Upload
+	for rows.Next() {
+		var i Upload
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Size,
+			&i.Status,
+			&i.CreatedAt,
+			&i.Bucket,
+			&i.IsLatest,
+			&i.IsDeleteMarker,
+			&i.RetainUntil,
+			&i.LegalHold,
+			&i.EncryptedDataKey,
+			&i.MasterKeyID,
+			&i.CustomerKeyFingerprint,
+			&i.Owner,
+			&i.UpdatedAt,
+			&i.ContentMd5,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockUploadKey = `-- name: LockUploadKey :exec
+select pg_advisory_xact_lock(hashtextextended($1::text || '/' || $2::text, 0))
+`
+
+type LockUploadKeyParams struct {
+	Bucket string
+	Name   string
+}
+
+func (q *Queries) LockUploadKey(ctx context.Context, arg LockUploadKeyParams) error {
+	_, err := q.db.Exec(ctx, lockUploadKey, arg.Bucket, arg.Name)
 	return err
 }
 
-const updateUploadAsDone = `-- name: UpdateUploadAsDone :exec
-update uploads set status = 'done' where id = $1
+const touchUpload = `-- name: TouchUpload :execrows
+update uploads set updated_at = now() where id = $1 and owner = $2 and status = 'writing'
 `
 
-func (q *Queries) UpdateUploadAsDone(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.Exec(ctx, updateUploadAsDone, id)
+type TouchUploadParams struct {
+	ID    uuid.UUID
+	Owner string
+}
+
+func (q *Queries) TouchUpload(ctx context.Context, arg TouchUploadParams) (int64, error) {
+	result, err := q.db.Exec(ctx, touchUpload, arg.ID, arg.Owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const unsetLatestUpload = `-- name: UnsetLatestUpload :exec
+update uploads set is_latest = false where bucket = $1 and name = $2 and is_latest
+`
+
+type UnsetLatestUploadParams struct {
+	Bucket string
+	Name   string
+}
+
+func (q *Queries) UnsetLatestUpload(ctx context.Context, arg UnsetLatestUploadParams) error {
+	_, err := q.db.Exec(ctx, unsetLatestUpload, arg.Bucket, arg.Name)
 	return err
 }
+
+const updateOwnedUploadAsFailed = `-- name: UpdateOwnedUploadAsFailed :execrows
+update uploads set status = 'failed', updated_at = now()
+where id = $1 and owner = $2 and status in ('pending', 'writing', 'written')
+`
+
+type UpdateOwnedUploadAsFailedParams struct {
+	ID    uuid.UUID
+	Owner string
+}
+
+func (q *Queries) UpdateOwnedUploadAsFailed(ctx context.Context, arg UpdateOwnedUploadAsFailedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateOwnedUploadAsFailed, arg.ID, arg.Owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updatePartAsWritten = `-- name: UpdatePartAsWritten :exec
+update parts
+set status = 'written', compressed_size = $1, blob_id = $2, server_url = $3
+where id = $4
+`
+
+type UpdatePartAsWrittenParams struct {
+	CompressedSize int64
+	BlobID         uuid.UUID
+	ServerUrl      string
+	ID             uuid.UUID
+}
+
+func (q *Queries) UpdatePartAsWritten(ctx context.Context, arg UpdatePartAsWrittenParams) error {
+	_, err := q.db.Exec(ctx, updatePartAsWritten,
+		arg.CompressedSize,
+		arg.BlobID,
+		arg.ServerUrl,
+		arg.ID,
+	)
+	return err
+}
+
+const updatePartStatus = `-- name: UpdatePartStatus :exec
+update parts set status = $1 where id = $2
+`
+
+type UpdatePartStatusParams struct {
+	Status UploadStatus
+	ID     uuid.UUID
+}
+
+func (q *Queries) UpdatePartStatus(ctx context.Context, arg UpdatePartStatusParams) error {
+	_, err := q.db.Exec(ctx, updatePartStatus, arg.Status, arg.ID)
+	return err
+}
+
+const updatePartsServer = `-- name: UpdatePartsServer :execrows
+update parts set server_url = $1
+where blob_id = $2 and server_url = $3 and volume_id = '00000000-0000-0000-0000-000000000000'
+`
+
+type UpdatePartsServerParams struct {
+	NewServerUrl string
+	BlobID       uuid.UUID
+	ServerUrl    string
+}
+
+func (q *Queries) UpdatePartsServer(ctx context.Context, arg UpdatePartsServerParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updatePartsServer, arg.NewServerUrl, arg.BlobID, arg.ServerUrl)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUploadAsCommitted = `-- name: UpdateUploadAsCommitted :execrows
+update uploads set status = 'committed', updated_at = now() where id = $1 and owner = $2 and status = 'written'
+`
+
+type UpdateUploadAsCommittedParams struct {
+	ID    uuid.UUID
+	Owner string
+}
+
+func (q *Queries) UpdateUploadAsCommitted(ctx context.Context, arg UpdateUploadAsCommittedParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUploadAsCommitted, arg.ID, arg.Owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUploadAsLatest = `-- name: UpdateUploadAsLatest :exec
+update uploads set is_latest = true where id = $1
+`
+
+func (q *Queries) UpdateUploadAsLatest(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, updateUploadAsLatest, id)
+	return err
+}
+
+const updateUploadAsWritten = `-- name: UpdateUploadAsWritten :execrows
+update uploads set status = 'written', content_md5 = $1, updated_at = now()
+where id = $2 and owner = $3 and status = 'writing'
+`
+
+type UpdateUploadAsWrittenParams struct {
+	ContentMd5 []byte
+	ID         uuid.UUID
+	Owner      string
+}
+
+func (q *Queries) UpdateUploadAsWritten(ctx context.Context, arg UpdateUploadAsWrittenParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUploadAsWritten, arg.ContentMd5, arg.ID, arg.Owner)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updateUploadDataKey = `-- name: UpdateUploadDataKey :exec
+update uploads set encrypted_data_key = $1, master_key_id = $2 where id = $3
+`
+
+type UpdateUploadDataKeyParams struct {
+	EncryptedDataKey []byte
+	MasterKeyID      string
+	ID               uuid.UUID
+}
+
+func (q *Queries) UpdateUploadDataKey(ctx context.Context, arg UpdateUploadDataKeyParams) error {
+	_, err := q.db.Exec(ctx, updateUploadDataKey, arg.EncryptedDataKey, arg.MasterKeyID, arg.ID)
+	return err
+}
+
+const updateUploadLegalHold = `-- name: UpdateUploadLegalHold :exec
+update uploads set legal_hold = $1 where id = $2
+`
+
+type UpdateUploadLegalHoldParams struct {
+	LegalHold bool
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateUploadLegalHold(ctx context.Context, arg UpdateUploadLegalHoldParams) error {
+	_, err := q.db.Exec(ctx, updateUploadLegalHold, arg.LegalHold, arg.ID)
+	return err
+}
+
+const updateUploadPartsAsCommitted = `-- name: UpdateUploadPartsAsCommitted :exec
+update parts set status = 'committed' where upload_id = $1
+`
+
+func (q *Queries) UpdateUploadPartsAsCommitted(ctx context.Context, uploadID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, updateUploadPartsAsCommitted, uploadID)
+	return err
+}
+
+const updateUploadRetention = `-- name: UpdateUploadRetention :exec
+update uploads set retain_until = $1 where id = $2
+`
+
+type UpdateUploadRetentionParams struct {
+	RetainUntil pgtype.Timestamptz
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateUploadRetention(ctx context.Context, arg UpdateUploadRetentionParams) error {
+	_, err := q.db.Exec(ctx, updateUploadRetention, arg.RetainUntil, arg.ID)
+	return err
+}
+
+const updateUploadStatus = `-- name: UpdateUploadStatus :execrows
+update uploads set status = $1, updated_at = now() where id = $2 and status = $3
+`
+
+type UpdateUploadStatusParams struct {
+	Status         UploadStatus
+	ID             uuid.UUID
+	ExpectedStatus UploadStatus
+}
+
+func (q *Queries) UpdateUploadStatus(ctx context.Context, arg UpdateUploadStatusParams) (int64, error) {
+	result, err := q.db.Exec(ctx, updateUploadStatus, arg.Status, arg.ID, arg.ExpectedStatus)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pressly/goose/v3"
+	"github.com/quolpr/distributeds3/internal/service/storage"
+)
+
+var (
+	ErrPendingMigrations       = errors.New("migrations are not applied")
+	ErrNotEnoughStorageServers = errors.New("not enough healthy storage servers")
+)
+
+// Service checks whether the instance can serve requests.
+type Service struct {
+	db         *pgxpool.Pool
+	migrations *goose.Provider
+	storage    *storage.Service
+	// minStorageServers is the number of storage servers with closed or half-open breakers the instance needs
+	minStorageServers int
+}
+
+func NewService(
+	db *pgxpool.Pool, migrations *goose.Provider, storage *storage.Service, minStorageServers int,
+) *Service {
+	return &Service{
+		db:                db,
+		migrations:        migrations,
+		storage:           storage,
+		minStorageServers: minStorageServers,
+	}
+}
+
+// Check is a result of a single readiness check, Err is nil if it passed.
+type Check struct {
+	Name string
+	Err  error
+}
+
+// Ready runs all readiness checks and reports whether all of them passed.
+func (s *Service) Ready(ctx context.Context) ([]Check, bool) {
+	checks := []Check{
+		{Name: "database", Err: s.checkDatabase(ctx)},
+		{Name: "migrations", Err: s.checkMigrations(ctx)},
+		{Name: "storage", Err: s.checkStorage(ctx)},
+	}
+
+	for _, check := range checks {
+		if check.Err != nil {
+			return checks, false
+		}
+	}
+
+	return checks, true
+}
+
+func (s *Service) checkDatabase(ctx context.Context) error {
+	if err := s.db.Ping(ctx); err != nil {
+		return fmt.Errorf("unable to ping database: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) checkMigrations(ctx context.Context) error {
+	pending, err := s.migrations.HasPending(ctx)
+
+	if err != nil {
+		return fmt.Errorf("unable to check migrations: %w", err)
+	}
+
+	if pending {
+		return ErrPendingMigrations
+	}
+
+	return nil
+}
+
+func (s *Service) checkStorage(ctx context.Context) error {
+	servers, err := s.storage.ServersHealth(ctx)
+
+	if err != nil {
+		return fmt.Errorf("unable to get storage servers: %w", err)
+	}
+
+	healthy := 0
+
+	for _, server := range servers {
+		if server.State != storage.BreakerOpen {
+			healthy++
+		}
+	}
+
+	if healthy < s.minStorageServers {
+		return fmt.Errorf("%w: %d of %d required", ErrNotEnoughStorageServers, healthy, s.minStorageServers)
+	}
+
+	return nil
+}
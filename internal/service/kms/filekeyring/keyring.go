@@ -0,0 +1,294 @@
+package filekeyring
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/quolpr/distributeds3/pkg/encryption"
+)
+
+var ErrKeyNotFound = errors.New("master key not found")
+
+type keyringFile struct {
+	ActiveKeyID string            `json:"active_key_id"`
+	Keys        map[string][]byte `json:"keys"`
+}
+
+// Keyring is a KMS that keeps master keys in a local JSON file. Rotated keys stay in the file,
+// so data keys wrapped by them still can be unwrapped.
+//
+// The active key is cached: a process sharing the file picks up a key rotated by another one
+// only after Reload. Rotations of processes sharing the file are serialized by a lock file next to it.
+type Keyring struct {
+	path string
+
+	mu   sync.RWMutex
+	file keyringFile
+}
+
+// Open loads the keyring from the path. A keyring with a fresh master key is created if the file doesn't exist.
+func Open(path string) (*Keyring, error) {
+	k := &Keyring{ //nolint:exhaustruct
+		path: path,
+	}
+
+	err := k.load()
+
+	if errors.Is(err, fs.ErrNotExist) {
+		_, err = k.Rotate()
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (k *Keyring) ActiveKeyID(_ context.Context) (string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.file.ActiveKeyID, nil
+}
+
+func (k *Keyring) Wrap(_ context.Context, dataKey []byte) (string, []byte, error) {
+	k.mu.RLock()
+	keyID := k.file.ActiveKeyID
+	masterKey := k.file.Keys[keyID]
+	k.mu.RUnlock()
+
+	aead, err := newAEAD(masterKey)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return keyID, aead.Seal(nonce, nonce, dataKey, []byte(keyID)), nil
+}
+
+func (k *Keyring) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	masterKey, err := k.masterKey(keyID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(masterKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, fmt.Errorf("wrapped key is too short")
+	}
+
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+
+	return dataKey, nil
+}
+
+// Rotate generates a new master key and makes it active. Previous keys are kept for unwrapping.
+// The file is reloaded under the lock before it is written, so keys added by other processes are not lost.
+func (k *Keyring) Rotate() (string, error) {
+	masterKey, err := encryption.NewDataKey()
+
+	if err != nil {
+		return "", err //nolint:wrapcheck
+	}
+
+	unlock, err := k.lockFile()
+
+	if err != nil {
+		return "", err
+	}
+
+	defer unlock()
+
+	current, err := k.read()
+
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	file := keyringFile{
+		ActiveKeyID: fmt.Sprintf("key-%d", time.Now().UnixNano()),
+		Keys:        make(map[string][]byte, len(current.Keys)+1),
+	}
+
+	for id, key := range current.Keys {
+		file.Keys[id] = key
+	}
+
+	file.Keys[file.ActiveKeyID] = masterKey
+
+	if err := k.write(file); err != nil {
+		return "", err
+	}
+
+	k.mu.Lock()
+	k.file = file
+	k.mu.Unlock()
+
+	return file.ActiveKeyID, nil
+}
+
+// Reload reads the file again, so a master key rotated by another process becomes active.
+func (k *Keyring) Reload() error {
+	return k.load()
+}
+
+// masterKey returns the key by ID. The file is reloaded on a miss, because
+// the key could be rotated by another process.
+func (k *Keyring) masterKey(keyID string) ([]byte, error) {
+	k.mu.RLock()
+	masterKey, ok := k.file.Keys[keyID]
+	k.mu.RUnlock()
+
+	if ok {
+		return masterKey, nil
+	}
+
+	if err := k.load(); err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	masterKey, ok = k.file.Keys[keyID]
+
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+
+	return masterKey, nil
+}
+
+func (k *Keyring) load() error {
+	file, err := k.read()
+
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	k.file = file
+	k.mu.Unlock()
+
+	return nil
+}
+
+func (k *Keyring) read() (keyringFile, error) {
+	content, err := os.ReadFile(k.path)
+
+	if err != nil {
+		return keyringFile{}, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	var file keyringFile
+
+	if err := json.Unmarshal(content, &file); err != nil {
+		return keyringFile{}, fmt.Errorf("failed to parse keyring: %w", err)
+	}
+
+	if _, ok := file.Keys[file.ActiveKeyID]; !ok {
+		return keyringFile{}, fmt.Errorf("%w: active key %s", ErrKeyNotFound, file.ActiveKeyID)
+	}
+
+	return file, nil
+}
+
+// write replaces the file atomically, readers never see a partly written keyring.
+func (k *Keyring) write(file keyringFile) error {
+	content, err := json.MarshalIndent(file, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("failed to marshal keyring: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(k.path), filepath.Base(k.path)+".tmp-*")
+
+	if err != nil {
+		return fmt.Errorf("failed to create keyring: %w", err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(content)
+
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to write keyring: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), k.path); err != nil {
+		return fmt.Errorf("failed to replace keyring: %w", err)
+	}
+
+	return nil
+}
+
+// lockFile takes an exclusive lock on the lock file of the keyring, it is held till unlock is called.
+func (k *Keyring) lockFile() (func(), error) {
+	lock, err := os.OpenFile(k.path+".lock", os.O_CREATE|os.O_RDWR, 0o600) //nolint:gomnd
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyring lock: %w", err)
+	}
+
+	if err := lockExclusive(lock); err != nil {
+		lock.Close()
+
+		return nil, fmt.Errorf("failed to lock keyring: %w", err)
+	}
+
+	return func() {
+		_ = unlock(lock)
+		lock.Close()
+	}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+
+	return aead, nil
+}
@@ -0,0 +1,97 @@
+package filekeyring
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateKeepsKeysOfOtherInstances(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keyring.json")
+
+	first, err := Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyID, wrapped, err := first.Wrap(ctx, []byte("data key"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both instances rotate from their cached copies, neither key may be lost
+	firstRotated, err := first.Rotate()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secondRotated, err := second.Rotate()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{keyID, firstRotated, secondRotated} {
+		if _, err := reopened.masterKey(id); err != nil {
+			t.Errorf("key %s is lost: %v", id, err)
+		}
+	}
+
+	dataKey, err := reopened.Unwrap(ctx, keyID, wrapped)
+
+	if err != nil || !bytes.Equal(dataKey, []byte("data key")) {
+		t.Errorf("unable to unwrap with the first key: %v", err)
+	}
+}
+
+func TestReloadPicksUpRotatedKey(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "keyring.json")
+
+	server, err := Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotator, err := Open(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := rotator.Rotate()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if active, _ := server.ActiveKeyID(ctx); active == rotated {
+		t.Fatal("active key changed without reload")
+	}
+
+	if err := server.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if active, _ := server.ActiveKeyID(ctx); active != rotated {
+		t.Errorf("expected active key %s after reload, got %s", rotated, active)
+	}
+}
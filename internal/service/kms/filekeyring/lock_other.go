@@ -0,0 +1,15 @@
+//go:build !unix
+
+package filekeyring
+
+import "os"
+
+// lockExclusive is a no-op on platforms without flock, so rotations by several processes
+// sharing the keyring file are not serialized there.
+func lockExclusive(_ *os.File) error {
+	return nil
+}
+
+func unlock(_ *os.File) error {
+	return nil
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package filekeyring
+
+import (
+	"os"
+	"syscall"
+)
+
+func lockExclusive(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_EX) //nolint:wrapcheck
+}
+
+func unlock(file *os.File) error {
+	return syscall.Flock(int(file.Fd()), syscall.LOCK_UN) //nolint:wrapcheck
+}
@@ -0,0 +1,12 @@
+package kms
+
+import "context"
+
+// KMS wraps data keys with master keys it holds. Master keys never leave the KMS.
+type KMS interface {
+	// Wrap encrypts the data key with the active master key.
+	Wrap(ctx context.Context, dataKey []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypts the data key that was wrapped by the keyID master key.
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+	ActiveKeyID(ctx context.Context) (string, error)
+}
@@ -0,0 +1,22 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Rule describes what happens with objects of the bucket whose keys start with Prefix.
+// Zero value of any of *Days fields disables the corresponding action.
+type Rule struct {
+	ID     uuid.UUID
+	Bucket string
+	Prefix string
+	// ExpirationDays puts a delete marker on top of current versions older than that.
+	ExpirationDays int32
+	// NoncurrentExpirationDays permanently deletes versions that became noncurrent that long ago.
+	NoncurrentExpirationDays int32
+	// AbortIncompleteUploadDays deletes uploads that are still in progress after that time.
+	AbortIncompleteUploadDays int32
+	CreatedAt                 time.Time
+}
@@ -0,0 +1,195 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/quolpr/distributeds3/internal/queries/pg"
+	"github.com/quolpr/distributeds3/internal/service/lifecycle/model"
+	uploadModel "github.com/quolpr/distributeds3/internal/service/upload/model"
+	uploadRepo "github.com/quolpr/distributeds3/internal/service/upload/repo"
+)
+
+type RuleRepo struct {
+	querier pg.Querier
+	// qtx - querier для запуска в транзакционном режиме.
+	qtx pg.QuerierTX
+}
+
+func NewRuleRepo(querierTx pg.QuerierTX) *RuleRepo {
+	return &RuleRepo{
+		querier: querierTx,
+		qtx:     querierTx,
+	}
+}
+
+func (r *RuleRepo) Create(ctx context.Context, rule model.Rule) error {
+	err := r.querier.InsertLifecycleRule(
+		ctx,
+		pg.InsertLifecycleRuleParams{
+			ID:                        rule.ID,
+			Bucket:                    rule.Bucket,
+			Prefix:                    rule.Prefix,
+			ExpirationDays:            rule.ExpirationDays,
+			NoncurrentExpirationDays:  rule.NoncurrentExpirationDays,
+			AbortIncompleteUploadDays: rule.AbortIncompleteUploadDays,
+			CreatedAt:                 toTimestamptz(rule.CreatedAt),
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to create lifecycle rule: %w", err)
+	}
+
+	return nil
+}
+
+func (r *RuleRepo) GetRules(ctx context.Context) ([]model.Rule, error) {
+	rows, err := r.querier.ListLifecycleRules(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lifecycle rules: %w", err)
+	}
+
+	return toRuleModels(rows), nil
+}
+
+func (r *RuleRepo) GetBucketRules(ctx context.Context, bucket string) ([]model.Rule, error) {
+	rows, err := r.querier.GetBucketLifecycleRules(ctx, bucket)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bucket lifecycle rules: %w", err)
+	}
+
+	return toRuleModels(rows), nil
+}
+
+func (r *RuleRepo) DeleteBucketRules(ctx context.Context, bucket string) error {
+	err := r.querier.DeleteBucketLifecycleRules(ctx, bucket)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete bucket lifecycle rules: %w", err)
+	}
+
+	return nil
+}
+
+// GetExpiredCurrentUploads returns up to maxCount latest versions under the prefix created before the time,
+// ordered by ID starting after the given one.
+func (r *RuleRepo) GetExpiredCurrentUploads(
+	ctx context.Context, bucket, prefix string, before time.Time, after uuid.UUID, maxCount int32,
+) ([]uploadModel.Upload, error) {
+	rows, err := r.querier.GetExpiredCurrentUploads(ctx, pg.GetExpiredCurrentUploadsParams{
+		Bucket:    bucket,
+		Prefix:    prefix,
+		CreatedAt: toTimestamptz(before),
+		After:     after,
+		MaxCount:  maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired current uploads: %w", err)
+	}
+
+	return uploadRepo.ToUploadModels(rows), nil
+}
+
+// GetExpiredNoncurrentUploads returns up to maxCount versions under the prefix that were replaced
+// by a newer version before the time, ordered by ID starting after the given one.
+func (r *RuleRepo) GetExpiredNoncurrentUploads(
+	ctx context.Context, bucket, prefix string, before time.Time, after uuid.UUID, maxCount int32,
+) ([]uploadModel.Upload, error) {
+	rows, err := r.querier.GetExpiredNoncurrentUploads(ctx, pg.GetExpiredNoncurrentUploadsParams{
+		Bucket:          bucket,
+		Prefix:          prefix,
+		NoncurrentSince: toTimestamptz(before),
+		After:           after,
+		MaxCount:        maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired noncurrent uploads: %w", err)
+	}
+
+	return uploadRepo.ToUploadModels(rows), nil
+}
+
+// GetExpiredDeleteMarkers returns up to maxCount delete markers that are the only version left of their object,
+// ordered by ID starting after the given one.
+func (r *RuleRepo) GetExpiredDeleteMarkers(
+	ctx context.Context, bucket, prefix string, after uuid.UUID, maxCount int32,
+) ([]uploadModel.Upload, error) {
+	rows, err := r.querier.GetExpiredDeleteMarkers(ctx, pg.GetExpiredDeleteMarkersParams{
+		Bucket:   bucket,
+		Prefix:   prefix,
+		After:    after,
+		MaxCount: maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired delete markers: %w", err)
+	}
+
+	return uploadRepo.ToUploadModels(rows), nil
+}
+
+// GetOldIncompleteUploads returns up to maxCount not committed uploads under the prefix created before the time,
+// ordered by ID starting after the given one.
+func (r *RuleRepo) GetOldIncompleteUploads(
+	ctx context.Context, bucket, prefix string, before time.Time, after uuid.UUID, maxCount int32,
+) ([]uploadModel.Upload, error) {
+	rows, err := r.querier.GetOldIncompleteUploads(ctx, pg.GetOldIncompleteUploadsParams{
+		Bucket:    bucket,
+		Prefix:    prefix,
+		CreatedAt: toTimestamptz(before),
+		After:     after,
+		MaxCount:  maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old incomplete uploads: %w", err)
+	}
+
+	return uploadRepo.ToUploadModels(rows), nil
+}
+
+func (r *RuleRepo) WithTx(tx pgx.Tx) *RuleRepo {
+	// если уже в транзакционном режиме - ничего не делаем
+	if r.qtx == nil {
+		return r
+	}
+
+	return &RuleRepo{
+		querier: r.qtx.WithTx(tx),
+		qtx:     nil, // нельзя запускать транзакцию повторно
+	}
+}
+
+func toTimestamptz(t time.Time) pgtype.Timestamptz {
+	return pgtype.Timestamptz{
+		Time:             t,
+		InfinityModifier: pgtype.Finite,
+		Valid:            true,
+	}
+}
+
+func toRuleModels(rows []pg.LifecycleRule) []model.Rule {
+	rules := make([]model.Rule, len(rows))
+	for i, r := range rows {
+		rules[i] = model.Rule{
+			ID:                        r.ID,
+			Bucket:                    r.Bucket,
+			Prefix:                    r.Prefix,
+			ExpirationDays:            r.ExpirationDays,
+			NoncurrentExpirationDays:  r.NoncurrentExpirationDays,
+			AbortIncompleteUploadDays: r.AbortIncompleteUploadDays,
+			CreatedAt:                 r.CreatedAt.Time,
+		}
+	}
+
+	return rules
+}
@@ -0,0 +1,290 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/service/lifecycle/model"
+	"github.com/quolpr/distributeds3/internal/service/lifecycle/repo"
+	"github.com/quolpr/distributeds3/internal/service/upload"
+	uploadModel "github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/pkg/transaction"
+)
+
+const day = time.Hour * 24
+
+// batchSize is the number of versions a rule loads at once.
+const batchSize = 1000
+
+var ErrInvalidRule = apperror.New(apperror.KindInvalidInput, "invalid_lifecycle_rule", "invalid lifecycle rule")
+
+// Service is a lifecycle engine. It periodically applies bucket rules: expires objects,
+// deletes noncurrent versions and aborts incomplete uploads.
+type Service struct {
+	ruleRepo    *repo.RuleRepo
+	uploadSvc   *upload.Service
+	transaction *transaction.Transaction
+
+	interval time.Duration
+}
+
+func NewService(
+	ruleRepo *repo.RuleRepo, uploadSvc *upload.Service, tr *transaction.Transaction, interval time.Duration,
+) *Service {
+	return &Service{
+		ruleRepo:    ruleRepo,
+		uploadSvc:   uploadSvc,
+		transaction: tr,
+		interval:    interval,
+	}
+}
+
+// Report holds the number of affected versions by a single engine run.
+type Report struct {
+	Expired          int
+	NoncurrentPurged int
+	MarkersPurged    int
+	Aborted          int
+	// FailedRules is the number of rules that failed, the run goes on with the next rule
+	FailedRules int
+}
+
+func (s *Service) GetBucketRules(ctx context.Context, bucket string) ([]model.Rule, error) {
+	rules, err := s.ruleRepo.GetBucketRules(ctx, bucket)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get bucket rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// PutBucketRules replaces all lifecycle rules of the bucket.
+func (s *Service) PutBucketRules(ctx context.Context, bucket string, rules []model.Rule) ([]model.Rule, error) {
+	for i := range rules {
+		rule := &rules[i]
+
+		if rule.ExpirationDays < 0 || rule.NoncurrentExpirationDays < 0 || rule.AbortIncompleteUploadDays < 0 {
+			return nil, fmt.Errorf("%w: days can't be negative", ErrInvalidRule)
+		}
+
+		if rule.ExpirationDays == 0 && rule.NoncurrentExpirationDays == 0 && rule.AbortIncompleteUploadDays == 0 {
+			return nil, fmt.Errorf("%w: rule for prefix %q has no actions", ErrInvalidRule, rule.Prefix)
+		}
+
+		rule.ID = uuid.New()
+		rule.Bucket = bucket
+		rule.CreatedAt = time.Now()
+	}
+
+	err := s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		ruleRepo := s.ruleRepo.WithTx(tx)
+
+		if err := ruleRepo.DeleteBucketRules(ctx, bucket); err != nil {
+			return fmt.Errorf("unable to delete bucket rules: %w", err)
+		}
+
+		for _, rule := range rules {
+			if err := ruleRepo.Create(ctx, rule); err != nil {
+				return fmt.Errorf("unable to create rule: %w", err)
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to put bucket rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// Run applies the rules every interval until ctx is done.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.RunOnce(ctx)
+
+		if err != nil {
+			slog.Error("Lifecycle run failed", "err", err)
+		} else {
+			slog.Info("Lifecycle run finished", "report", report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce applies every rule and then aborts uploads hanging longer than the global max upload time.
+// A failing rule is logged and counted, it doesn't stop other rules.
+func (s *Service) RunOnce(ctx context.Context) (Report, error) {
+	var report Report
+
+	rules, err := s.ruleRepo.GetRules(ctx)
+
+	if err != nil {
+		return report, fmt.Errorf("unable to get rules: %w", err)
+	}
+
+	for _, rule := range rules {
+		if err := s.applyRule(ctx, rule, &report); err != nil {
+			slog.Error("Unable to apply lifecycle rule", "rule", rule.ID, "bucket", rule.Bucket, "err", err)
+			report.FailedRules++
+		}
+	}
+
+	cleanup, err := s.uploadSvc.CleanDangleUploads(ctx, upload.DefaultCleanupOptions())
+
+	if err != nil {
+		return report, fmt.Errorf("unable to clean dangle uploads: %w", err)
+	}
+
+	report.Aborted += cleanup.Uploads
+
+	return report, nil
+}
+
+func (s *Service) applyRule(ctx context.Context, rule model.Rule, report *Report) error {
+	now := time.Now()
+
+	if rule.ExpirationDays > 0 {
+		before := now.Add(-day * time.Duration(rule.ExpirationDays))
+
+		err := forEachBatch(
+			func(after uuid.UUID) ([]uploadModel.Upload, error) {
+				return s.ruleRepo.GetExpiredCurrentUploads(ctx, rule.Bucket, rule.Prefix, before, after, batchSize)
+			},
+			func(versions []uploadModel.Upload) error {
+				for _, version := range versions {
+					if err := s.uploadSvc.ExpireObject(ctx, version); err != nil {
+						return fmt.Errorf("unable to expire object: %w", err)
+					}
+
+					report.Expired++
+				}
+
+				return nil
+			},
+		)
+
+		if err != nil {
+			return fmt.Errorf("unable to expire current versions: %w", err)
+		}
+	}
+
+	if rule.NoncurrentExpirationDays > 0 {
+		before := now.Add(-day * time.Duration(rule.NoncurrentExpirationDays))
+
+		err := forEachBatch(
+			func(after uuid.UUID) ([]uploadModel.Upload, error) {
+				return s.ruleRepo.GetExpiredNoncurrentUploads(ctx, rule.Bucket, rule.Prefix, before, after, batchSize)
+			},
+			func(versions []uploadModel.Upload) error {
+				err := s.deleteVersions(ctx, versions)
+				report.NoncurrentPurged += len(versions)
+
+				return err
+			},
+		)
+
+		if err != nil {
+			return fmt.Errorf("unable to purge noncurrent versions: %w", err)
+		}
+
+		err = forEachBatch(
+			func(after uuid.UUID) ([]uploadModel.Upload, error) {
+				return s.ruleRepo.GetExpiredDeleteMarkers(ctx, rule.Bucket, rule.Prefix, after, batchSize)
+			},
+			func(markers []uploadModel.Upload) error {
+				err := s.deleteVersions(ctx, markers)
+				report.MarkersPurged += len(markers)
+
+				return err
+			},
+		)
+
+		if err != nil {
+			return fmt.Errorf("unable to purge delete markers: %w", err)
+		}
+	}
+
+	if rule.AbortIncompleteUploadDays > 0 {
+		before := now.Add(-day * time.Duration(rule.AbortIncompleteUploadDays))
+
+		err := forEachBatch(
+			func(after uuid.UUID) ([]uploadModel.Upload, error) {
+				return s.ruleRepo.GetOldIncompleteUploads(ctx, rule.Bucket, rule.Prefix, before, after, batchSize)
+			},
+			func(uploads []uploadModel.Upload) error {
+				if err := s.uploadSvc.AbortUploads(ctx, uploads); err != nil {
+					return fmt.Errorf("unable to abort uploads: %w", err)
+				}
+
+				report.Aborted += len(uploads)
+
+				return nil
+			},
+		)
+
+		if err != nil {
+			return fmt.Errorf("unable to abort incomplete uploads: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// forEachBatch loads versions page by page, ordered by ID, and passes every page to apply,
+// so a rule matching millions of versions never holds them all in memory.
+func forEachBatch(
+	load func(after uuid.UUID) ([]uploadModel.Upload, error), apply func([]uploadModel.Upload) error,
+) error {
+	after := uuid.Nil
+
+	for {
+		versions, err := load(after)
+
+		if err != nil {
+			return fmt.Errorf("unable to load versions: %w", err)
+		}
+
+		if len(versions) == 0 {
+			return nil
+		}
+
+		if err := apply(versions); err != nil {
+			return err
+		}
+
+		if len(versions) < batchSize {
+			return nil
+		}
+
+		after = versions[len(versions)-1].ID
+	}
+}
+
+func (s *Service) deleteVersions(ctx context.Context, versions []uploadModel.Upload) error {
+	for _, version := range versions {
+		err := s.uploadSvc.DeleteObjectVersion(ctx, version.Bucket, version.Name, version.ID)
+
+		if err != nil && !errors.Is(err, upload.ErrObjectNotFound) && !errors.Is(err, upload.ErrObjectLocked) {
+			return fmt.Errorf("unable to delete version: %w", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"golang.org/x/exp/maps"
+)
+
+var (
+	ErrCircuitOpen = apperror.New(
+		apperror.KindUnavailable, "storage_unavailable", "storage server is unavailable, circuit is open",
+	)
+	ErrTimeout   = apperror.New(apperror.KindUnavailable, "storage_timeout", "storage server didn't respond in time")
+	ErrNoServers = apperror.New(apperror.KindUnavailable, "no_storage_servers", "no storage servers available")
+)
+
+type BreakerState string
+
+const (
+	// BreakerClosed server gets all calls.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen server is removed from available servers and gets no calls.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen server gets a single probe call that closes or opens the breaker again.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerConfig configures per-server circuit breakers.
+type BreakerConfig struct {
+	// Window is the number of last calls the failure rate is computed on
+	Window int
+	// FailureRate opens the breaker once the window is full, zero disables breakers
+	FailureRate float64
+	// SlowCall is the latency after which a successful call is counted as failed, zero disables it
+	SlowCall time.Duration
+	// OpenTimeout is how long the breaker stays open before the probe
+	OpenTimeout time.Duration
+}
+
+// ServerHealth is a snapshot of the server breaker.
+type ServerHealth struct {
+	ServerURL   string
+	State       BreakerState
+	FailureRate float64
+	// AvgLatency is an exponentially weighted average of call latencies
+	AvgLatency time.Duration
+	Calls      int64
+	Failures   int64
+	OpenedAt   time.Time
+}
+
+type serverBreaker struct {
+	state BreakerState
+	// outcomes is a ring of the last calls, true is a failure
+	outcomes []bool
+	next     int
+	filled   int
+	failures int
+	probing  bool
+
+	avgLatency    time.Duration
+	calls         int64
+	totalFailures int64
+	openedAt      time.Time
+}
+
+type breakers struct {
+	mu      sync.Mutex
+	cfg     BreakerConfig
+	servers map[string]*serverBreaker
+}
+
+func newBreakers(cfg BreakerConfig) *breakers {
+	return &breakers{
+		mu:      sync.Mutex{},
+		cfg:     cfg,
+		servers: make(map[string]*serverBreaker),
+	}
+}
+
+func (b *breakers) enabled() bool {
+	return b.cfg.FailureRate > 0 && b.cfg.Window > 0
+}
+
+func (b *breakers) get(serverURL string) *serverBreaker {
+	breaker, ok := b.servers[serverURL]
+
+	if !ok {
+		breaker = &serverBreaker{ //nolint:exhaustruct
+			state:    BreakerClosed,
+			outcomes: make([]bool, b.cfg.Window),
+		}
+		b.servers[serverURL] = breaker
+	}
+
+	return breaker
+}
+
+// available reports whether the server may get calls. An open breaker becomes half-open
+// after OpenTimeout, so the server is returned as available for the probe.
+func (b *breakers) available(serverURL string, now time.Time) bool {
+	if !b.enabled() {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker := b.get(serverURL)
+
+	return breaker.state != BreakerOpen || now.Sub(breaker.openedAt) >= b.cfg.OpenTimeout
+}
+
+// allow reports whether the call to the server can be made. Only one call at a time probes a half-open server.
+func (b *breakers) allow(serverURL string, now time.Time) bool {
+	if !b.enabled() {
+		return true
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker := b.get(serverURL)
+
+	if breaker.state == BreakerOpen && now.Sub(breaker.openedAt) >= b.cfg.OpenTimeout {
+		breaker.state = BreakerHalfOpen
+	}
+
+	switch breaker.state {
+	case BreakerClosed:
+		return true
+	case BreakerHalfOpen:
+		if breaker.probing {
+			return false
+		}
+
+		breaker.probing = true
+
+		return true
+	default:
+		return false
+	}
+}
+
+// record accounts the outcome of an allowed call.
+func (b *breakers) record(serverURL string, failed bool, latency time.Duration, now time.Time) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	breaker := b.get(serverURL)
+	failed = failed || (b.cfg.SlowCall > 0 && latency > b.cfg.SlowCall)
+
+	breaker.calls++
+	breaker.avgLatency += (latency - breaker.avgLatency) / 8 //nolint:gomnd
+
+	if failed {
+		breaker.totalFailures++
+	}
+
+	if breaker.state == BreakerHalfOpen {
+		breaker.probing = false
+
+		if failed {
+			breaker.open(now)
+		} else {
+			breaker.close()
+		}
+
+		return
+	}
+
+	breaker.push(failed)
+
+	if breaker.state == BreakerClosed && breaker.filled == len(breaker.outcomes) &&
+		breaker.failureRate() >= b.cfg.FailureRate {
+		breaker.open(now)
+	}
+}
+
+// release frees the probe of the call that was canceled by the caller, so it says nothing about the server.
+func (b *breakers) release(serverURL string) {
+	if !b.enabled() {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.get(serverURL).probing = false
+}
+
+// snapshot returns health of the servers sorted by URL.
+func (b *breakers) snapshot(serverURLs []string) []ServerHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, serverURL := range serverURLs {
+		b.get(serverURL)
+	}
+
+	urls := maps.Keys(b.servers)
+	slices.Sort(urls)
+
+	health := make([]ServerHealth, 0, len(urls))
+
+	for _, serverURL := range urls {
+		breaker := b.servers[serverURL]
+		health = append(health, ServerHealth{
+			ServerURL:   serverURL,
+			State:       breaker.state,
+			FailureRate: breaker.failureRate(),
+			AvgLatency:  breaker.avgLatency,
+			Calls:       breaker.calls,
+			Failures:    breaker.totalFailures,
+			OpenedAt:    breaker.openedAt,
+		})
+	}
+
+	return health
+}
+
+func (sb *serverBreaker) push(failed bool) {
+	if sb.filled == len(sb.outcomes) && sb.outcomes[sb.next] {
+		sb.failures--
+	}
+
+	if failed {
+		sb.failures++
+	}
+
+	sb.outcomes[sb.next] = failed
+	sb.next = (sb.next + 1) % len(sb.outcomes)
+	sb.filled = min(sb.filled+1, len(sb.outcomes))
+}
+
+func (sb *serverBreaker) failureRate() float64 {
+	if sb.filled == 0 {
+		return 0
+	}
+
+	return float64(sb.failures) / float64(sb.filled)
+}
+
+func (sb *serverBreaker) open(now time.Time) {
+	sb.state = BreakerOpen
+	sb.openedAt = now
+}
+
+// close resets the window, so the server is judged only by calls after it is back.
+func (sb *serverBreaker) close() {
+	sb.state = BreakerClosed
+	clear(sb.outcomes)
+	sb.next = 0
+	sb.filled = 0
+	sb.failures = 0
+}
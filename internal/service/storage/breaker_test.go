@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+const testServer = "http://storage-1"
+
+func newTestBreakers() *breakers {
+	return newBreakers(BreakerConfig{
+		Window:      4,
+		FailureRate: 0.5,
+		SlowCall:    time.Second,
+		OpenTimeout: time.Minute,
+	})
+}
+
+func TestBreakerOpensOnFailureRate(t *testing.T) {
+	b := newTestBreakers()
+	now := time.Now()
+
+	b.record(testServer, true, 0, now)
+	b.record(testServer, true, 0, now)
+	b.record(testServer, false, 0, now)
+
+	// The window is not full yet
+	assertState(t, b, BreakerClosed)
+
+	b.record(testServer, false, 0, now)
+	assertState(t, b, BreakerOpen)
+
+	if b.allow(testServer, now.Add(time.Second)) || b.available(testServer, now.Add(time.Second)) {
+		t.Error("open breaker allowed a call")
+	}
+}
+
+func TestBreakerStaysClosedBelowFailureRate(t *testing.T) {
+	b := newTestBreakers()
+	now := time.Now()
+
+	for range 10 {
+		b.record(testServer, false, 0, now)
+		b.record(testServer, false, 0, now)
+		b.record(testServer, false, 0, now)
+		b.record(testServer, true, 0, now)
+	}
+
+	assertState(t, b, BreakerClosed)
+
+	if !b.allow(testServer, now) {
+		t.Error("closed breaker rejected a call")
+	}
+}
+
+func TestBreakerCountsSlowCallsAsFailures(t *testing.T) {
+	b := newTestBreakers()
+	now := time.Now()
+
+	for range 4 {
+		b.record(testServer, false, 2*time.Second, now)
+	}
+
+	assertState(t, b, BreakerOpen)
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	tests := []struct {
+		name        string
+		probeFailed bool
+		want        BreakerState
+	}{
+		{name: "successful probe closes", probeFailed: false, want: BreakerClosed},
+		{name: "failed probe opens again", probeFailed: true, want: BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreakers()
+			now := time.Now()
+
+			openBreaker(b, now)
+
+			probeAt := now.Add(time.Minute)
+
+			if !b.available(testServer, probeAt) {
+				t.Fatal("server is not available for the probe after OpenTimeout")
+			}
+
+			if !b.allow(testServer, probeAt) {
+				t.Fatal("half-open breaker rejected the probe")
+			}
+
+			assertState(t, b, BreakerHalfOpen)
+
+			if b.allow(testServer, probeAt) {
+				t.Fatal("half-open breaker allowed a second call during the probe")
+			}
+
+			b.record(testServer, tt.probeFailed, 0, probeAt)
+			assertState(t, b, tt.want)
+
+			if allowed := b.allow(testServer, probeAt.Add(time.Second)); allowed != (tt.want == BreakerClosed) {
+				t.Errorf("expected allow %t after the probe, got %t", tt.want == BreakerClosed, allowed)
+			}
+		})
+	}
+}
+
+func TestBreakerClosedAfterProbeStartsWithEmptyWindow(t *testing.T) {
+	b := newTestBreakers()
+	now := time.Now()
+
+	openBreaker(b, now)
+
+	probeAt := now.Add(time.Minute)
+	b.allow(testServer, probeAt)
+	b.record(testServer, false, 0, probeAt)
+
+	// A single failure doesn't reopen it, old failures are forgotten
+	b.record(testServer, true, 0, probeAt)
+	b.record(testServer, false, 0, probeAt)
+	b.record(testServer, false, 0, probeAt)
+	assertState(t, b, BreakerClosed)
+}
+
+func TestBreakerReleaseFreesProbe(t *testing.T) {
+	b := newTestBreakers()
+	now := time.Now()
+
+	openBreaker(b, now)
+
+	probeAt := now.Add(time.Minute)
+	b.allow(testServer, probeAt)
+	b.release(testServer)
+
+	if !b.allow(testServer, probeAt) {
+		t.Error("released probe was not allowed again")
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := newBreakers(BreakerConfig{Window: 4, FailureRate: 0, SlowCall: 0, OpenTimeout: time.Minute})
+	now := time.Now()
+
+	for range 10 {
+		b.record(testServer, true, 0, now)
+	}
+
+	if !b.allow(testServer, now) || !b.available(testServer, now) {
+		t.Error("disabled breaker rejected a call")
+	}
+}
+
+func openBreaker(b *breakers, now time.Time) {
+	for range b.cfg.Window {
+		b.record(testServer, true, 0, now)
+	}
+}
+
+func assertState(t *testing.T, b *breakers, want BreakerState) {
+	t.Helper()
+
+	health := b.snapshot([]string{testServer})
+
+	if len(health) != 1 || health[0].State != want {
+		t.Fatalf("expected breaker %s, got %+v", want, health)
+	}
+}
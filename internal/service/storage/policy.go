@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/quolpr/distributeds3/internal/service/storage"
+
+// errPartialRead is returned by a read that failed after passing some data to the caller, it is not retried.
+var errPartialRead = errors.New("read failed after writing data")
+
+// Policy configures timeouts and retries of storage calls.
+type Policy struct {
+	// Timeout bounds a single attempt of a call, zero means no timeout
+	Timeout time.Duration
+	// Retries is the number of extra attempts of idempotent calls
+	Retries int
+	// Backoff is the delay before the first retry, it is doubled for every next one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// attempt runs the call to the server with the per-attempt timeout. The outcome is accounted
+// by the server breaker, calls to the server with open breaker fail right away.
+// Operation labels metrics of the call.
+func (s *Service) attempt(
+	ctx context.Context, serverURL, operation string, call func(ctx context.Context) error,
+) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("server.url", serverURL)),
+	)
+	defer span.End()
+
+	if !s.breakers.allow(serverURL, time.Now()) {
+		s.metrics.StorageErrors.WithLabelValues(serverURL, operation).Inc()
+		span.SetStatus(codes.Error, ErrCircuitOpen.Error())
+
+		return ErrCircuitOpen
+	}
+
+	start := time.Now()
+	err := s.timed(ctx, call)
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	s.metrics.StorageDuration.WithLabelValues(serverURL, operation).Observe(time.Since(start).Seconds())
+
+	// Calls canceled by the caller say nothing about the server
+	if ctx.Err() != nil {
+		s.breakers.release(serverURL)
+
+		return err
+	}
+
+	s.breakers.record(serverURL, err != nil, time.Since(start), time.Now())
+
+	if err != nil {
+		s.metrics.StorageErrors.WithLabelValues(serverURL, operation).Inc()
+	}
+
+	// The caller's ctx is alive, so the deadline is the one of the attempt
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	return err
+}
+
+func (s *Service) timed(ctx context.Context, call func(ctx context.Context) error) error {
+	if s.policy.Timeout == 0 {
+		return call(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.policy.Timeout)
+	defer cancel()
+
+	return call(ctx)
+}
+
+// retry runs attempts of the idempotent call till one succeeds, retries are over or ctx is done.
+func (s *Service) retry(
+	ctx context.Context, serverURL, operation string, call func(ctx context.Context) error,
+) error {
+	return s.withRetries(ctx, func(ctx context.Context) error {
+		return s.attempt(ctx, serverURL, operation, call)
+	})
+}
+
+func (s *Service) withRetries(ctx context.Context, call func(ctx context.Context) error) error {
+	backoff := s.policy.Backoff
+
+	for attempt := 0; ; attempt++ {
+		err := call(ctx)
+
+		if err == nil || attempt >= s.policy.Retries || ctx.Err() != nil ||
+			errors.Is(err, ErrCircuitOpen) || errors.Is(err, errPartialRead) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return fmt.Errorf("%w, last error: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, s.policy.MaxBackoff) //nolint:gomnd
+	}
+}
+
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.n += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/metrics"
+	"github.com/quolpr/distributeds3/internal/service/storage/repo"
+)
+
+var errRead = errors.New("read failed")
+
+// flakyRepo serves ReadPart with the given attempts in order, the last one is repeated.
+type flakyRepo struct {
+	repo.Repo
+
+	attempts []func(writer io.Writer) error
+	calls    int
+}
+
+func (r *flakyRepo) ReadPart(_ context.Context, _ uuid.UUID, _ string, writer io.Writer) error {
+	attempt := r.attempts[min(r.calls, len(r.attempts)-1)]
+	r.calls++
+
+	return attempt(writer)
+}
+
+// noRegistry has no servers added at runtime.
+type noRegistry struct{}
+
+func (noRegistry) GetServerURLs(context.Context) ([]string, error) {
+	return nil, nil
+}
+
+func newTestService(storage repo.Repo) *Service {
+	return NewService(storage, noRegistry{}, Policy{
+		Timeout:    0,
+		Retries:    2,
+		Backoff:    time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	}, BreakerConfig{Window: 100, FailureRate: 0, SlowCall: 0, OpenTimeout: time.Minute}, metrics.New())
+}
+
+func TestReadRetriesAttemptWithoutData(t *testing.T) {
+	storage := &flakyRepo{attempts: []func(io.Writer) error{
+		func(io.Writer) error { return errRead },
+		func(writer io.Writer) error {
+			_, err := writer.Write([]byte("data"))
+
+			return err
+		},
+	}}
+
+	var out bytes.Buffer
+
+	if err := newTestService(storage).ReadPart(context.Background(), uuid.New(), testServer, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if out.String() != "data" || storage.calls != 2 {
+		t.Errorf("expected data after 2 calls, got %q after %d", out.String(), storage.calls)
+	}
+}
+
+func TestReadDoesNotRetryAttemptWithData(t *testing.T) {
+	storage := &flakyRepo{attempts: []func(io.Writer) error{
+		func(writer io.Writer) error {
+			_, _ = writer.Write([]byte("da"))
+
+			return errRead
+		},
+		func(writer io.Writer) error {
+			_, err := writer.Write([]byte("data"))
+
+			return err
+		},
+	}}
+
+	var out bytes.Buffer
+
+	err := newTestService(storage).ReadPart(context.Background(), uuid.New(), testServer, &out)
+
+	if !errors.Is(err, errRead) || !errors.Is(err, errPartialRead) {
+		t.Fatalf("expected partial read error, got %v", err)
+	}
+
+	// The writer only got the data of the failed attempt
+	if out.String() != "da" || storage.calls != 1 {
+		t.Errorf("expected %q after 1 call, got %q after %d", "da", out.String(), storage.calls)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/storage/repo"
 	"golang.org/x/exp/maps"
 )
 
@@ -15,28 +18,67 @@ var (
 	ErrServerNotFound      = errors.New("server not found")
 	ErrPartNotFound        = errors.New("part not found")
 	ErrPartAlreadyUploaded = errors.New("part already uploaded")
+	ErrVolumeNotFound      = errors.New("volume not found")
+	ErrOutOfVolume         = errors.New("read is out of volume")
+	ErrOutOfPart           = errors.New("read is out of part")
 )
 
 type InmemRepo struct {
+	mu sync.RWMutex
 	// serverURL -> partID -> part
 	parts map[string]map[uuid.UUID][]byte
+	// serverURL -> volumeID -> volume
+	volumes map[string]map[uuid.UUID][]byte
+	// serverURL -> partID or volumeID -> time of the last write
+	modified map[string]map[uuid.UUID]time.Time
 }
 
-func NewInmemRepo() *InmemRepo {
+func NewInmemRepo(servers []string) *InmemRepo {
 	parts := make(map[string]map[uuid.UUID][]byte)
+	volumes := make(map[string]map[uuid.UUID][]byte)
+	modified := make(map[string]map[uuid.UUID]time.Time)
 
-	parts["http://localhost:8080"] = make(map[uuid.UUID][]byte)
-	parts["http://localhost:8081"] = make(map[uuid.UUID][]byte)
-	parts["http://localhost:8082"] = make(map[uuid.UUID][]byte)
+	for _, serverURL := range servers {
+		parts[serverURL] = make(map[uuid.UUID][]byte)
+		volumes[serverURL] = make(map[uuid.UUID][]byte)
+		modified[serverURL] = make(map[uuid.UUID]time.Time)
+	}
 
-	return &InmemRepo{parts: parts}
+	return &InmemRepo{mu: sync.RWMutex{}, parts: parts, volumes: volumes, modified: modified}
 }
 
 func (r *InmemRepo) GetAvailableServers(ctx context.Context) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	return maps.Keys(r.parts), nil
 }
 
+func (r *InmemRepo) AddServer(ctx context.Context, serverURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.parts[serverURL]; ok {
+		return nil
+	}
+
+	r.parts[serverURL] = make(map[uuid.UUID][]byte)
+	r.volumes[serverURL] = make(map[uuid.UUID][]byte)
+	r.modified[serverURL] = make(map[uuid.UUID]time.Time)
+
+	return nil
+}
+
 func (r *InmemRepo) UploadPart(ctx context.Context, partID uuid.UUID, serverURL string, reader io.Reader) error {
+	b, err := io.ReadAll(reader)
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to read part: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	m, ok := r.parts[serverURL]
 	if !ok {
 		return ErrServerNotFound
@@ -47,18 +89,16 @@ func (r *InmemRepo) UploadPart(ctx context.Context, partID uuid.UUID, serverURL
 		return ErrPartAlreadyUploaded
 	}
 
-	b, err := io.ReadAll(reader)
-
-	if err != nil && !errors.Is(err, io.EOF) {
-		return fmt.Errorf("failed to read part: %w", err)
-	}
-
 	m[partID] = b
+	r.modified[serverURL][partID] = time.Now()
 
 	return nil
 }
 
 func (r *InmemRepo) GetPart(ctx context.Context, partID uuid.UUID, serverURL string) (io.Reader, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	m, ok := r.parts[serverURL]
 	if !ok {
 		return nil, ErrServerNotFound
@@ -73,23 +113,33 @@ func (r *InmemRepo) GetPart(ctx context.Context, partID uuid.UUID, serverURL str
 }
 
 func (r *InmemRepo) CleanPart(ctx context.Context, partID uuid.UUID, serverURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	m, ok := r.parts[serverURL]
 	if !ok {
 		return ErrServerNotFound
 	}
 
 	delete(m, partID)
+	delete(r.modified[serverURL], partID)
 
 	return nil
 }
 
 func (r *InmemRepo) ReadPart(ctx context.Context, id uuid.UUID, serverURL string, writer io.Writer) error {
+	r.mu.RLock()
+
 	m, ok := r.parts[serverURL]
 	if !ok {
+		r.mu.RUnlock()
+
 		return ErrServerNotFound
 	}
 
 	p, ok := m[id]
+	r.mu.RUnlock()
+
 	if !ok {
 		return ErrPartNotFound
 	}
@@ -102,3 +152,157 @@ func (r *InmemRepo) ReadPart(ctx context.Context, id uuid.UUID, serverURL string
 
 	return nil
 }
+
+func (r *InmemRepo) ReadPartRange(
+	ctx context.Context, id uuid.UUID, serverURL string, offset, length int64, writer io.Writer,
+) error {
+	r.mu.RLock()
+
+	m, ok := r.parts[serverURL]
+	if !ok {
+		r.mu.RUnlock()
+
+		return ErrServerNotFound
+	}
+
+	p, ok := m[id]
+	r.mu.RUnlock()
+
+	if !ok {
+		return ErrPartNotFound
+	}
+
+	if offset < 0 || length < 0 || offset+length > int64(len(p)) {
+		return ErrOutOfPart
+	}
+
+	_, err := writer.Write(p[offset : offset+length])
+
+	if err != nil {
+		return fmt.Errorf("failed to write part: %w", err)
+	}
+
+	return nil
+}
+
+func (r *InmemRepo) AppendToVolume(ctx context.Context, id uuid.UUID, serverURL string, reader io.Reader) (int64, error) {
+	b, err := io.ReadAll(reader)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read needle: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.volumes[serverURL]
+	if !ok {
+		return 0, ErrServerNotFound
+	}
+
+	offset := int64(len(m[id]))
+	m[id] = append(m[id], b...)
+	r.modified[serverURL][id] = time.Now()
+
+	return offset, nil
+}
+
+func (r *InmemRepo) ReadVolume(
+	ctx context.Context, id uuid.UUID, serverURL string, offset, length int64, writer io.Writer,
+) error {
+	r.mu.RLock()
+
+	m, ok := r.volumes[serverURL]
+	if !ok {
+		r.mu.RUnlock()
+
+		return ErrServerNotFound
+	}
+
+	v, ok := m[id]
+	r.mu.RUnlock()
+
+	if !ok {
+		return ErrVolumeNotFound
+	}
+
+	if offset < 0 || length < 0 || offset+length > int64(len(v)) {
+		return ErrOutOfVolume
+	}
+
+	_, err := writer.Write(v[offset : offset+length])
+
+	if err != nil {
+		return fmt.Errorf("failed to write needle: %w", err)
+	}
+
+	return nil
+}
+
+func (r *InmemRepo) DeleteVolume(ctx context.Context, id uuid.UUID, serverURL string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := r.volumes[serverURL]
+	if !ok {
+		return ErrServerNotFound
+	}
+
+	delete(m, id)
+	delete(r.modified[serverURL], id)
+
+	return nil
+}
+
+func (r *InmemRepo) ListParts(ctx context.Context, serverURL string) ([]repo.Object, error) {
+	return r.list(serverURL, r.parts)
+}
+
+func (r *InmemRepo) ListVolumes(ctx context.Context, serverURL string) ([]repo.Object, error) {
+	return r.list(serverURL, r.volumes)
+}
+
+// Stat returns usage of the server memory, it is not limited.
+func (r *InmemRepo) Stat(ctx context.Context, serverURL string) (repo.ServerStat, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	parts, ok := r.parts[serverURL]
+	if !ok {
+		return repo.ServerStat{}, ErrServerNotFound //nolint:exhaustruct
+	}
+
+	stat := repo.ServerStat{Capacity: 0, Used: 0, Parts: len(parts), Volumes: len(r.volumes[serverURL])}
+
+	for _, data := range parts {
+		stat.Used += int64(len(data))
+	}
+
+	for _, data := range r.volumes[serverURL] {
+		stat.Used += int64(len(data))
+	}
+
+	return stat, nil
+}
+
+func (r *InmemRepo) list(serverURL string, objects map[string]map[uuid.UUID][]byte) ([]repo.Object, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	m, ok := objects[serverURL]
+	if !ok {
+		return nil, ErrServerNotFound
+	}
+
+	inventory := make([]repo.Object, 0, len(m))
+
+	for id, data := range m {
+		inventory = append(inventory, repo.Object{
+			ID:         id,
+			Size:       int64(len(data)),
+			ModifiedAt: r.modified[serverURL][id],
+		})
+	}
+
+	return inventory, nil
+}
@@ -3,13 +3,47 @@ package repo
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// Repo is a client of storage nodes.
 type Repo interface {
 	GetAvailableServers(ctx context.Context) ([]string, error)
+	// AddServer makes the server available, it does nothing if the server is already known.
+	AddServer(ctx context.Context, serverURL string) error
 	UploadPart(ctx context.Context, id uuid.UUID, serverURL string, reader io.Reader) error
 	ReadPart(ctx context.Context, id uuid.UUID, serverURL string, writer io.Writer) error
+	// ReadPartRange writes length bytes of the part starting at offset.
+	ReadPartRange(ctx context.Context, id uuid.UUID, serverURL string, offset, length int64, writer io.Writer) error
 	CleanPart(ctx context.Context, id uuid.UUID, serverURL string) error
+	// AppendToVolume appends data to the end of the volume file, creating it if needed,
+	// and returns the offset the data is written at.
+	AppendToVolume(ctx context.Context, id uuid.UUID, serverURL string, reader io.Reader) (int64, error)
+	ReadVolume(ctx context.Context, id uuid.UUID, serverURL string, offset, length int64, writer io.Writer) error
+	DeleteVolume(ctx context.Context, id uuid.UUID, serverURL string) error
+	// ListParts returns the inventory of parts stored on the server.
+	ListParts(ctx context.Context, serverURL string) ([]Object, error)
+	// ListVolumes returns the inventory of volumes stored on the server.
+	ListVolumes(ctx context.Context, serverURL string) ([]Object, error)
+	// Stat returns usage of the server storage.
+	Stat(ctx context.Context, serverURL string) (ServerStat, error)
+}
+
+type ServerStat struct {
+	// Capacity is the size of the server storage, zero if it is not limited
+	Capacity int64
+	// Used is the size of stored parts and volumes
+	Used    int64
+	Parts   int
+	Volumes int
+}
+
+// Object is a part or a volume stored on a server.
+type Object struct {
+	ID   uuid.UUID
+	Size int64
+	// ModifiedAt is the time of the last write, volumes are modified by every append
+	ModifiedAt time.Time
 }
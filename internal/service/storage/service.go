@@ -2,32 +2,218 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"slices"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/metrics"
 	"github.com/quolpr/distributeds3/internal/service/storage/repo"
 )
 
+// Registry lists servers added to the cluster at runtime, in addition to the ones the repo is configured with.
+type Registry interface {
+	GetServerURLs(ctx context.Context) ([]string, error)
+}
+
 type Service struct {
-	repo repo.Repo
+	repo     repo.Repo
+	registry Registry
+	policy   Policy
+	breakers *breakers
+	metrics  *metrics.Metrics
 }
 
-func NewService(repo repo.Repo) *Service {
-	return &Service{repo: repo}
+func NewService(
+	repo repo.Repo, registry Registry, policy Policy, breaker BreakerConfig, metrics *metrics.Metrics,
+) *Service {
+	return &Service{repo: repo, registry: registry, policy: policy, breakers: newBreakers(breaker), metrics: metrics}
 }
 
+// GetAvailableServers returns servers except ones with open breakers. If breakers of all servers
+// are open, all of them are returned: failing calls are better than refusing every upload.
 func (s *Service) GetAvailableServers(ctx context.Context) ([]string, error) {
-	return s.repo.GetAvailableServers(ctx) //nolint:wrapcheck
+	servers, err := s.listServers(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	available := make([]string, 0, len(servers))
+
+	for _, server := range servers {
+		if s.breakers.available(server, now) {
+			available = append(available, server)
+		}
+	}
+
+	if len(available) == 0 {
+		return servers, nil
+	}
+
+	return available, nil
+}
+
+// ServersHealth returns breaker states and call stats of the servers.
+func (s *Service) ServersHealth(ctx context.Context) ([]ServerHealth, error) {
+	servers, err := s.listServers(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.breakers.snapshot(servers), nil
+}
+
+func (s *Service) listServers(ctx context.Context) ([]string, error) {
+	var servers []string
+
+	err := s.withRetries(ctx, func(ctx context.Context) error {
+		return s.timed(ctx, func(ctx context.Context) error {
+			var err error
+			servers, err = s.repo.GetAvailableServers(ctx)
+
+			return err //nolint:wrapcheck
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	registered, err := s.registry.GetServerURLs(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get registered servers: %w", err)
+	}
+
+	for _, serverURL := range registered {
+		if slices.Contains(servers, serverURL) {
+			continue
+		}
+
+		if err := s.repo.AddServer(ctx, serverURL); err != nil {
+			return nil, fmt.Errorf("unable to add server %s: %w", serverURL, err)
+		}
+
+		servers = append(servers, serverURL)
+	}
+
+	return servers, nil
 }
 
 func (s *Service) CleanPart(ctx context.Context, id uuid.UUID, serverURL string) error {
-	return s.repo.CleanPart(ctx, id, serverURL) //nolint:wrapcheck
+	return s.retry(ctx, serverURL, "clean_part", func(ctx context.Context) error {
+		return s.repo.CleanPart(ctx, id, serverURL) //nolint:wrapcheck
+	})
 }
 
+// UploadPart is not retried: the reader is consumed by the failed attempt.
 func (s *Service) UploadPart(ctx context.Context, id uuid.UUID, serverURL string, reader io.Reader) error {
-	return s.repo.UploadPart(ctx, id, serverURL, reader) //nolint:wrapcheck
+	return s.attempt(ctx, serverURL, "upload_part", func(ctx context.Context) error {
+		return s.repo.UploadPart(ctx, id, serverURL, reader) //nolint:wrapcheck
+	})
 }
 
 func (s *Service) ReadPart(ctx context.Context, id uuid.UUID, serverURL string, writer io.Writer) error {
-	return s.repo.ReadPart(ctx, id, serverURL, writer) //nolint:wrapcheck
+	return s.read(ctx, serverURL, "read_part", writer, func(ctx context.Context, writer io.Writer) error {
+		return s.repo.ReadPart(ctx, id, serverURL, writer) //nolint:wrapcheck
+	})
+}
+
+// ReadPartRange writes length bytes of the part starting at offset.
+func (s *Service) ReadPartRange(
+	ctx context.Context, id uuid.UUID, serverURL string, offset, length int64, writer io.Writer,
+) error {
+	return s.read(ctx, serverURL, "read_part_range", writer, func(ctx context.Context, writer io.Writer) error {
+		return s.repo.ReadPartRange(ctx, id, serverURL, offset, length, writer) //nolint:wrapcheck
+	})
+}
+
+// AppendToVolume is not retried: a retry after a lost response would append the data twice.
+func (s *Service) AppendToVolume(ctx context.Context, id uuid.UUID, serverURL string, reader io.Reader) (int64, error) {
+	var offset int64
+
+	err := s.attempt(ctx, serverURL, "append_volume", func(ctx context.Context) error {
+		var err error
+		offset, err = s.repo.AppendToVolume(ctx, id, serverURL, reader)
+
+		return err //nolint:wrapcheck
+	})
+
+	return offset, err
+}
+
+func (s *Service) ReadVolume(
+	ctx context.Context, id uuid.UUID, serverURL string, offset, length int64, writer io.Writer,
+) error {
+	return s.read(ctx, serverURL, "read_volume", writer, func(ctx context.Context, writer io.Writer) error {
+		return s.repo.ReadVolume(ctx, id, serverURL, offset, length, writer) //nolint:wrapcheck
+	})
+}
+
+func (s *Service) DeleteVolume(ctx context.Context, id uuid.UUID, serverURL string) error {
+	return s.retry(ctx, serverURL, "delete_volume", func(ctx context.Context) error {
+		return s.repo.DeleteVolume(ctx, id, serverURL) //nolint:wrapcheck
+	})
+}
+
+func (s *Service) ListParts(ctx context.Context, serverURL string) ([]repo.Object, error) {
+	return s.list(ctx, serverURL, "list_parts", s.repo.ListParts)
+}
+
+func (s *Service) ListVolumes(ctx context.Context, serverURL string) ([]repo.Object, error) {
+	return s.list(ctx, serverURL, "list_volumes", s.repo.ListVolumes)
+}
+
+func (s *Service) Stat(ctx context.Context, serverURL string) (repo.ServerStat, error) {
+	var stat repo.ServerStat
+
+	err := s.retry(ctx, serverURL, "stat", func(ctx context.Context) error {
+		var err error
+		stat, err = s.repo.Stat(ctx, serverURL)
+
+		return err //nolint:wrapcheck
+	})
+
+	return stat, err
+}
+
+func (s *Service) list(
+	ctx context.Context, serverURL, operation string,
+	list func(ctx context.Context, serverURL string) ([]repo.Object, error),
+) ([]repo.Object, error) {
+	var inventory []repo.Object
+
+	err := s.retry(ctx, serverURL, operation, func(ctx context.Context) error {
+		var err error
+		inventory, err = list(ctx, serverURL)
+
+		return err //nolint:wrapcheck
+	})
+
+	return inventory, err
+}
+
+// read writes the data to the writer as it arrives. The read is retried only if the failed attempt
+// wrote nothing, the writer can't take the same data twice.
+func (s *Service) read(
+	ctx context.Context, serverURL, operation string, writer io.Writer,
+	read func(ctx context.Context, writer io.Writer) error,
+) error {
+	counter := &countingWriter{writer: writer, n: 0}
+
+	return s.withRetries(ctx, func(ctx context.Context) error {
+		err := s.attempt(ctx, serverURL, operation, func(ctx context.Context) error {
+			return read(ctx, counter)
+		})
+
+		if err != nil && counter.n > 0 {
+			return fmt.Errorf("%w: %w", errPartialRead, err)
+		}
+
+		return err
+	})
 }
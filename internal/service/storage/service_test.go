@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/metrics"
+	"github.com/quolpr/distributeds3/internal/service/storage/repo/inmemstorage"
+)
+
+type staticRegistry []string
+
+func (r staticRegistry) GetServerURLs(context.Context) ([]string, error) {
+	return r, nil
+}
+
+func TestGetAvailableServersIncludesRegisteredServers(t *testing.T) {
+	const added = "http://storage-2"
+
+	ctx := context.Background()
+	svc := NewService(
+		inmemstorage.NewInmemRepo([]string{testServer}), staticRegistry{testServer, added},
+		Policy{Timeout: 0, Retries: 0, Backoff: 0, MaxBackoff: 0},
+		BreakerConfig{Window: 4, FailureRate: 0, SlowCall: 0, OpenTimeout: time.Minute}, metrics.New(),
+	)
+
+	servers, err := svc.GetAvailableServers(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(servers)
+
+	if !slices.Equal(servers, []string{testServer, added}) {
+		t.Fatalf("unexpected servers %v", servers)
+	}
+
+	// The added server stores data like a configured one
+	id := uuid.New()
+
+	if err := svc.UploadPart(ctx, id, added, bytes.NewReader([]byte("data"))); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+
+	if err := svc.ReadPart(ctx, id, added, &out); err != nil || out.String() != "data" {
+		t.Errorf("expected data, got %q, %v", out.String(), err)
+	}
+}
@@ -0,0 +1,313 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+)
+
+// cleanupLockKey is the advisory lock key held while dangle uploads are cleaned.
+const cleanupLockKey = "distributeds3:cleanup"
+
+// CleanupOptions are parameters of a dangle uploads cleanup.
+type CleanupOptions struct {
+	// BatchSize is the number of uploads or queued part cleanups loaded at once
+	BatchSize int32
+	// MaxAttempts is the number of failed cleanups of a part after which it is not retried
+	MaxAttempts int32
+	// DryRun only reports what would be cleaned
+	DryRun bool
+}
+
+func DefaultCleanupOptions() CleanupOptions {
+	return CleanupOptions{
+		BatchSize:   100, //nolint:gomnd
+		MaxAttempts: 5,   //nolint:gomnd
+		DryRun:      false,
+	}
+}
+
+// CleanupReport holds the result of a cleanup. In a dry run it holds what would be cleaned,
+// bytes of deduplicated data still referenced by other uploads are counted too.
+type CleanupReport struct {
+	// Skipped is set if the cleanup is running in another process
+	Skipped        bool
+	Uploads        int
+	CleanedParts   int
+	FailedParts    int
+	CleanedVolumes int
+	FailedVolumes  int
+	ReclaimedBytes int64
+}
+
+func (r *CleanupReport) add(other CleanupReport) {
+	r.Uploads += other.Uploads
+	r.CleanedParts += other.CleanedParts
+	r.FailedParts += other.FailedParts
+	r.CleanedVolumes += other.CleanedVolumes
+	r.FailedVolumes += other.FailedVolumes
+	r.ReclaimedBytes += other.ReclaimedBytes
+}
+
+// CleanDangleUploads deletes uploads that are not committed for longer than maxUploadTime,
+// retries failed cleanups of part data and removes queued volumes whose delete delay is over. It is an upper bound for all buckets, lifecycle
+// rules can abort uploads earlier. Only one process cleans at a time, others skip the run.
+func (s *Service) CleanDangleUploads(ctx context.Context, opts CleanupOptions) (CleanupReport, error) {
+	var report CleanupReport
+
+	locked, err := s.transaction.TryLock(ctx, cleanupLockKey, func(ctx context.Context) error {
+		if err := s.retryPartCleanups(ctx, opts, &report); err != nil {
+			return err
+		}
+
+		if err := s.cleanVolumes(ctx, opts, &report); err != nil {
+			return err
+		}
+
+		return s.cleanOldUploads(ctx, opts, &report)
+	})
+
+	if err != nil {
+		return report, fmt.Errorf("unable to clean dangle uploads: %w", err)
+	}
+
+	report.Skipped = !locked
+
+	return report, nil
+}
+
+// retryPartCleanups retries queued cleanups of part data.
+func (s *Service) retryPartCleanups(ctx context.Context, opts CleanupOptions, report *CleanupReport) error {
+	after := uuid.Nil
+
+	for {
+		cleanups, err := s.cleanupRepo.GetPending(ctx, opts.MaxAttempts, after, opts.BatchSize)
+
+		if err != nil {
+			return fmt.Errorf("unable to get part cleanups: %w", err)
+		}
+
+		if len(cleanups) == 0 {
+			return nil
+		}
+
+		after = cleanups[len(cleanups)-1].BlobID
+
+		if opts.DryRun {
+			for _, cleanup := range cleanups {
+				report.CleanedParts++
+				report.ReclaimedBytes += cleanup.Size
+			}
+
+			continue
+		}
+
+		result, err := s.runCleanups(ctx, cleanups)
+		report.add(result)
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// cleanVolumes deletes volumes queued for cleanup once their delete delay is over. A failed cleanup is left
+// in the queue with its error and doesn't stop others.
+func (s *Service) cleanVolumes(ctx context.Context, opts CleanupOptions, report *CleanupReport) error {
+	now := time.Now()
+	after := uuid.Nil
+
+	for {
+		cleanups, err := s.cleanupRepo.GetDueVolumes(ctx, now, opts.MaxAttempts, after, opts.BatchSize)
+
+		if err != nil {
+			return fmt.Errorf("unable to get volume cleanups: %w", err)
+		}
+
+		if len(cleanups) == 0 {
+			return nil
+		}
+
+		after = cleanups[len(cleanups)-1].VolumeID
+
+		for _, cleanup := range cleanups {
+			if opts.DryRun {
+				report.CleanedVolumes++
+				report.ReclaimedBytes += cleanup.Size
+
+				continue
+			}
+
+			if err := s.cleanVolume(ctx, cleanup, report); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Service) cleanVolume(ctx context.Context, cleanup model.VolumeCleanup, report *CleanupReport) error {
+	err := s.storageService.DeleteVolume(ctx, cleanup.VolumeID, cleanup.ServerURL)
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("unable to delete volume: %w", ctx.Err())
+	}
+
+	if err != nil {
+		slog.Warn("Unable to delete volume, it will be retried",
+			"volume", cleanup.VolumeID, "server", cleanup.ServerURL, "attempts", cleanup.Attempts+1, "error", err)
+		report.FailedVolumes++
+
+		if err := s.cleanupRepo.RecordVolumeFailure(ctx, cleanup.VolumeID, err); err != nil {
+			return fmt.Errorf("unable to record volume cleanup failure: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := s.cleanupRepo.DeleteVolume(ctx, cleanup.VolumeID); err != nil {
+		return fmt.Errorf("unable to delete volume cleanup: %w", err)
+	}
+
+	report.CleanedVolumes++
+	report.ReclaimedBytes += cleanup.Size
+	s.metrics.CleanupReclaimedBytes.Add(float64(cleanup.Size))
+
+	return nil
+}
+
+// cleanOldUploads deletes not committed uploads older than maxUploadTime batch by batch.
+func (s *Service) cleanOldUploads(ctx context.Context, opts CleanupOptions, report *CleanupReport) error {
+	from := time.Now().Add(-s.maxUploadTime)
+	after := uuid.Nil
+
+	for {
+		uploads, err := s.uploadRepo.GetOldInFlightUploads(ctx, from, after, opts.BatchSize)
+
+		if err != nil {
+			return fmt.Errorf("unable to get old in flight uploads: %w", err)
+		}
+
+		if len(uploads) == 0 {
+			return nil
+		}
+
+		after = uploads[len(uploads)-1].ID
+		ids := unlockedIDs(uploads)
+
+		if opts.DryRun {
+			if err := s.reportUploads(ctx, ids, report); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		result, err := s.deleteUploads(ctx, ids)
+		report.add(result)
+
+		if err != nil {
+			return err
+		}
+
+		report.Uploads += len(ids)
+		s.metrics.CleanupUploads.Add(float64(len(ids)))
+	}
+}
+
+// reportUploads adds uploads with their parts to the report without deleting them.
+func (s *Service) reportUploads(ctx context.Context, ids []uuid.UUID, report *CleanupReport) error {
+	for _, id := range ids {
+		parts, err := s.partRepo.GetParts(ctx, id)
+
+		if err != nil {
+			return fmt.Errorf("unable to get parts: %w", err)
+		}
+
+		report.Uploads++
+
+		for _, part := range parts {
+			report.CleanedParts++
+			report.ReclaimedBytes += part.CompressedSize
+		}
+	}
+
+	return nil
+}
+
+// cleanParts removes the data of parts from storage servers. It must be called only after the
+// transaction releasing the parts and queuing their cleanups is committed.
+func (s *Service) cleanParts(ctx context.Context, parts []model.Part) (CleanupReport, error) {
+	cleanups := make([]model.PartCleanup, len(parts))
+
+	for i, part := range parts {
+		cleanups[i] = model.PartCleanup{
+			BlobID:    part.BlobID,
+			ServerURL: part.ServerURL,
+			Size:      part.CompressedSize,
+			Attempts:  0,
+			LastError: "",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+	}
+
+	return s.runCleanups(ctx, cleanups)
+}
+
+// runCleanups cleans data of the queued cleanups. A failed cleanup is left in the queue with its error
+// and doesn't stop others, it is retried by the next dangle uploads cleanup.
+func (s *Service) runCleanups(ctx context.Context, cleanups []model.PartCleanup) (CleanupReport, error) {
+	var report CleanupReport
+
+	for _, cleanup := range cleanups {
+		err := s.storageService.CleanPart(ctx, cleanup.BlobID, cleanup.ServerURL)
+
+		if ctx.Err() != nil {
+			return report, fmt.Errorf("unable to clean part: %w", ctx.Err())
+		}
+
+		if err != nil {
+			slog.Warn("Unable to clean part, it will be retried",
+				"blob", cleanup.BlobID, "server", cleanup.ServerURL, "attempts", cleanup.Attempts+1, "error", err)
+			report.FailedParts++
+			s.metrics.CleanupParts.WithLabelValues("failed").Inc()
+
+			if err := s.cleanupRepo.RecordFailure(ctx, cleanup.BlobID, err); err != nil {
+				return report, fmt.Errorf("unable to record part cleanup failure: %w", err)
+			}
+
+			continue
+		}
+
+		if err := s.cleanupRepo.Delete(ctx, cleanup.BlobID); err != nil {
+			return report, fmt.Errorf("unable to delete part cleanup: %w", err)
+		}
+
+		report.CleanedParts++
+		report.ReclaimedBytes += cleanup.Size
+		s.metrics.CleanupParts.WithLabelValues("cleaned").Inc()
+		s.metrics.CleanupReclaimedBytes.Add(float64(cleanup.Size))
+	}
+
+	return report, nil
+}
+
+// unlockedIDs returns IDs of the uploads that can be deleted.
+func unlockedIDs(uploads []model.Upload) []uuid.UUID {
+	now := time.Now()
+	ids := make([]uuid.UUID, 0, len(uploads))
+
+	for _, upload := range uploads {
+		if upload.IsLocked(now) {
+			continue
+		}
+
+		ids = append(ids, upload.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,65 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/quolpr/distributeds3/internal/service/storage"
+	storageRepo "github.com/quolpr/distributeds3/internal/service/storage/repo"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+)
+
+// ServerStatus summarises a storage server: its health, usage reported by the server and parts placed on it.
+type ServerStatus struct {
+	Health storage.ServerHealth
+	Stat   storageRepo.ServerStat
+	// StatErr is set if the server couldn't report its usage
+	StatErr error
+	Parts   model.ServerPartStats
+	// Draining servers get no new parts
+	Draining bool
+}
+
+// ClusterStatus returns the status of every storage server. An unreachable server doesn't fail the status,
+// its StatErr is set instead.
+func (s *Service) ClusterStatus(ctx context.Context) ([]ServerStatus, error) {
+	servers, err := s.storageService.ServersHealth(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get servers health: %w", err)
+	}
+
+	partStats, err := s.partRepo.GetServerStats(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get part stats: %w", err)
+	}
+
+	draining, err := s.drainingServers(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make(map[string]model.ServerPartStats, len(partStats))
+	for _, stats := range partStats {
+		parts[stats.ServerURL] = stats
+	}
+
+	statuses := make([]ServerStatus, len(servers))
+
+	for i, server := range servers {
+		stat, err := s.storageService.Stat(ctx, server.ServerURL)
+
+		statuses[i] = ServerStatus{
+			Health:   server,
+			Stat:     stat,
+			StatErr:  err,
+			Parts:    parts[server.ServerURL],
+			Draining: draining[server.ServerURL],
+		}
+		statuses[i].Parts.ServerURL = server.ServerURL
+	}
+
+	return statuses, nil
+}
@@ -0,0 +1,180 @@
+package upload
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+)
+
+// uploadDedupPart stores the part data once per content: a part with the same content as
+// an existing blob references it instead of storing the data again.
+func (s *Service) uploadDedupPart(ctx context.Context, part model.Part, data []byte) error {
+	hash := sha256.Sum256(data)
+
+	referenced, err := s.referenceBlob(ctx, part, hash[:])
+
+	if err != nil || referenced {
+		return err
+	}
+
+	part, err = s.storePart(ctx, part, dataKey{}, data) //nolint:exhaustruct
+
+	if err != nil {
+		return err
+	}
+
+	blob := model.Blob{
+		ID:             part.BlobID,
+		ServerURL:      part.ServerURL,
+		Hash:           hash[:],
+		Codec:          part.Codec,
+		Size:           part.Size,
+		CompressedSize: part.CompressedSize,
+		RefCount:       1,
+		CreatedAt:      time.Now(),
+	}
+
+	err = s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		blob, err = s.blobRepo.WithTx(tx).Acquire(ctx, blob)
+
+		if err != nil {
+			return fmt.Errorf("unable to acquire blob: %w", err)
+		}
+
+		return s.partRepo.WithTx(tx).MarkPartAsWritten(ctx, withBlob(part, blob)) //nolint:wrapcheck
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to save part blob: %w", err)
+	}
+
+	// The same content was stored concurrently, only one copy is kept
+	if blob.ID != part.BlobID {
+		return s.storageService.CleanPart(ctx, part.BlobID, part.ServerURL) //nolint:wrapcheck
+	}
+
+	return nil
+}
+
+// referenceBlob marks the part as written if a blob with the same content exists.
+func (s *Service) referenceBlob(ctx context.Context, part model.Part, hash []byte) (bool, error) {
+	referenced := false
+
+	err := s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		blob, err := s.blobRepo.WithTx(tx).Reference(ctx, hash, part.Codec)
+
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to reference blob: %w", err)
+		}
+
+		referenced = true
+
+		return s.partRepo.WithTx(tx).MarkPartAsWritten(ctx, withBlob(part, blob)) //nolint:wrapcheck
+	})
+
+	if err != nil {
+		return false, fmt.Errorf("unable to reference blob: %w", err)
+	}
+
+	return referenced, nil
+}
+
+func withBlob(part model.Part, blob model.Blob) model.Part {
+	part.BlobID = blob.ID
+	part.ServerURL = blob.ServerURL
+	part.CompressedSize = blob.CompressedSize
+
+	return part
+}
+
+// deleteUploads removes uploads with their parts and cleans the data no part references anymore.
+func (s *Service) deleteUploads(ctx context.Context, ids []uuid.UUID) (CleanupReport, error) {
+	var unreferenced []model.Part
+
+	err := s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		for _, id := range ids {
+			parts, err := s.partRepo.WithTx(tx).GetParts(ctx, id)
+
+			if err != nil {
+				return fmt.Errorf("unable to get parts: %w", err)
+			}
+
+			released, err := s.releaseParts(ctx, tx, parts)
+
+			if err != nil {
+				return err
+			}
+
+			unreferenced = append(unreferenced, released...)
+		}
+
+		// Due to cascade deletes in parts table, parts will be deleted too
+		if err := s.uploadRepo.WithTx(tx).DeleteUploadByIDs(ctx, ids); err != nil {
+			return fmt.Errorf("unable to delete uploads: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return CleanupReport{}, fmt.Errorf("unable to delete uploads: %w", err) //nolint:exhaustruct
+	}
+
+	return s.cleanParts(ctx, unreferenced)
+}
+
+// releaseParts drops references of the parts to their data and returns parts
+// whose data is not referenced anymore. Cleanups of their data are queued in the same transaction.
+func (s *Service) releaseParts(ctx context.Context, tx pgx.Tx, parts []model.Part) ([]model.Part, error) {
+	unreferenced := make([]model.Part, 0, len(parts))
+
+	for _, part := range parts {
+		// Needles of packed parts are reclaimed by volume compaction
+		if part.IsPacked() {
+			if err := s.volumeRepo.WithTx(tx).ReleaseNeedle(ctx, part.VolumeID, part.VolumeLength); err != nil {
+				return nil, fmt.Errorf("unable to release needle: %w", err)
+			}
+
+			continue
+		}
+
+		released, err := s.blobRepo.WithTx(tx).Release(ctx, part.BlobID)
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to release blob: %w", err)
+		}
+
+		if !released {
+			continue
+		}
+
+		err = s.cleanupRepo.WithTx(tx).Enqueue(ctx, model.PartCleanup{
+			BlobID:    part.BlobID,
+			ServerURL: part.ServerURL,
+			Size:      part.CompressedSize,
+			Attempts:  0,
+			LastError: "",
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+
+		if err != nil {
+			return nil, fmt.Errorf("unable to enqueue part cleanup: %w", err)
+		}
+
+		unreferenced = append(unreferenced, part)
+	}
+
+	return unreferenced, nil
+}
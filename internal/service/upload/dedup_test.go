@@ -0,0 +1,194 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/kms"
+	"github.com/quolpr/distributeds3/internal/service/storage/repo/inmemstorage"
+	"github.com/quolpr/distributeds3/pkg/encryption"
+)
+
+// dedupData is split into 3 parts of different content.
+const dedupData = "first part data.second part datathird part"
+
+var errClean = errors.New("clean failed")
+
+// failingCleanRepo fails to clean parts while failClean is set.
+type failingCleanRepo struct {
+	*inmemstorage.InmemRepo
+
+	failClean bool
+}
+
+func (r *failingCleanRepo) CleanPart(ctx context.Context, id uuid.UUID, serverURL string) error {
+	if r.failClean {
+		return errClean
+	}
+
+	return r.InmemRepo.CleanPart(ctx, id, serverURL) //nolint:wrapcheck
+}
+
+// testKMS keeps data keys as is, it is enough to turn encryption on.
+type testKMS struct{}
+
+func (testKMS) Wrap(_ context.Context, dataKey []byte) (string, []byte, error) {
+	return "test-key", dataKey, nil
+}
+
+func (testKMS) Unwrap(_ context.Context, _ string, wrapped []byte) ([]byte, error) {
+	return wrapped, nil
+}
+
+func (testKMS) ActiveKeyID(context.Context) (string, error) {
+	return "test-key", nil
+}
+
+func storedParts(t *testing.T, storage *inmemstorage.InmemRepo) int {
+	t.Helper()
+
+	parts, err := storage.ListParts(context.Background(), testServer)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return len(parts)
+}
+
+func TestUploadsShareBlobs(t *testing.T) {
+	storage := inmemstorage.NewInmemRepo([]string{testServer})
+	env := newTestEnv(t, storage, nil)
+
+	first := env.upload(t, "first", dedupData, UploadOptions{})
+	second := env.upload(t, "second", dedupData, UploadOptions{})
+
+	if n := env.count(t, "select count(*) from blobs where ref_count = 2"); n != 3 {
+		t.Errorf("expected 3 blobs referenced twice, got %d", n)
+	}
+
+	if n := env.count(t, "select count(*) from blobs"); n != 3 {
+		t.Errorf("expected 3 blobs, got %d", n)
+	}
+
+	if n := storedParts(t, storage); n != 3 {
+		t.Errorf("expected 3 stored parts, got %d", n)
+	}
+
+	for _, upload := range []uuid.UUID{first.ID, second.ID} {
+		if got := env.read(t, upload); got != dedupData {
+			t.Errorf("unexpected data of upload %s: %q", upload, got)
+		}
+	}
+}
+
+func TestDeletingOneUploadKeepsSharedBlobs(t *testing.T) {
+	storage := inmemstorage.NewInmemRepo([]string{testServer})
+	env := newTestEnv(t, storage, nil)
+
+	first := env.upload(t, "first", dedupData, UploadOptions{})
+	second := env.upload(t, "second", dedupData, UploadOptions{})
+
+	if err := env.svc.DeleteObjectVersion(context.Background(), testBucket, "first", first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := env.count(t, "select count(*) from blobs where ref_count = 1"); n != 3 {
+		t.Errorf("expected 3 blobs referenced once, got %d", n)
+	}
+
+	if n := env.count(t, "select count(*) from part_cleanups"); n != 0 {
+		t.Errorf("expected no queued cleanups, got %d", n)
+	}
+
+	if n := storedParts(t, storage); n != 3 {
+		t.Errorf("expected 3 stored parts, got %d", n)
+	}
+
+	if got := env.read(t, second.ID); got != dedupData {
+		t.Errorf("unexpected data of the remaining upload %q", got)
+	}
+}
+
+func TestDeletingAllUploadsQueuesBlobCleanup(t *testing.T) {
+	storage := &failingCleanRepo{InmemRepo: inmemstorage.NewInmemRepo([]string{testServer}), failClean: true}
+	env := newTestEnv(t, storage, nil)
+	ctx := context.Background()
+
+	first := env.upload(t, "first", dedupData, UploadOptions{})
+	second := env.upload(t, "second", dedupData, UploadOptions{})
+
+	if err := env.svc.DeleteObjectVersion(ctx, testBucket, "first", first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Cleaning fails, so the queued cleanups stay in the queue
+	if err := env.svc.DeleteObjectVersion(ctx, testBucket, "second", second.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := env.count(t, "select count(*) from blobs"); n != 0 {
+		t.Errorf("expected no blobs, got %d", n)
+	}
+
+	if n := env.count(t, "select count(*) from part_cleanups where attempts = 1"); n != 3 {
+		t.Fatalf("expected 3 queued cleanups with a failed attempt, got %d", n)
+	}
+
+	storage.failClean = false
+
+	report, err := env.svc.CleanDangleUploads(ctx, DefaultCleanupOptions())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.CleanedParts != 3 {
+		t.Errorf("expected 3 cleaned parts, got %d", report.CleanedParts)
+	}
+
+	if n := env.count(t, "select count(*) from part_cleanups"); n != 0 {
+		t.Errorf("expected empty cleanup queue, got %d", n)
+	}
+
+	if n := storedParts(t, storage.InmemRepo); n != 0 {
+		t.Errorf("expected no stored parts, got %d", n)
+	}
+}
+
+func TestEncryptedUploadsAreNotDeduplicated(t *testing.T) {
+	customerKey, err := encryption.NewDataKey()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		kms  kms.KMS
+		opts UploadOptions
+	}{
+		{name: "server-side encryption", kms: testKMS{}, opts: UploadOptions{}},
+		{name: "customer key", kms: nil, opts: UploadOptions{CustomerKey: customerKey}}, //nolint:exhaustruct
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := inmemstorage.NewInmemRepo([]string{testServer})
+			env := newTestEnv(t, storage, tt.kms)
+
+			env.upload(t, "first", dedupData, tt.opts)
+			env.upload(t, "second", dedupData, tt.opts)
+
+			if n := env.count(t, "select count(*) from blobs"); n != 0 {
+				t.Errorf("expected no blobs, got %d", n)
+			}
+
+			if n := storedParts(t, storage); n != 6 {
+				t.Errorf("expected 6 stored parts, got %d", n)
+			}
+		})
+	}
+}
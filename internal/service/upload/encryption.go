@@ -0,0 +1,206 @@
+package upload
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"fmt"
+	"io"
+
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/pkg/encryption"
+)
+
+const (
+	rewrapBatchSize = 100
+	fingerprintSalt = 16
+
+	customerKeyFingerprintLabel = "sse-c fingerprint"
+	customerDataKeyLabel        = "sse-c data key"
+)
+
+var ErrInvalidCustomerKey = apperror.New(
+	apperror.KindInvalidInput, "invalid_customer_key", "invalid customer encryption key",
+)
+
+// dataKey is a per-upload key parts are encrypted with. Zero value means that parts are not encrypted.
+type dataKey struct {
+	plaintext []byte
+	// encrypted and masterKeyID are set for the envelope encryption with KMS.
+	encrypted   []byte
+	masterKeyID string
+	// customerKeyFingerprint is set if the key is derived from the customer provided key.
+	customerKeyFingerprint []byte
+}
+
+// newDataKey generates a data key for a new upload. A customer key takes precedence over
+// the server-side encryption.
+func (s *Service) newDataKey(ctx context.Context, customerKey []byte) (dataKey, error) {
+	if customerKey != nil {
+		return newCustomerDataKey(customerKey)
+	}
+
+	if s.kms == nil {
+		return dataKey{}, nil
+	}
+
+	plaintext, err := encryption.NewDataKey()
+
+	if err != nil {
+		return dataKey{}, fmt.Errorf("unable to generate data key: %w", err)
+	}
+
+	masterKeyID, encrypted, err := s.kms.Wrap(ctx, plaintext)
+
+	if err != nil {
+		return dataKey{}, fmt.Errorf("unable to wrap data key: %w", err)
+	}
+
+	return dataKey{
+		plaintext:              plaintext,
+		encrypted:              encrypted,
+		masterKeyID:            masterKeyID,
+		customerKeyFingerprint: nil,
+	}, nil
+}
+
+// newCustomerDataKey derives the data key from the customer key with a random salt. Only the salted
+// fingerprint is stored, so the same customer key doesn't produce the same fingerprint twice.
+func newCustomerDataKey(customerKey []byte) (dataKey, error) {
+	if len(customerKey) != encryption.KeySize {
+		return dataKey{}, fmt.Errorf("%w: key must be %d bytes", ErrInvalidCustomerKey, encryption.KeySize)
+	}
+
+	salt := make([]byte, fingerprintSalt)
+
+	if _, err := rand.Read(salt); err != nil {
+		return dataKey{}, fmt.Errorf("unable to generate salt: %w", err)
+	}
+
+	return customerDataKey(customerKey, salt), nil
+}
+
+func customerDataKey(customerKey, salt []byte) dataKey {
+	fingerprint := encryption.DeriveKey(customerKey, customerKeyFingerprintLabel, salt)
+
+	return dataKey{
+		plaintext:              encryption.DeriveKey(customerKey, customerDataKeyLabel, salt),
+		encrypted:              nil,
+		masterKeyID:            "",
+		customerKeyFingerprint: append(salt[:len(salt):len(salt)], fingerprint...),
+	}
+}
+
+// uploadDataKey returns the data key of the upload. The customer key must be passed
+// for uploads encrypted with it and must be nil for others.
+func (s *Service) uploadDataKey(ctx context.Context, upload model.Upload, customerKey []byte) (dataKey, error) {
+	if len(upload.CustomerKeyFingerprint) > 0 {
+		if customerKey == nil {
+			return dataKey{}, fmt.Errorf("%w: upload is encrypted with a customer key", ErrInvalidCustomerKey)
+		}
+
+		if len(upload.CustomerKeyFingerprint) <= fingerprintSalt {
+			return dataKey{}, fmt.Errorf("upload %s has malformed key fingerprint", upload.ID)
+		}
+
+		key := customerDataKey(customerKey, upload.CustomerKeyFingerprint[:fingerprintSalt])
+
+		if !hmac.Equal(key.customerKeyFingerprint, upload.CustomerKeyFingerprint) {
+			return dataKey{}, fmt.Errorf("%w: key doesn't match", ErrInvalidCustomerKey)
+		}
+
+		return key, nil
+	}
+
+	if customerKey != nil {
+		return dataKey{}, fmt.Errorf("%w: upload is not encrypted with a customer key", ErrInvalidCustomerKey)
+	}
+
+	if len(upload.EncryptedDataKey) == 0 {
+		return dataKey{}, nil
+	}
+
+	if s.kms == nil {
+		return dataKey{}, fmt.Errorf("upload %s is encrypted, but KMS is not configured", upload.ID)
+	}
+
+	plaintext, err := s.kms.Unwrap(ctx, upload.MasterKeyID, upload.EncryptedDataKey)
+
+	if err != nil {
+		return dataKey{}, fmt.Errorf("unable to unwrap data key: %w", err)
+	}
+
+	return dataKey{
+		plaintext:              plaintext,
+		encrypted:              upload.EncryptedDataKey,
+		masterKeyID:            upload.MasterKeyID,
+		customerKeyFingerprint: nil,
+	}, nil
+}
+
+func (k dataKey) encryptPart(reader io.Reader, part model.Part) (io.Reader, error) {
+	if k.plaintext == nil {
+		return reader, nil
+	}
+
+	reader, err := encryption.NewEncryptReader(reader, k.plaintext, uint32(part.Number))
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to encrypt part: %w", err)
+	}
+
+	return reader, nil
+}
+
+// RewrapDataKeys re-encrypts data keys wrapped by rotated master keys with the active one.
+// Parts themselves are not touched. Returns the number of rewrapped uploads.
+func (s *Service) RewrapDataKeys(ctx context.Context) (int, error) {
+	if s.kms == nil {
+		return 0, fmt.Errorf("KMS is not configured")
+	}
+
+	activeKeyID, err := s.kms.ActiveKeyID(ctx)
+
+	if err != nil {
+		return 0, fmt.Errorf("unable to get active master key: %w", err)
+	}
+
+	rewrapped := 0
+
+	for {
+		uploads, err := s.uploadRepo.GetUploadsWithStaleDataKey(ctx, activeKeyID, rewrapBatchSize)
+
+		if err != nil {
+			return rewrapped, fmt.Errorf("unable to get uploads: %w", err)
+		}
+
+		if len(uploads) == 0 {
+			return rewrapped, nil
+		}
+
+		for _, upload := range uploads {
+			key, err := s.uploadDataKey(ctx, upload, nil)
+
+			if err != nil {
+				return rewrapped, err
+			}
+
+			masterKeyID, encrypted, err := s.kms.Wrap(ctx, key.plaintext)
+
+			if err != nil {
+				return rewrapped, fmt.Errorf("unable to wrap data key: %w", err)
+			}
+
+			if masterKeyID != activeKeyID {
+				return rewrapped, fmt.Errorf("active master key changed during rewrap")
+			}
+
+			if err := s.uploadRepo.SetDataKey(ctx, upload.ID, encrypted, masterKeyID); err != nil {
+				return rewrapped, fmt.Errorf("unable to set data key: %w", err)
+			}
+
+			rewrapped++
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	storageRepo "github.com/quolpr/distributeds3/internal/service/storage/repo"
+)
+
+// gcBatchSize is the number of inventory objects checked against the database by one query.
+const gcBatchSize = 1000
+
+// GCOptions are parameters of a garbage collection run.
+type GCOptions struct {
+	// GracePeriod protects recently written data: it may belong to an upload that is not persisted yet
+	GracePeriod time.Duration
+	// DryRun only reports orphaned data without deleting it
+	DryRun bool
+}
+
+// GCReport holds the result of a garbage collection run.
+type GCReport struct {
+	Servers         int
+	Scanned         int
+	OrphanedParts   int
+	OrphanedVolumes int
+	OrphanedBytes   int64
+	// FailedServers are skipped servers, their inventory couldn't be listed or cleaned
+	FailedServers []string
+}
+
+// CollectGarbage deletes parts and volumes stored on storage servers that no part references,
+// e.g. data of a part whose write succeeded, but the database update failed.
+// Data modified within the grace period is kept.
+func (s *Service) CollectGarbage(ctx context.Context, opts GCOptions) (GCReport, error) {
+	var report GCReport
+
+	servers, err := s.storageService.GetAvailableServers(ctx)
+
+	if err != nil {
+		return report, fmt.Errorf("unable to get available servers: %w", err)
+	}
+
+	for _, serverURL := range servers {
+		report.Servers++
+
+		if err := s.collectServerGarbage(ctx, serverURL, opts, &report); err != nil {
+			if ctx.Err() != nil {
+				return report, fmt.Errorf("unable to collect garbage of %s: %w", serverURL, err)
+			}
+
+			// One failed server shouldn't stop cleaning others
+			slog.Error("Unable to collect garbage", "server", serverURL, "error", err)
+			report.FailedServers = append(report.FailedServers, serverURL)
+		}
+	}
+
+	return report, nil
+}
+
+func (s *Service) collectServerGarbage(ctx context.Context, serverURL string, opts GCOptions, report *GCReport) error {
+	modifiedBefore := time.Now().Add(-opts.GracePeriod)
+
+	parts, err := s.storageService.ListParts(ctx, serverURL)
+
+	if err != nil {
+		return fmt.Errorf("unable to list parts: %w", err)
+	}
+
+	orphanedParts, err := s.orphaned(ctx, serverURL, parts, modifiedBefore, s.blobRepo.GetStoredIDs)
+
+	if err != nil {
+		return fmt.Errorf("unable to find orphaned parts: %w", err)
+	}
+
+	volumes, err := s.storageService.ListVolumes(ctx, serverURL)
+
+	if err != nil {
+		return fmt.Errorf("unable to list volumes: %w", err)
+	}
+
+	orphanedVolumes, err := s.orphaned(ctx, serverURL, volumes, modifiedBefore, s.volumeRepo.GetStoredIDs)
+
+	if err != nil {
+		return fmt.Errorf("unable to find orphaned volumes: %w", err)
+	}
+
+	report.Scanned += len(parts) + len(volumes)
+	report.OrphanedParts += len(orphanedParts)
+	report.OrphanedVolumes += len(orphanedVolumes)
+
+	for _, part := range orphanedParts {
+		report.OrphanedBytes += part.Size
+		slog.Info("Orphaned part", "server", serverURL, "id", part.ID, "size", part.Size, "dry_run", opts.DryRun)
+
+		if opts.DryRun {
+			continue
+		}
+
+		if err := s.storageService.CleanPart(ctx, part.ID, serverURL); err != nil {
+			return fmt.Errorf("unable to clean part %s: %w", part.ID, err)
+		}
+	}
+
+	for _, volume := range orphanedVolumes {
+		report.OrphanedBytes += volume.Size
+		slog.Info("Orphaned volume", "server", serverURL, "id", volume.ID, "size", volume.Size, "dry_run", opts.DryRun)
+
+		if opts.DryRun {
+			continue
+		}
+
+		if err := s.storageService.DeleteVolume(ctx, volume.ID, serverURL); err != nil {
+			return fmt.Errorf("unable to delete volume %s: %w", volume.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// orphaned returns objects older than modifiedBefore that are not stored according to the database.
+func (s *Service) orphaned(
+	ctx context.Context, serverURL string, inventory []storageRepo.Object, modifiedBefore time.Time,
+	getStored func(ctx context.Context, serverURL string, ids []uuid.UUID) ([]uuid.UUID, error),
+) ([]storageRepo.Object, error) {
+	var orphaned []storageRepo.Object
+
+	for start := 0; start < len(inventory); start += gcBatchSize {
+		batch := inventory[start:min(start+gcBatchSize, len(inventory))]
+		ids := make([]uuid.UUID, 0, len(batch))
+
+		for _, object := range batch {
+			if object.ModifiedAt.Before(modifiedBefore) {
+				ids = append(ids, object.ID)
+			}
+		}
+
+		if len(ids) == 0 {
+			continue
+		}
+
+		stored, err := getStored(ctx, serverURL, ids)
+
+		if err != nil {
+			return nil, err
+		}
+
+		storedSet := make(map[uuid.UUID]struct{}, len(stored))
+		for _, id := range stored {
+			storedSet[id] = struct{}{}
+		}
+
+		for _, object := range batch {
+			if _, ok := storedSet[object.ID]; !ok && object.ModifiedAt.Before(modifiedBefore) {
+				orphaned = append(orphaned, object)
+			}
+		}
+	}
+
+	return orphaned, nil
+}
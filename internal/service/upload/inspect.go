@@ -0,0 +1,80 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+)
+
+var ErrUploadInFlight = apperror.New(apperror.KindConflict, "upload_in_flight", "upload is being written")
+
+// ListUploads returns up to maxCount uploads of all statuses, ordered by ID starting after the given one.
+// Empty bucket means all buckets.
+func (s *Service) ListUploads(
+	ctx context.Context, bucket, prefix string, after uuid.UUID, maxCount int32,
+) ([]model.Upload, error) {
+	uploads, err := s.uploadRepo.ListUploads(ctx, bucket, prefix, after, maxCount)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to list uploads: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// UploadParts returns the upload with its parts, so it can be seen where the data is placed.
+func (s *Service) UploadParts(ctx context.Context, id uuid.UUID) (model.Upload, []model.Part, error) {
+	upload, err := s.uploadRepo.GetUpload(ctx, id)
+
+	if errors.Is(err, repo.ErrNotFound) {
+		return model.Upload{}, nil, ErrObjectNotFound
+	}
+
+	if err != nil {
+		return model.Upload{}, nil, fmt.Errorf("unable to get upload: %w", err)
+	}
+
+	parts, err := s.partRepo.GetParts(ctx, id)
+
+	if err != nil {
+		return model.Upload{}, nil, fmt.Errorf("unable to get parts: %w", err)
+	}
+
+	return upload, parts, nil
+}
+
+// DeleteUpload removes the upload with its parts. Committed uploads are deleted as object versions,
+// failed ones are rolled back. Uploads still being written can't be deleted.
+func (s *Service) DeleteUpload(ctx context.Context, id uuid.UUID) error {
+	upload, err := s.uploadRepo.GetUpload(ctx, id)
+
+	if errors.Is(err, repo.ErrNotFound) {
+		return ErrObjectNotFound
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to get upload: %w", err)
+	}
+
+	switch upload.Status {
+	case model.UploadStatusCommitted:
+		return s.DeleteObjectVersion(ctx, upload.Bucket, upload.Name, upload.ID)
+	case model.UploadStatusFailed, model.UploadStatusAborting:
+		err := s.rollbackUpload(ctx, upload)
+
+		if errors.Is(err, repo.ErrConcurrentUpdate) {
+			return fmt.Errorf("%w: upload changed while it was deleted", ErrUploadInFlight)
+		}
+
+		return err
+	case model.UploadStatusPending, model.UploadStatusWriting, model.UploadStatusWritten:
+		return fmt.Errorf("%w: upload is %s", ErrUploadInFlight, upload.Status)
+	}
+
+	return fmt.Errorf("upload %s has unknown status %s", upload.ID, upload.Status)
+}
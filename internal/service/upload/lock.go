@@ -0,0 +1,94 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+)
+
+var ErrInvalidRetention = apperror.New(apperror.KindInvalidInput, "invalid_retention", "invalid retention")
+
+// SetRetention protects the version from deletion and overwriting until retainUntil.
+// Retention can only be extended, uuid.Nil versionID means the latest version.
+func (s *Service) SetRetention(
+	ctx context.Context, bucket, key string, versionID uuid.UUID, retainUntil time.Time,
+) (model.Upload, error) {
+	if !retainUntil.After(time.Now()) {
+		return model.Upload{}, fmt.Errorf("%w: retain until date must be in the future", ErrInvalidRetention)
+	}
+
+	return s.updateLock(ctx, bucket, key, versionID, func(uploadRepo *repo.UploadRepo, upload *model.Upload) error {
+		if retainUntil.Before(upload.RetainUntil) {
+			return fmt.Errorf("%w: retention can't be shortened", ErrObjectLocked)
+		}
+
+		if err := uploadRepo.SetRetention(ctx, upload.ID, retainUntil); err != nil {
+			return fmt.Errorf("unable to set retention: %w", err)
+		}
+
+		upload.RetainUntil = retainUntil
+
+		return nil
+	})
+}
+
+// SetLegalHold places or releases a legal hold of the version. uuid.Nil versionID means the latest version.
+func (s *Service) SetLegalHold(
+	ctx context.Context, bucket, key string, versionID uuid.UUID, legalHold bool,
+) (model.Upload, error) {
+	return s.updateLock(ctx, bucket, key, versionID, func(uploadRepo *repo.UploadRepo, upload *model.Upload) error {
+		if err := uploadRepo.SetLegalHold(ctx, upload.ID, legalHold); err != nil {
+			return fmt.Errorf("unable to set legal hold: %w", err)
+		}
+
+		upload.LegalHold = legalHold
+
+		return nil
+	})
+}
+
+func (s *Service) updateLock(
+	ctx context.Context, bucket, key string, versionID uuid.UUID,
+	update func(uploadRepo *repo.UploadRepo, upload *model.Upload) error,
+) (model.Upload, error) {
+	var upload model.Upload
+
+	err := s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		uploadRepo := s.uploadRepo.WithTx(tx)
+
+		if err := uploadRepo.LockKey(ctx, bucket, key); err != nil {
+			return fmt.Errorf("unable to lock object: %w", err)
+		}
+
+		var err error
+
+		if versionID == uuid.Nil {
+			upload, err = uploadRepo.GetLatestUpload(ctx, bucket, key)
+		} else {
+			upload, err = s.getVersion(ctx, uploadRepo, bucket, key, versionID)
+		}
+
+		if errors.Is(err, repo.ErrNotFound) || (err == nil && upload.IsDeleteMarker) {
+			return ErrObjectNotFound
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to get object version: %w", err)
+		}
+
+		return update(uploadRepo, &upload)
+	})
+
+	if err != nil {
+		return model.Upload{}, fmt.Errorf("unable to update object lock: %w", err)
+	}
+
+	return upload, nil
+}
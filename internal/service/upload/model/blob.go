@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/pkg/compression"
+)
+
+// Blob is a deduplicated part data stored once and shared by parts with the same content.
+type Blob struct {
+	// ID is the storage ID of the data, it is the ID of the part that uploaded it first
+	ID        uuid.UUID
+	ServerURL string
+	// Hash is SHA-256 of the original data
+	Hash           []byte
+	Codec          compression.Codec
+	Size           int64
+	CompressedSize int64
+	// RefCount is the number of parts referencing the blob, the data is removed when it drops to zero
+	RefCount  int32
+	CreatedAt time.Time
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PartCleanup is a pending removal of part data no part references anymore. It is queued in the transaction
+// releasing the data and removed once the data is cleaned from its storage server.
+type PartCleanup struct {
+	BlobID    uuid.UUID
+	ServerURL string
+	// Size is the size of the stored data
+	Size int64
+	// Attempts is the number of failed cleanups, LastError is the error of the last one
+	Attempts  int32
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// VolumeCleanup is a pending removal of a volume no part references anymore. The volume is kept till
+// DeleteAfter, so reads that found their needles in it before the parts were moved can finish.
+type VolumeCleanup struct {
+	VolumeID  uuid.UUID
+	ServerURL string
+	// Size is the size of the stored volume
+	Size        int64
+	DeleteAfter time.Time
+	// Attempts is the number of failed cleanups, LastError is the error of the last one
+	Attempts  int32
+	LastError string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/pkg/compression"
 )
 
 type Part struct {
@@ -11,7 +12,30 @@ type Part struct {
 	ServerURL string
 	UploadID  uuid.UUID
 	Number    int32
-	Size      int64
-	CreatedAt time.Time
-	Status    UploadStatus
+	// Size is the size of the original data
+	Size int64
+	// Codec the part data is compressed with before encryption
+	Codec compression.Codec
+	// CompressedSize is the size of the data after compression, it is known after the part is uploaded
+	CompressedSize int64
+	// BlobID is the storage ID of the part data. It equals ID unless the data is shared with other parts
+	BlobID uuid.UUID
+	// VolumeID is set if the part is packed into a volume at VolumeOffset, it is uuid.Nil otherwise
+	VolumeID     uuid.UUID
+	VolumeOffset int64
+	// VolumeLength is the size of the part data as it is stored in the volume
+	VolumeLength int64
+	CreatedAt    time.Time
+	Status       UploadStatus
+}
+
+func (p Part) IsPacked() bool {
+	return p.VolumeID != uuid.Nil
+}
+
+// ServerPartStats is the number of parts placed on a storage server and the size of their compressed data.
+type ServerPartStats struct {
+	ServerURL string
+	Parts     int64
+	Bytes     int64
 }
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// StorageServer is an operator's setting of a storage server. Draining servers get no new parts,
+// their parts are moved to other servers by rebalancing.
+type StorageServer struct {
+	URL       string
+	Draining  bool
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
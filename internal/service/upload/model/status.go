@@ -1,8 +1,21 @@
 package model
 
+// UploadStatus is a state of an upload or a part. Uploads go
+// pending → writing → written → committed, a failed upload goes failed → aborting and is deleted.
+// Parts go pending → writing → written and are committed together with their upload.
 type UploadStatus string
 
 const (
-	UploadStatusInProgress UploadStatus = "in_progress"
-	UploadStatusDone       UploadStatus = "done"
+	// UploadStatusPending is created, but no data is written yet.
+	UploadStatusPending UploadStatus = "pending"
+	// UploadStatusWriting data is being written to storage servers.
+	UploadStatusWriting UploadStatus = "writing"
+	// UploadStatusWritten all data is stored, but the version is not visible yet.
+	UploadStatusWritten UploadStatus = "written"
+	// UploadStatusCommitted version is visible.
+	UploadStatusCommitted UploadStatus = "committed"
+	// UploadStatusFailed writing failed, the upload must be rolled back.
+	UploadStatusFailed UploadStatus = "failed"
+	// UploadStatusAborting upload data is being removed.
+	UploadStatusAborting UploadStatus = "aborting"
 )
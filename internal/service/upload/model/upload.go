@@ -6,10 +6,37 @@ import (
 	"github.com/google/uuid"
 )
 
+// Upload is a single version of an object. Its ID doubles as the version ID.
 type Upload struct {
-	ID        uuid.UUID
-	Name      string
-	Size      int64
-	Status    UploadStatus
+	ID             uuid.UUID
+	Bucket         string
+	Name           string
+	Size           int64
+	Status         UploadStatus
+	IsLatest       bool
+	IsDeleteMarker bool
+	// RetainUntil is a WORM retention date, zero value means no retention.
+	RetainUntil time.Time
+	// LegalHold protects the version until it is explicitly released.
+	LegalHold bool
+	// EncryptedDataKey is the key parts are encrypted with, wrapped by the MasterKeyID key of KMS.
+	// Parts are stored in plaintext if it is empty.
+	EncryptedDataKey []byte
+	MasterKeyID      string
+	// CustomerKeyFingerprint is set if parts are encrypted with a key provided by the client (SSE-C).
+	// The key itself is never stored.
+	CustomerKeyFingerprint []byte
+	// ContentMD5 is the MD5 digest of the object data, it is known once the data is written.
+	// It is empty for delete markers.
+	ContentMD5 []byte
+	// Owner is the ID of the API instance writing the upload, it rolls back uploads left in flight after restart.
+	Owner     string
 	CreatedAt time.Time
+	// UpdatedAt is the time of the last progress of an upload in flight.
+	UpdatedAt time.Time
+}
+
+// IsLocked reports whether the version can't be deleted or overwritten at the moment.
+func (u Upload) IsLocked(now time.Time) bool {
+	return u.LegalHold || u.RetainUntil.After(now)
 }
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Volume is a large file on a storage server small parts are appended to (needles).
+type Volume struct {
+	ID        uuid.UUID
+	ServerURL string
+	// Size is the end of the last appended needle
+	Size int64
+	// LiveSize is the size of needles of not deleted parts, the rest is reclaimed by compaction
+	LiveSize int64
+	// Sealed volume gets no new needles
+	Sealed    bool
+	CreatedAt time.Time
+}
+
+// GarbageRatio returns the share of the volume taken by deleted needles.
+func (v Volume) GarbageRatio() float64 {
+	if v.Size == 0 {
+		return 0
+	}
+
+	return float64(v.Size-v.LiveSize) / float64(v.Size)
+}
@@ -0,0 +1,253 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/quolpr/distributeds3/internal/service/storage"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/pkg/compression"
+	"github.com/quolpr/distributeds3/pkg/encryption"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxPartPlacements is the number of servers a part write is tried on before the upload fails.
+const maxPartPlacements = 3
+
+// uploadPart stores the part data and marks the part as written. Data of not encrypted parts is deduplicated,
+// encrypted ones never match as every upload has its own data key.
+func (s *Service) uploadPart(ctx context.Context, part model.Part, key dataKey, data []byte) error {
+	if err := s.startPart(ctx, part); err != nil {
+		return err
+	}
+
+	if key.plaintext == nil {
+		return s.uploadDedupPart(ctx, part, data)
+	}
+
+	part, err := s.storePart(ctx, part, key, data)
+
+	if err != nil {
+		return err
+	}
+
+	return s.partRepo.MarkPartAsWritten(ctx, part) //nolint:wrapcheck
+}
+
+// startPart marks the part as writing and records progress of its upload, so the upload is not
+// recovered as stale while it is written.
+func (s *Service) startPart(ctx context.Context, part model.Part) error {
+	if err := s.partRepo.SetStatus(ctx, part.ID, model.UploadStatusWriting); err != nil {
+		return fmt.Errorf("unable to mark part as writing: %w", err)
+	}
+
+	if err := s.uploadRepo.Touch(ctx, part.UploadID, s.recovery.InstanceID); err != nil {
+		return fmt.Errorf("unable to touch upload: %w", err)
+	}
+
+	return nil
+}
+
+// storePart compresses, encrypts and sends the part data to its storage server. If the write fails,
+// the part is placed onto another server. It returns the part with the server and the compressed size of its data.
+func (s *Service) storePart(ctx context.Context, part model.Part, key dataKey, data []byte) (model.Part, error) {
+	for placement := 1; ; placement++ {
+		compressedSize, err := encodePart(part, key, bytes.NewReader(data), func(encoded io.Reader) error {
+			ctx, span := startPartSpan(ctx, "upload.UploadPart", part)
+			defer span.End()
+
+			err := s.storageService.UploadPart(ctx, part.BlobID, part.ServerURL, encoded)
+			if err != nil {
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return err //nolint:wrapcheck
+		})
+
+		if err == nil {
+			part.CompressedSize = compressedSize
+
+			return part, nil
+		}
+
+		if placement == maxPartPlacements || ctx.Err() != nil {
+			return part, err
+		}
+
+		slog.Warn("Unable to upload part, placing it onto another server",
+			"part", part.ID, "server", part.ServerURL, "error", err)
+
+		// The failed write may have left data on the server
+		if err := s.storageService.CleanPart(ctx, part.BlobID, part.ServerURL); err != nil {
+			slog.Warn("Unable to clean failed part", "part", part.ID, "server", part.ServerURL, "error", err)
+		}
+
+		serverURL, replaceErr := s.anotherServer(ctx, part.ServerURL)
+
+		if replaceErr != nil {
+			return part, errors.Join(err, replaceErr)
+		}
+
+		part.ServerURL = serverURL
+	}
+}
+
+// anotherServer returns a random available server other than the failed one.
+func (s *Service) anotherServer(ctx context.Context, failedURL string) (string, error) {
+	servers, err := s.shuffledServers(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	for _, server := range servers {
+		if server != failedURL {
+			return server, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w other than the failed one", storage.ErrNoServers)
+}
+
+// encodePart compresses and encrypts the part data and passes it to store.
+// It returns the size of the compressed data.
+func encodePart(part model.Part, key dataKey, reader io.Reader, store func(io.Reader) error) (int64, error) {
+	compressed, err := compression.NewCompressReader(reader, part.Codec)
+
+	if err != nil {
+		return 0, fmt.Errorf("unable to compress part: %w", err)
+	}
+
+	defer compressed.Close()
+
+	counter := &countingReader{reader: compressed, n: 0}
+
+	encrypted, err := key.encryptPart(counter, part)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := store(encrypted); err != nil {
+		return 0, err
+	}
+
+	return counter.n, nil
+}
+
+// readPart writes the part data to the writer, decrypting and, unless keepCompressed
+// is set, decompressing it.
+func (s *Service) readPart(
+	ctx context.Context, part model.Part, key dataKey, writer io.Writer, keepCompressed bool,
+) (err error) { //nolint:nonamedreturns
+	codec := part.Codec
+	if keepCompressed {
+		codec = compression.CodecNone
+	}
+
+	decompressWriter, err := compression.NewDecompressWriter(writer, codec)
+
+	if err != nil {
+		return fmt.Errorf("unable to decompress part: %w", err)
+	}
+
+	defer func() {
+		// Always stops decompression, but reports its error only if reading succeeded
+		if closeErr := decompressWriter.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("unable to decompress part: %w", closeErr)
+		}
+	}()
+
+	if key.plaintext == nil {
+		return s.readPartData(ctx, part, decompressWriter)
+	}
+
+	decryptWriter, err := encryption.NewDecryptWriter(decompressWriter, key.plaintext, uint32(part.Number))
+
+	if err != nil {
+		return fmt.Errorf("unable to decrypt part: %w", err)
+	}
+
+	err = s.readPartData(ctx, part, decryptWriter)
+
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	if err := decryptWriter.Close(); err != nil {
+		return fmt.Errorf("unable to decrypt part: %w", err)
+	}
+
+	return nil
+}
+
+// readPartData writes the part data as it is stored to the writer.
+func (s *Service) readPartData(ctx context.Context, part model.Part, writer io.Writer) error {
+	ctx, span := startPartSpan(ctx, "upload.ReadPart", part)
+	defer span.End()
+
+	var err error
+
+	if part.IsPacked() {
+		err = s.storageService.ReadVolume(
+			ctx, part.VolumeID, part.ServerURL, part.VolumeOffset, part.VolumeLength, writer,
+		)
+	} else {
+		err = s.storageService.ReadPart(ctx, part.BlobID, part.ServerURL, writer)
+	}
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// readPartRange writes length bytes of the part data starting at offset. The part must be stored as is,
+// neither compressed nor encrypted.
+func (s *Service) readPartRange(ctx context.Context, part model.Part, offset, length int64, writer io.Writer) error {
+	ctx, span := startPartSpan(ctx, "upload.ReadPartRange", part)
+	defer span.End()
+
+	var err error
+
+	if part.IsPacked() {
+		err = s.storageService.ReadVolume(ctx, part.VolumeID, part.ServerURL, part.VolumeOffset+offset, length, writer)
+	} else {
+		err = s.storageService.ReadPartRange(ctx, part.BlobID, part.ServerURL, offset, length, writer)
+	}
+
+	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	return err //nolint:wrapcheck
+}
+
+// startPartSpan starts a span of a storage call of the part tagged with its number and server.
+func startPartSpan(ctx context.Context, name string, part model.Part) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(
+		attribute.String("part.id", part.ID.String()),
+		attribute.Int("part.number", int(part.Number)),
+		attribute.String("server.url", part.ServerURL),
+	))
+}
+
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
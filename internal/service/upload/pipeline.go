@@ -0,0 +1,107 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/pkg/chunker"
+	"github.com/quolpr/distributeds3/pkg/compression"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+var ErrInvalidPipeline = errors.New("invalid pipeline config")
+
+// PipelineConfig bounds parts transferred concurrently by one upload or download.
+type PipelineConfig struct {
+	// Concurrency is the max number of parts transferred at the same time
+	Concurrency int
+	// MemoryBudget is the max total size of buffered parts. A part bigger than the budget takes all of it
+	MemoryBudget int64
+}
+
+func (c PipelineConfig) Validate() error {
+	if c.Concurrency < 1 {
+		return fmt.Errorf("%w: concurrency must be positive", ErrInvalidPipeline)
+	}
+
+	if c.MemoryBudget < 1 {
+		return fmt.Errorf("%w: memory budget must be positive", ErrInvalidPipeline)
+	}
+
+	return nil
+}
+
+// uploadChunks splits the upload data into parts spread over the servers. Parts are buffered
+// and uploaded concurrently, the stream is not read further while the budget is exhausted.
+func (s *Service) uploadChunks(
+	ctx context.Context, upload model.Upload, servers []string, key dataKey, codec compression.Codec, reader io.Reader,
+) error {
+	chunks, err := chunker.New(reader, s.chunking)
+
+	if err != nil {
+		return fmt.Errorf("unable to split upload into parts: %w", err)
+	}
+
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(s.pipeline.Concurrency)
+
+	produceErr := s.produceParts(groupCtx, group, chunks, upload, servers, key, codec)
+
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("unable to upload part: %w", err)
+	}
+
+	return produceErr
+}
+
+// produceParts reads chunks and starts their uploads in the group till the stream is over or an upload fails.
+func (s *Service) produceParts(
+	ctx context.Context, group *errgroup.Group, chunks chunker.Chunker,
+	upload model.Upload, servers []string, key dataKey, codec compression.Codec,
+) error {
+	budget := semaphore.NewWeighted(s.pipeline.MemoryBudget)
+
+	for number := int32(0); ; number++ {
+		chunk, err := chunks.Next()
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to read part: %w", err)
+		}
+
+		size := min(int64(len(chunk)), s.pipeline.MemoryBudget)
+
+		// Fails only if the context is canceled by a failed upload, its error is returned by the group
+		if err := budget.Acquire(ctx, size); err != nil {
+			return nil //nolint:nilerr
+		}
+
+		// The chunk is valid only till the next one is read
+		data := bytes.Clone(chunk)
+
+		part, err := s.persistPart(ctx, upload, number, int64(len(data)), servers[int(number)%len(servers)], codec)
+
+		if err != nil {
+			budget.Release(size)
+
+			return err
+		}
+
+		group.Go(func() error {
+			defer budget.Release(size)
+
+			slog.Info("Uploading part", "part", part)
+
+			return s.uploadPart(ctx, part, key, data)
+		})
+	}
+}
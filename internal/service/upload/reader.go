@@ -0,0 +1,274 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+	"github.com/quolpr/distributeds3/pkg/compression"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/semaphore"
+)
+
+// UploadReader streams an upload opened by OpenUpload.
+type UploadReader struct {
+	svc    *Service
+	upload model.Upload
+	parts  []model.Part
+	key    dataKey
+	// gzipPassthrough is set if all parts are gzip compressed and the client accepts gzip.
+	// Concatenated gzip members are a valid gzip stream, so parts are sent as is.
+	gzipPassthrough bool
+}
+
+// OpenUpload checks that the upload can be read with the options and prepares it for streaming,
+// so errors are reported before anything is written to the client. Only committed versions with data
+// can be read, uploads in flight, failed ones and delete markers are not found.
+func (s *Service) OpenUpload(ctx context.Context, id uuid.UUID, opts ReadOptions) (*UploadReader, error) {
+	upload, err := s.uploadRepo.GetUpload(ctx, id)
+
+	if errors.Is(err, repo.ErrNotFound) ||
+		(err == nil && (upload.Status != model.UploadStatusCommitted || upload.IsDeleteMarker)) {
+		return nil, ErrObjectNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get upload: %w", err)
+	}
+
+	key, err := s.uploadDataKey(ctx, upload, opts.CustomerKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parts, err := s.partRepo.GetParts(ctx, id)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get parts: %w", err)
+	}
+
+	// Empty uploads have no parts, and an empty body is not a valid gzip stream
+	gzipPassthrough := opts.AcceptGzip && len(parts) > 0
+
+	for _, part := range parts {
+		if part.Codec != compression.CodecGzip {
+			gzipPassthrough = false
+		}
+	}
+
+	return &UploadReader{
+		svc:             s,
+		upload:          upload,
+		parts:           parts,
+		key:             key,
+		gzipPassthrough: gzipPassthrough,
+	}, nil
+}
+
+func (r *UploadReader) Upload() model.Upload {
+	return r.upload
+}
+
+// ContentEncoding returns "gzip" if the stream is gzip compressed and empty string otherwise.
+func (r *UploadReader) ContentEncoding() string {
+	if r.gzipPassthrough {
+		return string(compression.CodecGzip)
+	}
+
+	return ""
+}
+
+// Stream writes the upload to the writer. Next parts are fetched concurrently within the pipeline
+// budget while the current one is written, all fetches are canceled if writing fails or ctx is done.
+func (r *UploadReader) Stream(ctx context.Context, writer io.Writer) error {
+	ranges := make([]partRange, len(r.parts))
+
+	for i, part := range r.parts {
+		ranges[i] = partRange{part: part, offset: 0, length: -1}
+	}
+
+	return r.stream(ctx, writer, ranges)
+}
+
+// StreamRange writes length bytes of the upload starting at offset, only parts overlapping the range are read.
+// Of parts stored as is, neither compressed nor encrypted, only the requested bytes are read. Compressed
+// and encrypted parts can't be decoded from the middle, they are read whole and cut.
+// The range must be within the upload and the reader must be opened without AcceptGzip.
+func (r *UploadReader) StreamRange(ctx context.Context, writer io.Writer, offset, length int64) error {
+	if r.gzipPassthrough {
+		return fmt.Errorf("range of a gzip passthrough stream is requested")
+	}
+
+	var (
+		ranges    []partRange
+		partStart int64
+	)
+
+	for _, part := range r.parts {
+		partEnd := partStart + part.Size
+
+		if partEnd > offset && partStart < offset+length {
+			from := max(offset-partStart, 0)
+			to := min(offset+length, partEnd) - partStart
+
+			ranges = append(ranges, partRange{part: part, offset: from, length: to - from})
+		}
+
+		partStart = partEnd
+	}
+
+	return r.stream(ctx, writer, ranges)
+}
+
+// partRange is the range of the part data to write.
+type partRange struct {
+	part   model.Part
+	offset int64
+	// length is -1 if the whole part is written
+	length int64
+}
+
+// readsStoredRange reports whether the range can be read from the storage without the rest of the part.
+func (r *UploadReader) readsStoredRange(rng partRange) bool {
+	return rng.length >= 0 && rng.part.Codec == compression.CodecNone && r.key.plaintext == nil
+}
+
+func (r *UploadReader) stream(ctx context.Context, writer io.Writer, ranges []partRange) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "upload.Stream", trace.WithAttributes(
+		attribute.String("upload.id", r.upload.ID.String()),
+		attribute.Int("upload.parts", len(ranges)),
+	))
+	defer span.End()
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	defer cancel()
+
+	budget := semaphore.NewWeighted(r.svc.pipeline.MemoryBudget)
+	// The part being written takes one of the concurrency slots
+	fetches := make(chan *partFetch, r.svc.pipeline.Concurrency-1)
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+		defer close(fetches)
+
+		r.prefetch(ctx, &wg, budget, ranges, fetches)
+	}()
+
+	for fetch := range fetches {
+		select {
+		case <-fetch.done:
+		case <-ctx.Done():
+			return fmt.Errorf("download is canceled: %w", ctx.Err())
+		}
+
+		if fetch.err != nil {
+			return fmt.Errorf("unable to get part: %w", fetch.err)
+		}
+
+		n, err := fetch.writeTo(writer)
+		r.svc.metrics.DownloadedBytes.Add(float64(n))
+
+		if err != nil {
+			return fmt.Errorf("unable to write part: %w", err)
+		}
+
+		budget.Release(fetch.size)
+	}
+
+	// Prefetching stops early only if ctx is done
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("download is canceled: %w", err)
+	}
+
+	return nil
+}
+
+type partFetch struct {
+	part model.Part
+	data bytes.Buffer
+	// skip and length cut the range out of the fetched data, length is -1 if all of it is written
+	skip   int64
+	length int64
+	// size is taken from the memory budget till the part is written
+	size int64
+	done chan struct{}
+	err  error
+}
+
+// writeTo writes the range of the fetched data to the writer.
+func (f *partFetch) writeTo(writer io.Writer) (int64, error) {
+	f.data.Next(int(f.skip))
+
+	if f.length >= 0 {
+		f.data.Truncate(int(min(f.length, int64(f.data.Len()))))
+	}
+
+	return f.data.WriteTo(writer) //nolint:wrapcheck
+}
+
+// prefetch starts fetches of parts in order, waiting for room in the budget and in the fetches channel.
+func (r *UploadReader) prefetch(
+	ctx context.Context, wg *sync.WaitGroup, budget *semaphore.Weighted, ranges []partRange, fetches chan<- *partFetch,
+) {
+	for _, rng := range ranges {
+		part := rng.part
+		fetch := &partFetch{
+			part:   part,
+			data:   bytes.Buffer{},
+			skip:   rng.offset,
+			length: rng.length,
+			// The buffer takes the decompressed size or the compressed one if parts are passed through
+			size: min(max(part.Size, part.CompressedSize), r.svc.pipeline.MemoryBudget),
+			done: make(chan struct{}),
+			err:  nil,
+		}
+
+		storedRange := r.readsStoredRange(rng)
+
+		if storedRange {
+			// Only the range is fetched, nothing to cut
+			fetch.skip = 0
+			fetch.size = min(rng.length, r.svc.pipeline.MemoryBudget)
+		}
+
+		if err := budget.Acquire(ctx, fetch.size); err != nil {
+			return
+		}
+
+		select {
+		case fetches <- fetch:
+		case <-ctx.Done():
+			return
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer close(fetch.done)
+
+			slog.Info("Reading part", "part", part, "parts", len(ranges))
+
+			if storedRange {
+				fetch.err = r.svc.readPartRange(ctx, part, rng.offset, rng.length, &fetch.data)
+			} else {
+				fetch.err = r.svc.readPart(ctx, part, r.key, &fetch.data, r.gzipPassthrough)
+			}
+		}()
+	}
+}
@@ -0,0 +1,87 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/storage/repo/inmemstorage"
+	"github.com/quolpr/distributeds3/pkg/compression"
+)
+
+// rangeData is split into parts of 16 bytes, the last one is shorter.
+const rangeData = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// countingReadsRepo counts reads of whole parts.
+type countingReadsRepo struct {
+	*inmemstorage.InmemRepo
+
+	wholeReads atomic.Int32
+}
+
+func (r *countingReadsRepo) ReadPart(ctx context.Context, id uuid.UUID, serverURL string, writer io.Writer) error {
+	r.wholeReads.Add(1)
+
+	return r.InmemRepo.ReadPart(ctx, id, serverURL, writer) //nolint:wrapcheck
+}
+
+func TestStreamRange(t *testing.T) {
+	tests := []struct {
+		codec      compression.Codec
+		wholeReads bool
+	}{
+		{codec: compression.CodecNone, wholeReads: false},
+		// Compressed parts can't be read from the middle
+		{codec: compression.CodecGzip, wholeReads: true},
+	}
+
+	ranges := []struct{ offset, length int64 }{
+		{offset: 0, length: 1},
+		{offset: 3, length: 5},
+		{offset: 10, length: 20},
+		{offset: 16, length: 16},
+		{offset: 15, length: 34},
+		{offset: 50, length: 12},
+		{offset: 0, length: int64(len(rangeData))},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.codec), func(t *testing.T) {
+			storage := &countingReadsRepo{InmemRepo: inmemstorage.NewInmemRepo([]string{testServer})} //nolint:exhaustruct
+			env := newTestEnv(t, storage, nil)
+
+			upload := env.upload(t, "key", rangeData, UploadOptions{Codec: tt.codec}) //nolint:exhaustruct
+
+			for _, rng := range ranges {
+				t.Run(fmt.Sprintf("%d-%d", rng.offset, rng.length), func(t *testing.T) {
+					//nolint:exhaustruct
+					reader, err := env.svc.OpenUpload(context.Background(), upload.ID, ReadOptions{})
+
+					if err != nil {
+						t.Fatal(err)
+					}
+
+					var out bytes.Buffer
+
+					storage.wholeReads.Store(0)
+
+					if err := reader.StreamRange(context.Background(), &out, rng.offset, rng.length); err != nil {
+						t.Fatal(err)
+					}
+
+					if want := rangeData[rng.offset : rng.offset+rng.length]; out.String() != want {
+						t.Errorf("expected %q, got %q", want, out.String())
+					}
+
+					if reads := storage.wholeReads.Load(); (reads > 0) != tt.wholeReads {
+						t.Errorf("unexpected %d reads of whole parts", reads)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+)
+
+// RecoveryConfig configures recovery of uploads left in flight by a crashed API instance.
+type RecoveryConfig struct {
+	// InstanceID is the owner of uploads created by this instance. It must be stable across restarts
+	// and unique among running instances.
+	InstanceID string
+	// StaleAfter is the time without progress after which an upload of another instance is recovered
+	StaleAfter time.Duration
+	// Interval is how often uploads of other instances are checked
+	Interval time.Duration
+}
+
+// RecoveryReport holds the number of recovered uploads.
+type RecoveryReport struct {
+	Resumed    int
+	RolledBack int
+	// Skipped uploads were finished or taken over by another instance meanwhile
+	Skipped int
+}
+
+// RecoverUploads finishes uploads this instance left in flight before a restart. It must be called
+// before the instance accepts new uploads.
+func (s *Service) RecoverUploads(ctx context.Context) (RecoveryReport, error) {
+	uploads, err := s.uploadRepo.GetOwnedInFlightUploads(ctx, s.recovery.InstanceID)
+
+	if err != nil {
+		return RecoveryReport{}, fmt.Errorf("unable to get owned in flight uploads: %w", err)
+	}
+
+	return s.recoverUploads(ctx, uploads)
+}
+
+// RecoverStaleUploads finishes uploads of other instances that made no progress for StaleAfter.
+// The uploads are taken over first, so the previous owner can't change them anymore
+// and concurrent recoveries don't act on the same upload.
+func (s *Service) RecoverStaleUploads(ctx context.Context) (RecoveryReport, error) {
+	uploads, err := s.uploadRepo.ClaimStaleInFlightUploads(
+		ctx, s.recovery.InstanceID, time.Now().Add(-s.recovery.StaleAfter),
+	)
+
+	if err != nil {
+		return RecoveryReport{}, fmt.Errorf("unable to claim stale in flight uploads: %w", err)
+	}
+
+	return s.recoverUploads(ctx, uploads)
+}
+
+// RunRecovery periodically recovers stale uploads of other instances till the context is done.
+func (s *Service) RunRecovery(ctx context.Context) {
+	ticker := time.NewTicker(s.recovery.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := s.RecoverStaleUploads(ctx)
+
+		if err != nil {
+			slog.Error("Uploads recovery failed", "err", err)
+		} else if report.Resumed > 0 || report.RolledBack > 0 || report.Skipped > 0 {
+			slog.Info("Stale uploads recovered", "report", report)
+		}
+	}
+}
+
+// recoverUploads commits uploads with all data written and rolls back the rest. Uploads changed
+// concurrently since they were read are skipped.
+func (s *Service) recoverUploads(ctx context.Context, uploads []model.Upload) (RecoveryReport, error) {
+	var report RecoveryReport
+
+	for _, upload := range uploads {
+		if upload.Status == model.UploadStatusWritten {
+			err := s.commitVersion(ctx, upload)
+
+			if err == nil {
+				slog.Info("Upload resumed", "upload", upload.ID)
+				report.Resumed++
+
+				continue
+			}
+
+			if errors.Is(err, repo.ErrConcurrentUpdate) {
+				slog.Info("Upload changed concurrently, skipping it", "upload", upload.ID)
+				report.Skipped++
+
+				continue
+			}
+
+			// The object was locked after the data was written, the version is never visible
+			if !errors.Is(err, ErrObjectLocked) {
+				return report, fmt.Errorf("unable to resume upload %s: %w", upload.ID, err)
+			}
+		}
+
+		err := s.rollbackUpload(ctx, upload)
+
+		if errors.Is(err, repo.ErrConcurrentUpdate) {
+			slog.Info("Upload changed concurrently, skipping it", "upload", upload.ID)
+			report.Skipped++
+
+			continue
+		}
+
+		if err != nil {
+			return report, fmt.Errorf("unable to roll back upload %s: %w", upload.ID, err)
+		}
+
+		slog.Info("Upload rolled back", "upload", upload.ID, "status", upload.Status)
+		report.RolledBack++
+	}
+
+	return report, nil
+}
+
+// rollbackUpload deletes a not committed upload with the data of its parts. The aborting status
+// is persisted first, so a crash during the rollback is recovered by rolling back again. The upload
+// must still have the status it was read with, otherwise repo.ErrConcurrentUpdate is returned.
+func (s *Service) rollbackUpload(ctx context.Context, upload model.Upload) error {
+	if err := s.uploadRepo.SetStatus(ctx, upload.ID, upload.Status, model.UploadStatusAborting); err != nil {
+		return fmt.Errorf("unable to mark upload as aborting: %w", err)
+	}
+
+	_, err := s.deleteUploads(ctx, []uuid.UUID{upload.ID})
+
+	return err
+}
+
+// failUpload marks the upload as failed and rolls it back. The upload is left failed if the rollback fails,
+// it is rolled back by recovery or by the cleanup of dangle uploads.
+func (s *Service) failUpload(ctx context.Context, upload model.Upload, cause error) {
+	// The request context is usually canceled by now, the rollback must outlive it
+	ctx = context.WithoutCancel(ctx)
+
+	slog.Warn("Upload failed, rolling it back", "upload", upload.ID, "error", cause)
+
+	err := s.uploadRepo.MarkUploadAsFailed(ctx, upload.ID, s.recovery.InstanceID)
+
+	if errors.Is(err, repo.ErrConcurrentUpdate) {
+		slog.Warn("Upload was taken over by recovery, leaving the rollback to it", "upload", upload.ID)
+
+		return
+	}
+
+	if err != nil {
+		slog.Error("Unable to mark upload as failed", "upload", upload.ID, "error", err)
+
+		return
+	}
+
+	upload.Status = model.UploadStatusFailed
+
+	if err := s.rollbackUpload(ctx, upload); err != nil {
+		slog.Error("Unable to roll back upload", "upload", upload.ID, "error", err)
+	}
+}
+
+// touchingReader records progress of the upload while its body is read, so the upload is not recovered
+// as stale by another instance in the middle of a long part.
+type touchingReader struct {
+	reader  io.Reader
+	touch   func() error
+	every   time.Duration
+	touched time.Time
+}
+
+func (s *Service) newTouchingReader(ctx context.Context, upload model.Upload, reader io.Reader) *touchingReader {
+	return &touchingReader{
+		reader: reader,
+		touch: func() error {
+			return s.uploadRepo.Touch(ctx, upload.ID, s.recovery.InstanceID)
+		},
+		every:   s.recovery.StaleAfter / 4, //nolint:gomnd
+		touched: time.Now(),
+	}
+}
+
+func (r *touchingReader) Read(p []byte) (int, error) {
+	if time.Since(r.touched) >= r.every {
+		if err := r.touch(); err != nil {
+			return 0, fmt.Errorf("unable to touch upload: %w", err)
+		}
+
+		r.touched = time.Now()
+	}
+
+	return r.reader.Read(p) //nolint:wrapcheck
+}
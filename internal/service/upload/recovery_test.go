@@ -0,0 +1,290 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/storage/repo/inmemstorage"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+	"github.com/quolpr/distributeds3/pkg/compression"
+)
+
+const recoveryData = "data of the upload left in flight"
+
+// withInstance returns the service of another API instance sharing the database and the storage.
+func (e *testEnv) withInstance(instanceID string) *Service {
+	svc := *e.svc
+	svc.recovery.InstanceID = instanceID
+
+	return &svc
+}
+
+// writingUpload starts an upload and stores its first part, as if the instance crashed in the middle of it.
+func (e *testEnv) writingUpload(t *testing.T, key string) model.Upload {
+	t.Helper()
+
+	ctx := context.Background()
+
+	upload, err := e.svc.persistUpload(ctx, testBucket, int64(len(recoveryData)), key, dataKey{}) //nolint:exhaustruct
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.svc.uploadRepo.SetStatus(ctx, upload.ID, model.UploadStatusPending, model.UploadStatusWriting); err != nil {
+		t.Fatal(err)
+	}
+
+	part, err := e.svc.persistPart(ctx, upload, 0, testChunkSize, testServer, compression.CodecNone)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.svc.uploadPart(ctx, part, dataKey{}, []byte(recoveryData[:testChunkSize])); err != nil { //nolint:exhaustruct
+		t.Fatal(err)
+	}
+
+	upload.Status = model.UploadStatusWriting
+
+	return upload
+}
+
+// writtenUpload stores all data of an upload without committing it, as if the instance crashed right before.
+func (e *testEnv) writtenUpload(t *testing.T, key string) model.Upload {
+	t.Helper()
+
+	ctx := context.Background()
+
+	upload, err := e.svc.persistUpload(ctx, testBucket, int64(len(recoveryData)), key, dataKey{}) //nolint:exhaustruct
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servers, err := e.svc.shuffledServers(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload.ContentMD5, err = e.svc.writeUpload(
+		ctx, upload, servers, dataKey{}, compression.CodecNone, strings.NewReader(recoveryData), nil, //nolint:exhaustruct
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upload.Status = model.UploadStatusWritten
+
+	return upload
+}
+
+// makeStale moves the last progress of the upload back past StaleAfter.
+func (e *testEnv) makeStale(t *testing.T, id uuid.UUID) {
+	t.Helper()
+
+	_, err := e.pool.Exec(context.Background(), "update uploads set updated_at = $1 where id = $2",
+		time.Now().Add(-2*e.svc.recovery.StaleAfter), id)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecoveryRollsBackStaleWritingUpload(t *testing.T) {
+	storage := inmemstorage.NewInmemRepo([]string{testServer})
+	env := newTestEnv(t, storage, nil)
+
+	upload := env.writingUpload(t, "key")
+	env.makeStale(t, upload.ID)
+
+	report, err := env.withInstance("recoverer").RecoverStaleUploads(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.RolledBack != 1 || report.Resumed != 0 || report.Skipped != 0 {
+		t.Errorf("expected 1 rolled back upload, got %+v", report)
+	}
+
+	if n := env.count(t, "select count(*) from uploads"); n != 0 {
+		t.Errorf("expected the upload to be deleted, got %d uploads", n)
+	}
+
+	if n := storedParts(t, storage); n != 0 {
+		t.Errorf("expected the part data to be cleaned, got %d stored parts", n)
+	}
+}
+
+func TestRecoveryKeepsFreshUploadsOfOtherInstances(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+
+	env.writingUpload(t, "key")
+
+	report, err := env.withInstance("recoverer").RecoverStaleUploads(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report != (RecoveryReport{}) {
+		t.Errorf("expected nothing to recover, got %+v", report)
+	}
+
+	if n := env.count(t, "select count(*) from uploads where owner = $1", testInstance); n != 1 {
+		t.Errorf("expected the upload to stay with its owner, got %d", n)
+	}
+}
+
+func TestRecoveryResumesWrittenUpload(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+	ctx := context.Background()
+
+	upload := env.writtenUpload(t, "key")
+
+	// The instance restarts and finishes its own uploads
+	report, err := env.svc.RecoverUploads(ctx)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Resumed != 1 || report.RolledBack != 0 {
+		t.Errorf("expected 1 resumed upload, got %+v", report)
+	}
+
+	latest, err := env.svc.GetObjectVersion(ctx, testBucket, "key", uuid.Nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latest.ID != upload.ID || latest.Status != model.UploadStatusCommitted {
+		t.Fatalf("expected %s to be the committed latest version, got %+v", upload.ID, latest)
+	}
+
+	if got := env.read(t, upload.ID); got != recoveryData {
+		t.Errorf("unexpected data of the resumed upload %q", got)
+	}
+}
+
+func TestRecoverySkipsConcurrentlyChangedUpload(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+	ctx := context.Background()
+
+	// The recoverer read the upload as writing, then its owner failed it
+	upload := env.writingUpload(t, "key")
+
+	err := env.svc.uploadRepo.SetStatus(ctx, upload.ID, model.UploadStatusWriting, model.UploadStatusFailed)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := env.withInstance("recoverer").recoverUploads(ctx, []model.Upload{upload})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Skipped != 1 || report.RolledBack != 0 {
+		t.Errorf("expected 1 skipped upload, got %+v", report)
+	}
+
+	if n := env.count(t, "select count(*) from uploads where status = 'failed'"); n != 1 {
+		t.Errorf("expected the upload to be left to its owner, got %d failed uploads", n)
+	}
+}
+
+func TestOwnerCanNotFinishClaimedUpload(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+	ctx := context.Background()
+
+	upload := env.writingUpload(t, "key")
+	env.makeStale(t, upload.ID)
+
+	claimed, err := env.svc.uploadRepo.ClaimStaleInFlightUploads(ctx, "recoverer", time.Now().Add(-time.Second))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != 1 {
+		t.Fatalf("expected 1 claimed upload, got %d", len(claimed))
+	}
+
+	// The previous owner wakes up, but the upload is not its anymore
+	err = env.svc.uploadRepo.MarkUploadAsWritten(ctx, upload.ID, testInstance, nil)
+
+	if !errors.Is(err, repo.ErrConcurrentUpdate) {
+		t.Errorf("expected concurrent update, got %v", err)
+	}
+}
+
+func TestRecoverersNeverClaimSameUpload(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+	ctx := context.Background()
+
+	const uploads, recoverers = 20, 4
+
+	for i := range uploads {
+		upload := env.writingUpload(t, fmt.Sprintf("key-%d", i))
+		env.makeStale(t, upload.ID)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		claimed = make(map[uuid.UUID]string)
+		errs    []error
+	)
+
+	for i := range recoverers {
+		owner := fmt.Sprintf("recoverer-%d", i)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			claims, err := env.svc.uploadRepo.ClaimStaleInFlightUploads(ctx, owner, time.Now().Add(-time.Second))
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs = append(errs, err)
+
+				return
+			}
+
+			for _, upload := range claims {
+				if previous, ok := claimed[upload.ID]; ok {
+					errs = append(errs, fmt.Errorf("%s is claimed by %s and %s", upload.ID, previous, owner))
+				}
+
+				claimed[upload.ID] = owner
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(claimed) != uploads {
+		t.Errorf("expected all %d uploads to be claimed, got %d", uploads, len(claimed))
+	}
+}
@@ -0,0 +1,162 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/quolpr/distributeds3/internal/queries/pg"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/pkg/compression"
+)
+
+type BlobRepo struct {
+	querier pg.Querier
+	// qtx - querier для запуска в транзакционном режиме.
+	qtx pg.QuerierTX
+}
+
+func NewBlobRepo(querierTx pg.QuerierTX) *BlobRepo {
+	return &BlobRepo{
+		querier: querierTx,
+		qtx:     querierTx,
+	}
+}
+
+// Reference adds a reference to the blob with the same content. Returns ErrNotFound if there is no such blob.
+func (r *BlobRepo) Reference(ctx context.Context, hash []byte, codec compression.Codec) (model.Blob, error) {
+	row, err := r.querier.ReferenceBlob(ctx, pg.ReferenceBlobParams{
+		Hash:  hash,
+		Codec: string(codec),
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Blob{}, ErrNotFound
+	}
+
+	if err != nil {
+		return model.Blob{}, fmt.Errorf("failed to reference blob: %w", err)
+	}
+
+	return toBlobModel(row), nil
+}
+
+// Acquire creates the blob with one reference. If the blob with the same content was created
+// concurrently, a reference to it is added and the existing blob is returned.
+func (r *BlobRepo) Acquire(ctx context.Context, blob model.Blob) (model.Blob, error) {
+	row, err := r.querier.AcquireBlob(ctx, pg.AcquireBlobParams{
+		ID:             blob.ID,
+		ServerUrl:      blob.ServerURL,
+		Hash:           blob.Hash,
+		Codec:          string(blob.Codec),
+		Size:           blob.Size,
+		CompressedSize: blob.CompressedSize,
+		CreatedAt: pgtype.Timestamptz{
+			Time:             blob.CreatedAt,
+			InfinityModifier: pgtype.Finite,
+			Valid:            true,
+		},
+	})
+
+	if err != nil {
+		return model.Blob{}, fmt.Errorf("failed to acquire blob: %w", err)
+	}
+
+	return toBlobModel(row), nil
+}
+
+// Release drops a reference to the blob and returns true if the data is not referenced anymore.
+// Data of parts that are not deduplicated has no blob, so it is released right away.
+func (r *BlobRepo) Release(ctx context.Context, id uuid.UUID) (bool, error) {
+	row, err := r.querier.ReleaseBlob(ctx, id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("failed to release blob: %w", err)
+	}
+
+	if row.RefCount > 0 {
+		return false, nil
+	}
+
+	err = r.querier.DeleteUnreferencedBlob(ctx, id)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to delete blob: %w", err)
+	}
+
+	return true, nil
+}
+
+// Get returns the blob by ID. Returns ErrNotFound if the data is not shared, e.g. it is encrypted.
+func (r *BlobRepo) Get(ctx context.Context, id uuid.UUID) (model.Blob, error) {
+	row, err := r.querier.GetBlob(ctx, id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Blob{}, ErrNotFound
+	}
+
+	if err != nil {
+		return model.Blob{}, fmt.Errorf("failed to get blob: %w", err)
+	}
+
+	return toBlobModel(row), nil
+}
+
+func (r *BlobRepo) SetServer(ctx context.Context, id uuid.UUID, serverURL string) error {
+	err := r.querier.UpdateBlobServer(ctx, pg.UpdateBlobServerParams{
+		NewServerUrl: serverURL,
+		ID:           id,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set blob server: %w", err)
+	}
+
+	return nil
+}
+
+// GetStoredIDs returns IDs of the data on the server that parts or blobs reference.
+func (r *BlobRepo) GetStoredIDs(ctx context.Context, serverURL string, ids []uuid.UUID) ([]uuid.UUID, error) {
+	stored, err := r.querier.GetStoredBlobIDs(ctx, pg.GetStoredBlobIDsParams{
+		ServerUrl: serverURL,
+		Ids:       ids,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored blob ids: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (r *BlobRepo) WithTx(tx pgx.Tx) *BlobRepo {
+	// если уже в транзакционном режиме - ничего не делаем
+	if r.qtx == nil {
+		return r
+	}
+
+	return &BlobRepo{
+		querier: r.qtx.WithTx(tx),
+		qtx:     nil, // нельзя запускать транзакцию повторно
+	}
+}
+
+func toBlobModel(row pg.Blob) model.Blob {
+	return model.Blob{
+		ID:             row.ID,
+		ServerURL:      row.ServerUrl,
+		Hash:           row.Hash,
+		Codec:          compression.Codec(row.Codec),
+		Size:           row.Size,
+		CompressedSize: row.CompressedSize,
+		RefCount:       row.RefCount,
+		CreatedAt:      row.CreatedAt.Time,
+	}
+}
@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/quolpr/distributeds3/internal/queries/pg"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+)
+
+type CleanupRepo struct {
+	querier pg.Querier
+	// qtx - querier для запуска в транзакционном режиме.
+	qtx pg.QuerierTX
+}
+
+func NewCleanupRepo(querierTx pg.QuerierTX) *CleanupRepo {
+	return &CleanupRepo{
+		querier: querierTx,
+		qtx:     querierTx,
+	}
+}
+
+// Enqueue queues the cleanup, a cleanup of the same data is queued once.
+func (r *CleanupRepo) Enqueue(ctx context.Context, cleanup model.PartCleanup) error {
+	err := r.querier.InsertPartCleanup(ctx, pg.InsertPartCleanupParams{
+		BlobID:    cleanup.BlobID,
+		ServerUrl: cleanup.ServerURL,
+		Size:      cleanup.Size,
+		CreatedAt: pgtype.Timestamptz{
+			Time:             cleanup.CreatedAt,
+			InfinityModifier: pgtype.Finite,
+			Valid:            true,
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to enqueue part cleanup: %w", err)
+	}
+
+	return nil
+}
+
+func (r *CleanupRepo) Delete(ctx context.Context, blobID uuid.UUID) error {
+	err := r.querier.DeletePartCleanup(ctx, blobID)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete part cleanup: %w", err)
+	}
+
+	return nil
+}
+
+// RecordFailure increments attempts of the cleanup and saves its error.
+func (r *CleanupRepo) RecordFailure(ctx context.Context, blobID uuid.UUID, cleanupErr error) error {
+	err := r.querier.UpdatePartCleanupFailure(ctx, pg.UpdatePartCleanupFailureParams{
+		LastError: cleanupErr.Error(),
+		BlobID:    blobID,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to record part cleanup failure: %w", err)
+	}
+
+	return nil
+}
+
+// GetPending returns up to maxCount cleanups with less than maxAttempts failed attempts, ordered by blob ID
+// starting after the given one.
+func (r *CleanupRepo) GetPending(
+	ctx context.Context, maxAttempts int32, after uuid.UUID, maxCount int32,
+) ([]model.PartCleanup, error) {
+	rows, err := r.querier.GetPartCleanups(ctx, pg.GetPartCleanupsParams{
+		MaxAttempts: maxAttempts,
+		After:       after,
+		MaxCount:    maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get part cleanups: %w", err)
+	}
+
+	cleanups := make([]model.PartCleanup, len(rows))
+	for i, row := range rows {
+		cleanups[i] = model.PartCleanup{
+			BlobID:    row.BlobID,
+			ServerURL: row.ServerUrl,
+			Size:      row.Size,
+			Attempts:  row.Attempts,
+			LastError: row.LastError,
+			CreatedAt: row.CreatedAt.Time,
+			UpdatedAt: row.UpdatedAt.Time,
+		}
+	}
+
+	return cleanups, nil
+}
+
+// EnqueueVolume queues the volume cleanup, a cleanup of the same volume is queued once.
+func (r *CleanupRepo) EnqueueVolume(ctx context.Context, cleanup model.VolumeCleanup) error {
+	err := r.querier.InsertVolumeCleanup(ctx, pg.InsertVolumeCleanupParams{
+		VolumeID:  cleanup.VolumeID,
+		ServerUrl: cleanup.ServerURL,
+		Size:      cleanup.Size,
+		DeleteAfter: pgtype.Timestamptz{
+			Time:             cleanup.DeleteAfter,
+			InfinityModifier: pgtype.Finite,
+			Valid:            true,
+		},
+		CreatedAt: pgtype.Timestamptz{
+			Time:             cleanup.CreatedAt,
+			InfinityModifier: pgtype.Finite,
+			Valid:            true,
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to enqueue volume cleanup: %w", err)
+	}
+
+	return nil
+}
+
+func (r *CleanupRepo) DeleteVolume(ctx context.Context, volumeID uuid.UUID) error {
+	err := r.querier.DeleteVolumeCleanup(ctx, volumeID)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete volume cleanup: %w", err)
+	}
+
+	return nil
+}
+
+// RecordVolumeFailure increments attempts of the volume cleanup and saves its error.
+func (r *CleanupRepo) RecordVolumeFailure(ctx context.Context, volumeID uuid.UUID, cleanupErr error) error {
+	err := r.querier.UpdateVolumeCleanupFailure(ctx, pg.UpdateVolumeCleanupFailureParams{
+		LastError: cleanupErr.Error(),
+		VolumeID:  volumeID,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to record volume cleanup failure: %w", err)
+	}
+
+	return nil
+}
+
+// GetDueVolumes returns up to maxCount volume cleanups due at now with less than maxAttempts failed attempts,
+// ordered by volume ID starting after the given one.
+func (r *CleanupRepo) GetDueVolumes(
+	ctx context.Context, now time.Time, maxAttempts int32, after uuid.UUID, maxCount int32,
+) ([]model.VolumeCleanup, error) {
+	rows, err := r.querier.GetDueVolumeCleanups(ctx, pg.GetDueVolumeCleanupsParams{
+		Now: pgtype.Timestamptz{
+			Time:             now,
+			InfinityModifier: pgtype.Finite,
+			Valid:            true,
+		},
+		MaxAttempts: maxAttempts,
+		After:       after,
+		MaxCount:    maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume cleanups: %w", err)
+	}
+
+	cleanups := make([]model.VolumeCleanup, len(rows))
+	for i, row := range rows {
+		cleanups[i] = model.VolumeCleanup{
+			VolumeID:    row.VolumeID,
+			ServerURL:   row.ServerUrl,
+			Size:        row.Size,
+			DeleteAfter: row.DeleteAfter.Time,
+			Attempts:    row.Attempts,
+			LastError:   row.LastError,
+			CreatedAt:   row.CreatedAt.Time,
+			UpdatedAt:   row.UpdatedAt.Time,
+		}
+	}
+
+	return cleanups, nil
+}
+
+func (r *CleanupRepo) WithTx(tx pgx.Tx) *CleanupRepo {
+	// если уже в транзакционном режиме - ничего не делаем
+	if r.qtx == nil {
+		return r
+	}
+
+	return &CleanupRepo{
+		querier: r.qtx.WithTx(tx),
+		qtx:     nil, // нельзя запускать транзакцию повторно
+	}
+}
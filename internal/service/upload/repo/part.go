@@ -3,13 +3,12 @@ package repo
 import (
 	"context"
 	"fmt"
-	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/quolpr/distributeds3/internal/queries/pg"
 	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/pkg/compression"
 )
 
 type PartRepo struct {
@@ -34,6 +33,8 @@ func (r *PartRepo) Create(ctx context.Context, part model.Part) error {
 			UploadID:  part.UploadID,
 			Number:    part.Number,
 			Size:      part.Size,
+			Codec:     string(part.Codec),
+			BlobID:    part.BlobID,
 			Status:    pg.UploadStatus(part.Status),
 		},
 	)
@@ -54,65 +55,142 @@ func (r *PartRepo) GetParts(ctx context.Context, uploadID uuid.UUID) ([]model.Pa
 		return nil, fmt.Errorf("failed to get parts: %w", err)
 	}
 
-	parts := make([]model.Part, len(rows))
-	for i, r := range rows {
-		parts[i] = model.Part{
-			ID:        r.ID,
-			ServerURL: r.ServerUrl,
-			UploadID:  r.UploadID,
-			Number:    r.Number,
-			Size:      r.Size,
-			CreatedAt: r.CreatedAt.Time,
-			Status:    model.UploadStatus(r.Status),
-		}
-	}
-
-	return parts, nil
+	return toPartModels(rows), nil
 }
 
-func (r *PartRepo) MarkPartAsDone(ctx context.Context, partID uuid.UUID) error {
-	err := r.querier.UpdatePartAsDone(
+// MarkPartAsWritten saves the part with its compressed size and the location of its data.
+func (r *PartRepo) MarkPartAsWritten(ctx context.Context, part model.Part) error {
+	err := r.querier.UpdatePartAsWritten(
 		ctx,
-		partID,
+		pg.UpdatePartAsWrittenParams{
+			CompressedSize: part.CompressedSize,
+			BlobID:         part.BlobID,
+			ServerUrl:      part.ServerURL,
+			ID:             part.ID,
+		},
 	)
 
 	if err != nil {
-		return fmt.Errorf("failed to mark part as done: %w", err)
+		return fmt.Errorf("failed to mark part as written: %w", err)
 	}
 
 	return nil
 }
 
-func (r *PartRepo) GetOldInProgressParts(ctx context.Context, from time.Time) ([]model.Part, error) {
-	time := pgtype.Timestamptz{
-		Time:             from,
-		InfinityModifier: pgtype.Finite,
-		Valid:            true,
+func (r *PartRepo) SetStatus(ctx context.Context, partID uuid.UUID, status model.UploadStatus) error {
+	err := r.querier.UpdatePartStatus(ctx, pg.UpdatePartStatusParams{
+		Status: pg.UploadStatus(status),
+		ID:     partID,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set part status: %w", err)
 	}
 
-	rows, err := r.querier.GetOldInProgressParts(
+	return nil
+}
+
+// MarkPartAsPacked saves the part with the location of its data in the volume.
+func (r *PartRepo) MarkPartAsPacked(ctx context.Context, part model.Part) error {
+	err := r.querier.UpdatePartAsPacked(
 		ctx,
-		time,
+		pg.UpdatePartAsPackedParams{
+			CompressedSize: part.CompressedSize,
+			VolumeID:       part.VolumeID,
+			VolumeOffset:   part.VolumeOffset,
+			VolumeLength:   part.VolumeLength,
+			ID:             part.ID,
+		},
 	)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get old in progress parts: %w", err)
+		return fmt.Errorf("failed to mark part as packed: %w", err)
 	}
 
-	parts := make([]model.Part, len(rows))
-	for i, r := range rows {
-		parts[i] = model.Part{
-			ID:        r.ID,
-			ServerURL: r.ServerUrl,
-			UploadID:  r.UploadID,
-			Number:    r.Number,
-			Size:      r.Size,
-			CreatedAt: r.CreatedAt.Time,
-			Status:    model.UploadStatus(r.Status),
+	return nil
+}
+
+func (r *PartRepo) GetVolumeParts(ctx context.Context, volumeID uuid.UUID) ([]model.Part, error) {
+	rows, err := r.querier.GetVolumeParts(ctx, volumeID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volume parts: %w", err)
+	}
+
+	return toPartModels(rows), nil
+}
+
+// GetServerStats returns part stats of every server parts are placed on.
+func (r *PartRepo) GetServerStats(ctx context.Context) ([]model.ServerPartStats, error) {
+	rows, err := r.querier.GetServerPartStats(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server part stats: %w", err)
+	}
+
+	stats := make([]model.ServerPartStats, len(rows))
+	for i, row := range rows {
+		stats[i] = model.ServerPartStats{
+			ServerURL: row.ServerUrl,
+			Parts:     row.Parts,
+			Bytes:     row.Bytes,
 		}
 	}
 
-	return parts, nil
+	return stats, nil
+}
+
+// GetServerBlobIDs returns up to maxCount IDs of not packed data stored on the server by written parts,
+// ordered by ID starting after the given one.
+func (r *PartRepo) GetServerBlobIDs(
+	ctx context.Context, serverURL string, after uuid.UUID, maxCount int32,
+) ([]uuid.UUID, error) {
+	ids, err := r.querier.GetServerBlobIDs(ctx, pg.GetServerBlobIDsParams{
+		ServerUrl: serverURL,
+		After:     after,
+		MaxCount:  maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server blob ids: %w", err)
+	}
+
+	return ids, nil
+}
+
+// MoveBlob points parts referencing the blob on the server to its copy on newServerURL.
+// Returns the number of moved parts.
+func (r *PartRepo) MoveBlob(ctx context.Context, blobID uuid.UUID, serverURL, newServerURL string) (int64, error) {
+	moved, err := r.querier.UpdatePartsServer(ctx, pg.UpdatePartsServerParams{
+		NewServerUrl: newServerURL,
+		BlobID:       blobID,
+		ServerUrl:    serverURL,
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to move blob parts: %w", err)
+	}
+
+	return moved, nil
+}
+
+// MovePartToVolume updates the location of the packed part data.
+func (r *PartRepo) MovePartToVolume(ctx context.Context, part model.Part) error {
+	err := r.querier.UpdatePartVolume(
+		ctx,
+		pg.UpdatePartVolumeParams{
+			ServerUrl:    part.ServerURL,
+			VolumeID:     part.VolumeID,
+			VolumeOffset: part.VolumeOffset,
+			ID:           part.ID,
+		},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to move part to volume: %w", err)
+	}
+
+	return nil
 }
 
 func (r *PartRepo) WithTx(tx pgx.Tx) *PartRepo {
@@ -126,3 +204,26 @@ func (r *PartRepo) WithTx(tx pgx.Tx) *PartRepo {
 		qtx:     nil, // нельзя запускать транзакцию повторно
 	}
 }
+
+func toPartModels(rows []pg.Part) []model.Part {
+	parts := make([]model.Part, len(rows))
+	for i, r := range rows {
+		parts[i] = model.Part{
+			ID:             r.ID,
+			ServerURL:      r.ServerUrl,
+			UploadID:       r.UploadID,
+			Number:         r.Number,
+			Size:           r.Size,
+			Codec:          compression.Codec(r.Codec),
+			CompressedSize: r.CompressedSize,
+			BlobID:         r.BlobID,
+			VolumeID:       r.VolumeID,
+			VolumeOffset:   r.VolumeOffset,
+			VolumeLength:   r.VolumeLength,
+			CreatedAt:      r.CreatedAt.Time,
+			Status:         model.UploadStatus(r.Status),
+		}
+	}
+
+	return parts
+}
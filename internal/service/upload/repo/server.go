@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/quolpr/distributeds3/internal/queries/pg"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+)
+
+type ServerRepo struct {
+	querier pg.Querier
+	// qtx - querier для запуска в транзакционном режиме.
+	qtx pg.QuerierTX
+}
+
+func NewServerRepo(querierTx pg.QuerierTX) *ServerRepo {
+	return &ServerRepo{
+		querier: querierTx,
+		qtx:     querierTx,
+	}
+}
+
+// GetAll returns servers added at runtime or having settings, servers without settings are not draining.
+func (r *ServerRepo) GetAll(ctx context.Context) ([]model.StorageServer, error) {
+	rows, err := r.querier.GetStorageServers(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get storage servers: %w", err)
+	}
+
+	servers := make([]model.StorageServer, len(rows))
+	for i, row := range rows {
+		servers[i] = model.StorageServer{
+			URL:       row.Url,
+			Draining:  row.Draining,
+			CreatedAt: row.CreatedAt.Time,
+			UpdatedAt: row.UpdatedAt.Time,
+		}
+	}
+
+	return servers, nil
+}
+
+// GetServerURLs returns URLs of servers added at runtime or having settings.
+func (r *ServerRepo) GetServerURLs(ctx context.Context) ([]string, error) {
+	servers, err := r.GetAll(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, len(servers))
+	for i, server := range servers {
+		urls[i] = server.URL
+	}
+
+	return urls, nil
+}
+
+// Add registers the server, it returns false if the server is already registered.
+func (r *ServerRepo) Add(ctx context.Context, serverURL string) (bool, error) {
+	added, err := r.querier.InsertStorageServer(ctx, serverURL)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to add storage server: %w", err)
+	}
+
+	return added > 0, nil
+}
+
+func (r *ServerRepo) SetDraining(ctx context.Context, serverURL string, draining bool) error {
+	err := r.querier.UpsertStorageServer(ctx, pg.UpsertStorageServerParams{
+		Url:      serverURL,
+		Draining: draining,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set storage server draining: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ServerRepo) WithTx(tx pgx.Tx) *ServerRepo {
+	// если уже в транзакционном режиме - ничего не делаем
+	if r.qtx == nil {
+		return r
+	}
+
+	return &ServerRepo{
+		querier: r.qtx.WithTx(tx),
+		qtx:     nil, // нельзя запускать транзакцию повторно
+	}
+}
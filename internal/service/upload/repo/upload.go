@@ -2,6 +2,7 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -12,6 +13,13 @@ import (
 	"github.com/quolpr/distributeds3/internal/service/upload/model"
 )
 
+var (
+	ErrNotFound = errors.New("not found")
+	// ErrConcurrentUpdate means the upload changed its status or owner since it was read,
+	// e.g. it was taken over by recovery on another instance.
+	ErrConcurrentUpdate = errors.New("upload was updated concurrently")
+)
+
 type UploadRepo struct {
 	querier pg.Querier
 	// qtx - querier для запуска в транзакционном режиме.
@@ -29,10 +37,17 @@ func (r *UploadRepo) Create(ctx context.Context, upload model.Upload) error {
 	err := r.querier.InsertUpload(
 		ctx,
 		pg.InsertUploadParams{
-			ID:     upload.ID,
-			Name:   upload.Name,
-			Size:   upload.Size,
-			Status: pg.UploadStatus(upload.Status),
+			ID:                     upload.ID,
+			Bucket:                 upload.Bucket,
+			Name:                   upload.Name,
+			Size:                   upload.Size,
+			Status:                 pg.UploadStatus(upload.Status),
+			IsLatest:               upload.IsLatest,
+			IsDeleteMarker:         upload.IsDeleteMarker,
+			EncryptedDataKey:       upload.EncryptedDataKey,
+			MasterKeyID:            upload.MasterKeyID,
+			CustomerKeyFingerprint: upload.CustomerKeyFingerprint,
+			Owner:                  upload.Owner,
 			CreatedAt: pgtype.Timestamptz{
 				Time:             upload.CreatedAt,
 				InfinityModifier: pgtype.Finite,
@@ -48,46 +63,363 @@ func (r *UploadRepo) Create(ctx context.Context, upload model.Upload) error {
 	return nil
 }
 
-func (r *UploadRepo) MarkUploadAsDone(ctx context.Context, uploadID uuid.UUID) error {
-	err := r.querier.UpdateUploadAsDone(
-		ctx,
-		uploadID,
-	)
+// MarkUploadAsCommitted makes the written upload of the owner and its parts committed.
+func (r *UploadRepo) MarkUploadAsCommitted(ctx context.Context, uploadID uuid.UUID, owner string) error {
+	affected, err := r.querier.UpdateUploadAsCommitted(ctx, pg.UpdateUploadAsCommittedParams{
+		ID:    uploadID,
+		Owner: owner,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to mark upload as committed: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	err = r.querier.UpdateUploadPartsAsCommitted(ctx, uploadID)
 
 	if err != nil {
-		return fmt.Errorf("failed to mark upload as done: %w", err)
+		return fmt.Errorf("failed to mark upload parts as committed: %w", err)
 	}
 
 	return nil
 }
-func (r *UploadRepo) GetOldInProgressUploads(ctx context.Context, from time.Time) ([]model.Upload, error) {
-	time := pgtype.Timestamptz{
-		Time:             from,
-		InfinityModifier: pgtype.Finite,
-		Valid:            true,
+
+// SetStatus changes the upload status only if it is still the expected one.
+func (r *UploadRepo) SetStatus(ctx context.Context, uploadID uuid.UUID, expected, status model.UploadStatus) error {
+	affected, err := r.querier.UpdateUploadStatus(ctx, pg.UpdateUploadStatusParams{
+		Status:         pg.UploadStatus(status),
+		ID:             uploadID,
+		ExpectedStatus: pg.UploadStatus(expected),
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set upload status: %w", err)
 	}
 
-	rows, err := r.querier.GetOldInProgressUploads(
-		ctx,
-		time,
-	)
+	if affected == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	return nil
+}
+
+// MarkUploadAsFailed marks the not committed upload of the owner as failed.
+func (r *UploadRepo) MarkUploadAsFailed(ctx context.Context, uploadID uuid.UUID, owner string) error {
+	affected, err := r.querier.UpdateOwnedUploadAsFailed(ctx, pg.UpdateOwnedUploadAsFailedParams{
+		ID:    uploadID,
+		Owner: owner,
+	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to get old in progress uploads: %w", err)
+		return fmt.Errorf("failed to mark upload as failed: %w", err)
 	}
 
-	uploads := make([]model.Upload, len(rows))
-	for i, r := range rows {
-		uploads[i] = model.Upload{
-			ID:        r.ID,
-			Name:      r.Name,
-			Size:      r.Size,
-			Status:    model.UploadStatus(r.Status),
-			CreatedAt: r.CreatedAt.Time,
-		}
+	if affected == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	return nil
+}
+
+// MarkUploadAsWritten records that all data of the writing upload of the owner is stored
+// together with its MD5 digest.
+func (r *UploadRepo) MarkUploadAsWritten(
+	ctx context.Context, uploadID uuid.UUID, owner string, contentMD5 []byte,
+) error {
+	affected, err := r.querier.UpdateUploadAsWritten(ctx, pg.UpdateUploadAsWrittenParams{
+		ContentMd5: contentMD5,
+		ID:         uploadID,
+		Owner:      owner,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to mark upload as written: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrConcurrentUpdate
 	}
 
-	return uploads, nil
+	return nil
+}
+
+// Touch records progress of the writing upload of the owner, so it is not considered stale.
+func (r *UploadRepo) Touch(ctx context.Context, uploadID uuid.UUID, owner string) error {
+	affected, err := r.querier.TouchUpload(ctx, pg.TouchUploadParams{
+		ID:    uploadID,
+		Owner: owner,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to touch upload: %w", err)
+	}
+
+	if affected == 0 {
+		return ErrConcurrentUpdate
+	}
+
+	return nil
+}
+
+// GetOwnedInFlightUploads returns not committed uploads of the owner.
+func (r *UploadRepo) GetOwnedInFlightUploads(ctx context.Context, owner string) ([]model.Upload, error) {
+	rows, err := r.querier.GetOwnedInFlightUploads(ctx, owner)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get owned in flight uploads: %w", err)
+	}
+
+	return ToUploadModels(rows), nil
+}
+
+// ClaimStaleInFlightUploads makes the owner the owner of not committed uploads of other owners that made
+// no progress since the time and returns them. Uploads locked by a concurrent claim are skipped.
+func (r *UploadRepo) ClaimStaleInFlightUploads(
+	ctx context.Context, owner string, updatedBefore time.Time,
+) ([]model.Upload, error) {
+	rows, err := r.querier.ClaimStaleInFlightUploads(ctx, pg.ClaimStaleInFlightUploadsParams{
+		Owner: owner,
+		UpdatedAt: pgtype.Timestamptz{
+			Time:             updatedBefore,
+			InfinityModifier: pgtype.Finite,
+			Valid:            true,
+		},
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim stale in flight uploads: %w", err)
+	}
+
+	return ToUploadModels(rows), nil
+}
+
+// SetRetention sets the retention date of the upload, zero time removes it.
+func (r *UploadRepo) SetRetention(ctx context.Context, uploadID uuid.UUID, retainUntil time.Time) error {
+	err := r.querier.UpdateUploadRetention(ctx, pg.UpdateUploadRetentionParams{
+		RetainUntil: pgtype.Timestamptz{
+			Time:             retainUntil,
+			InfinityModifier: pgtype.Finite,
+			Valid:            !retainUntil.IsZero(),
+		},
+		ID: uploadID,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set upload retention: %w", err)
+	}
+
+	return nil
+}
+
+func (r *UploadRepo) SetLegalHold(ctx context.Context, uploadID uuid.UUID, legalHold bool) error {
+	err := r.querier.UpdateUploadLegalHold(ctx, pg.UpdateUploadLegalHoldParams{
+		LegalHold: legalHold,
+		ID:        uploadID,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set upload legal hold: %w", err)
+	}
+
+	return nil
+}
+
+// GetUploadsWithStaleDataKey returns up to limit encrypted uploads whose data key
+// is wrapped by a master key other than the given one.
+func (r *UploadRepo) GetUploadsWithStaleDataKey(
+	ctx context.Context, masterKeyID string, limit int32,
+) ([]model.Upload, error) {
+	rows, err := r.querier.GetUploadsWithStaleDataKey(ctx, pg.GetUploadsWithStaleDataKeyParams{
+		MasterKeyID: masterKeyID,
+		MaxCount:    limit,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uploads with stale data key: %w", err)
+	}
+
+	return ToUploadModels(rows), nil
+}
+
+func (r *UploadRepo) SetDataKey(ctx context.Context, uploadID uuid.UUID, encryptedDataKey []byte, masterKeyID string) error {
+	err := r.querier.UpdateUploadDataKey(ctx, pg.UpdateUploadDataKeyParams{
+		EncryptedDataKey: encryptedDataKey,
+		MasterKeyID:      masterKeyID,
+		ID:               uploadID,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to set upload data key: %w", err)
+	}
+
+	return nil
+}
+
+// GetOldInFlightUploads returns up to maxCount not committed uploads created before the time,
+// ordered by ID starting after the given one.
+func (r *UploadRepo) GetOldInFlightUploads(
+	ctx context.Context, from time.Time, after uuid.UUID, maxCount int32,
+) ([]model.Upload, error) {
+	rows, err := r.querier.GetOldInFlightUploads(ctx, pg.GetOldInFlightUploadsParams{
+		CreatedAt: pgtype.Timestamptz{
+			Time:             from,
+			InfinityModifier: pgtype.Finite,
+			Valid:            true,
+		},
+		After:    after,
+		MaxCount: maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get old in flight uploads: %w", err)
+	}
+
+	return ToUploadModels(rows), nil
+}
+
+// ListUploads returns up to maxCount uploads of the bucket with names starting with prefix, ordered by ID
+// starting after the given one. Empty bucket means all buckets.
+func (r *UploadRepo) ListUploads(
+	ctx context.Context, bucket, prefix string, after uuid.UUID, maxCount int32,
+) ([]model.Upload, error) {
+	rows, err := r.querier.ListUploads(ctx, pg.ListUploadsParams{
+		Bucket:   bucket,
+		Prefix:   prefix,
+		After:    after,
+		MaxCount: maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list uploads: %w", err)
+	}
+
+	return ToUploadModels(rows), nil
+}
+
+// ListObjects returns up to maxCount latest versions of objects of the bucket with names starting with prefix,
+// ordered by name starting after the given one. Deleted objects are skipped.
+func (r *UploadRepo) ListObjects(
+	ctx context.Context, bucket, prefix, after string, maxCount int32,
+) ([]model.Upload, error) {
+	rows, err := r.querier.ListObjects(ctx, pg.ListObjectsParams{
+		Bucket:   bucket,
+		Prefix:   prefix,
+		After:    after,
+		MaxCount: maxCount,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	return ToUploadModels(rows), nil
+}
+
+func (r *UploadRepo) GetUpload(ctx context.Context, id uuid.UUID) (model.Upload, error) {
+	row, err := r.querier.GetUpload(ctx, id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Upload{}, ErrNotFound
+	}
+
+	if err != nil {
+		return model.Upload{}, fmt.Errorf("failed to get upload: %w", err)
+	}
+
+	return ToUploadModel(row), nil
+}
+
+// GetLatestUpload returns the current version of the object, which may be a delete marker.
+func (r *UploadRepo) GetLatestUpload(ctx context.Context, bucket, name string) (model.Upload, error) {
+	row, err := r.querier.GetLatestUpload(ctx, pg.GetLatestUploadParams{
+		Bucket: bucket,
+		Name:   name,
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Upload{}, ErrNotFound
+	}
+
+	if err != nil {
+		return model.Upload{}, fmt.Errorf("failed to get latest upload: %w", err)
+	}
+
+	return ToUploadModel(row), nil
+}
+
+// GetNewestUploadVersion returns the most recently finished version of the object,
+// regardless of whether it is currently marked as latest.
+func (r *UploadRepo) GetNewestUploadVersion(ctx context.Context, bucket, name string) (model.Upload, error) {
+	row, err := r.querier.GetNewestUploadVersion(ctx, pg.GetNewestUploadVersionParams{
+		Bucket: bucket,
+		Name:   name,
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Upload{}, ErrNotFound
+	}
+
+	if err != nil {
+		return model.Upload{}, fmt.Errorf("failed to get newest upload version: %w", err)
+	}
+
+	return ToUploadModel(row), nil
+}
+
+func (r *UploadRepo) ListUploadVersions(ctx context.Context, bucket, name string) ([]model.Upload, error) {
+	rows, err := r.querier.ListUploadVersions(ctx, pg.ListUploadVersionsParams{
+		Bucket: bucket,
+		Name:   name,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list upload versions: %w", err)
+	}
+
+	return ToUploadModels(rows), nil
+}
+
+// LockKey serializes version changes of the object until the end of the transaction.
+func (r *UploadRepo) LockKey(ctx context.Context, bucket, name string) error {
+	err := r.querier.LockUploadKey(ctx, pg.LockUploadKeyParams{
+		Bucket: bucket,
+		Name:   name,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to lock upload key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *UploadRepo) UnsetLatest(ctx context.Context, bucket, name string) error {
+	err := r.querier.UnsetLatestUpload(ctx, pg.UnsetLatestUploadParams{
+		Bucket: bucket,
+		Name:   name,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to unset latest upload: %w", err)
+	}
+
+	return nil
+}
+
+func (r *UploadRepo) MarkUploadAsLatest(ctx context.Context, uploadID uuid.UUID) error {
+	err := r.querier.UpdateUploadAsLatest(
+		ctx,
+		uploadID,
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to mark upload as latest: %w", err)
+	}
+
+	return nil
 }
 
 func (r *UploadRepo) DeleteUploadByIDs(ctx context.Context, ids []uuid.UUID) error {
@@ -127,3 +459,34 @@ func (r *UploadRepo) WithTx(tx pgx.Tx) *UploadRepo {
 		qtx:     nil, // нельзя запускать транзакцию повторно
 	}
 }
+
+// ToUploadModel converts the uploads table row. It is shared with repos querying uploads on their own.
+func ToUploadModel(row pg.Upload) model.Upload {
+	return model.Upload{
+		ID:                     row.ID,
+		Bucket:                 row.Bucket,
+		Name:                   row.Name,
+		Size:                   row.Size,
+		Status:                 model.UploadStatus(row.Status),
+		IsLatest:               row.IsLatest,
+		IsDeleteMarker:         row.IsDeleteMarker,
+		RetainUntil:            row.RetainUntil.Time,
+		LegalHold:              row.LegalHold,
+		EncryptedDataKey:       row.EncryptedDataKey,
+		MasterKeyID:            row.MasterKeyID,
+		CustomerKeyFingerprint: row.CustomerKeyFingerprint,
+		ContentMD5:             row.ContentMd5,
+		Owner:                  row.Owner,
+		CreatedAt:              row.CreatedAt.Time,
+		UpdatedAt:              row.UpdatedAt.Time,
+	}
+}
+
+func ToUploadModels(rows []pg.Upload) []model.Upload {
+	uploads := make([]model.Upload, len(rows))
+	for i, r := range rows {
+		uploads[i] = ToUploadModel(r)
+	}
+
+	return uploads
+}
@@ -0,0 +1,199 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/quolpr/distributeds3/internal/queries/pg"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+)
+
+type VolumeRepo struct {
+	querier pg.Querier
+	// qtx - querier для запуска в транзакционном режиме.
+	qtx pg.QuerierTX
+}
+
+func NewVolumeRepo(querierTx pg.QuerierTX) *VolumeRepo {
+	return &VolumeRepo{
+		querier: querierTx,
+		qtx:     querierTx,
+	}
+}
+
+func (r *VolumeRepo) Create(ctx context.Context, volume model.Volume) error {
+	err := r.querier.InsertVolume(ctx, pg.InsertVolumeParams{
+		ID:        volume.ID,
+		ServerUrl: volume.ServerURL,
+		Size:      volume.Size,
+		LiveSize:  volume.LiveSize,
+		Sealed:    volume.Sealed,
+		CreatedAt: pgtype.Timestamptz{
+			Time:             volume.CreatedAt,
+			InfinityModifier: pgtype.Finite,
+			Valid:            true,
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to create volume: %w", err)
+	}
+
+	return nil
+}
+
+// GetWritableVolume locks a not sealed volume of the server that has at least freeSpace bytes
+// before maxSize. Volumes locked by other transactions are skipped. Returns ErrNotFound if there is no such volume.
+func (r *VolumeRepo) GetWritableVolume(
+	ctx context.Context, serverURL string, freeSpace, maxSize int64,
+) (model.Volume, error) {
+	row, err := r.querier.GetWritableVolume(ctx, pg.GetWritableVolumeParams{
+		ServerUrl: serverURL,
+		MaxSize:   maxSize - freeSpace,
+	})
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Volume{}, ErrNotFound
+	}
+
+	if err != nil {
+		return model.Volume{}, fmt.Errorf("failed to get writable volume: %w", err)
+	}
+
+	return toVolumeModel(row), nil
+}
+
+// LockVolume locks the volume till the end of the transaction.
+func (r *VolumeRepo) LockVolume(ctx context.Context, id uuid.UUID) (model.Volume, error) {
+	row, err := r.querier.LockVolume(ctx, id)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		return model.Volume{}, ErrNotFound
+	}
+
+	if err != nil {
+		return model.Volume{}, fmt.Errorf("failed to lock volume: %w", err)
+	}
+
+	return toVolumeModel(row), nil
+}
+
+// AddNeedle accounts the needle of length bytes appended to the volume, size is the new end of the volume.
+func (r *VolumeRepo) AddNeedle(ctx context.Context, id uuid.UUID, size, length int64) error {
+	err := r.querier.AddVolumeNeedle(ctx, pg.AddVolumeNeedleParams{
+		Size:   size,
+		Length: length,
+		ID:     id,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to add volume needle: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseNeedle accounts the needle of a deleted part as garbage.
+func (r *VolumeRepo) ReleaseNeedle(ctx context.Context, id uuid.UUID, length int64) error {
+	err := r.querier.ReleaseVolumeNeedle(ctx, pg.ReleaseVolumeNeedleParams{
+		Length: length,
+		ID:     id,
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to release volume needle: %w", err)
+	}
+
+	return nil
+}
+
+func (r *VolumeRepo) Seal(ctx context.Context, id uuid.UUID) error {
+	err := r.querier.SealVolume(ctx, id)
+
+	if err != nil {
+		return fmt.Errorf("failed to seal volume: %w", err)
+	}
+
+	return nil
+}
+
+func (r *VolumeRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	err := r.querier.DeleteVolume(ctx, id)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete volume: %w", err)
+	}
+
+	return nil
+}
+
+func (r *VolumeRepo) GetVolumesToCompact(ctx context.Context, garbageRatio float64) ([]model.Volume, error) {
+	rows, err := r.querier.GetVolumesToCompact(ctx, garbageRatio)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get volumes to compact: %w", err)
+	}
+
+	volumes := make([]model.Volume, len(rows))
+	for i, row := range rows {
+		volumes[i] = toVolumeModel(row)
+	}
+
+	return volumes, nil
+}
+
+func (r *VolumeRepo) GetServerVolumes(ctx context.Context, serverURL string) ([]model.Volume, error) {
+	rows, err := r.querier.GetServerVolumes(ctx, serverURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server volumes: %w", err)
+	}
+
+	volumes := make([]model.Volume, len(rows))
+	for i, row := range rows {
+		volumes[i] = toVolumeModel(row)
+	}
+
+	return volumes, nil
+}
+
+// GetStoredIDs returns IDs of the volumes of the server that exist.
+func (r *VolumeRepo) GetStoredIDs(ctx context.Context, serverURL string, ids []uuid.UUID) ([]uuid.UUID, error) {
+	stored, err := r.querier.GetStoredVolumeIDs(ctx, pg.GetStoredVolumeIDsParams{
+		ServerUrl: serverURL,
+		Ids:       ids,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stored volume ids: %w", err)
+	}
+
+	return stored, nil
+}
+
+func (r *VolumeRepo) WithTx(tx pgx.Tx) *VolumeRepo {
+	// если уже в транзакционном режиме - ничего не делаем
+	if r.qtx == nil {
+		return r
+	}
+
+	return &VolumeRepo{
+		querier: r.qtx.WithTx(tx),
+		qtx:     nil, // нельзя запускать транзакцию повторно
+	}
+}
+
+func toVolumeModel(row pg.Volume) model.Volume {
+	return model.Volume{
+		ID:        row.ID,
+		ServerURL: row.ServerUrl,
+		Size:      row.Size,
+		LiveSize:  row.LiveSize,
+		Sealed:    row.Sealed,
+		CreatedAt: row.CreatedAt.Time,
+	}
+}
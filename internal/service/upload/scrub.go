@@ -0,0 +1,192 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+)
+
+// defaultScrubBatchSize is the number of uploads loaded by one query.
+const defaultScrubBatchSize = 100
+
+// PartFailure is a part that couldn't be read back or whose data doesn't match the database.
+type PartFailure struct {
+	Part model.Part
+	Err  error
+}
+
+// VerifyReport holds the result of an upload verification.
+type VerifyReport struct {
+	Upload model.Upload
+	Parts  int
+	// Bytes is the size of the verified data as it is read by clients
+	Bytes    int64
+	Failures []PartFailure
+}
+
+// ScrubOptions are parameters of a scrub run.
+type ScrubOptions struct {
+	// BatchSize is the number of uploads loaded at once, defaultScrubBatchSize is used if it is zero
+	BatchSize int32
+}
+
+// ScrubReport holds the result of a scrub run.
+type ScrubReport struct {
+	Uploads int
+	Parts   int
+	Bytes   int64
+	// CorruptUploads are uploads with parts that failed verification
+	CorruptUploads []uuid.UUID
+}
+
+// VerifyUpload reads every part of the upload back from storage servers and checks that it decodes
+// to the size recorded in the database and, for deduplicated parts, to the recorded SHA-256.
+// Parts encrypted with a customer key are only checked to be readable unless the key is passed.
+// Part failures are collected into the report, the error is returned only if the check couldn't run.
+func (s *Service) VerifyUpload(ctx context.Context, id uuid.UUID, customerKey []byte) (VerifyReport, error) {
+	upload, parts, err := s.UploadParts(ctx, id)
+
+	if err != nil {
+		return VerifyReport{}, err
+	}
+
+	report := VerifyReport{Upload: upload, Parts: len(parts), Bytes: 0, Failures: nil}
+	rawOnly := len(upload.CustomerKeyFingerprint) > 0 && customerKey == nil
+
+	var key dataKey
+
+	if !rawOnly {
+		key, err = s.uploadDataKey(ctx, upload, customerKey)
+
+		if err != nil {
+			return report, err
+		}
+	}
+
+	for _, part := range parts {
+		if rawOnly {
+			err = s.readPartData(ctx, part, io.Discard)
+		} else {
+			err = s.verifyPart(ctx, part, key)
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return report, fmt.Errorf("unable to verify part %d: %w", part.Number, err)
+			}
+
+			report.Failures = append(report.Failures, PartFailure{Part: part, Err: err})
+
+			continue
+		}
+
+		report.Bytes += part.Size
+	}
+
+	return report, nil
+}
+
+func (s *Service) verifyPart(ctx context.Context, part model.Part, key dataKey) error {
+	digest := sha256.New()
+	counter := &countingWriter{writer: digest, n: 0}
+
+	if err := s.readPart(ctx, part, key, counter, false); err != nil {
+		return fmt.Errorf("unable to read part: %w", err)
+	}
+
+	if counter.n != part.Size {
+		return fmt.Errorf("%w: part is %d bytes, expected %d", ErrChecksumMismatch, counter.n, part.Size)
+	}
+
+	blob, err := s.blobRepo.Get(ctx, part.BlobID)
+
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to get blob: %w", err)
+	}
+
+	if !bytes.Equal(digest.Sum(nil), blob.Hash) {
+		return fmt.Errorf("%w: SHA-256 of the part differs from the blob hash", ErrChecksumMismatch)
+	}
+
+	return nil
+}
+
+// Scrub verifies every committed upload. Corrupt uploads are logged and collected into the report.
+func (s *Service) Scrub(ctx context.Context, opts ScrubOptions) (ScrubReport, error) {
+	var report ScrubReport
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultScrubBatchSize
+	}
+
+	after := uuid.Nil
+
+	for {
+		uploads, err := s.uploadRepo.ListUploads(ctx, "", "", after, opts.BatchSize)
+
+		if err != nil {
+			return report, fmt.Errorf("unable to list uploads: %w", err)
+		}
+
+		if len(uploads) == 0 {
+			return report, nil
+		}
+
+		for _, upload := range uploads {
+			if upload.Status != model.UploadStatusCommitted || upload.IsDeleteMarker {
+				continue
+			}
+
+			verified, err := s.VerifyUpload(ctx, upload.ID, nil)
+
+			// The upload may be deleted since it was listed
+			if errors.Is(err, ErrObjectNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return report, fmt.Errorf("unable to verify upload %s: %w", upload.ID, err)
+			}
+
+			report.Uploads++
+			report.Parts += verified.Parts
+			report.Bytes += verified.Bytes
+
+			for _, failure := range verified.Failures {
+				slog.Error("Corrupt part",
+					"upload", upload.ID, "part", failure.Part.Number, "server", failure.Part.ServerURL, "error", failure.Err,
+				)
+			}
+
+			if len(verified.Failures) > 0 {
+				report.CorruptUploads = append(report.CorruptUploads, upload.ID)
+			}
+		}
+
+		after = uploads[len(uploads)-1].ID
+	}
+}
+
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.n += int64(n)
+
+	return n, err //nolint:wrapcheck
+}
@@ -0,0 +1,332 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/service/storage"
+)
+
+// defaultRebalanceBatchSize is the number of blobs of a draining server loaded by one query.
+const defaultRebalanceBatchSize = 100
+
+var (
+	ErrServerNotFound = apperror.New(apperror.KindNotFound, "storage_server_not_found", "storage server not found")
+	ErrServerExists   = apperror.New(apperror.KindConflict, "storage_server_exists", "storage server already exists")
+	ErrInvalidServer  = apperror.New(
+		apperror.KindInvalidInput, "invalid_storage_server", "storage server URL must be an absolute http(s) URL",
+	)
+)
+
+// errBlobGone rolls back moving of a blob whose parts were deleted while it was copied.
+var errBlobGone = errors.New("blob is not referenced anymore")
+
+// RebalanceOptions are parameters of a rebalance run.
+type RebalanceOptions struct {
+	// BatchSize is the number of blobs loaded at once, defaultRebalanceBatchSize is used if it is zero
+	BatchSize int32
+	// DryRun only reports data that would be moved
+	DryRun bool
+}
+
+// RebalanceReport holds the result of a rebalance run.
+type RebalanceReport struct {
+	// Servers are draining servers data was moved from
+	Servers      []string
+	MovedBlobs   int
+	MovedVolumes int
+	MovedParts   int64
+	MovedBytes   int64
+	// Failed is the number of blobs and volumes that couldn't be moved, they are retried by the next run
+	Failed int
+}
+
+// DrainServer stops placing new parts on the server. Its data is moved to other servers by Rebalance.
+func (s *Service) DrainServer(ctx context.Context, serverURL string) error {
+	return s.setDraining(ctx, serverURL, true)
+}
+
+// UndrainServer returns a drained server into rotation, new parts are placed on it again.
+func (s *Service) UndrainServer(ctx context.Context, serverURL string) error {
+	return s.setDraining(ctx, serverURL, false)
+}
+
+// AddServer adds a storage server to the cluster on top of STORAGE_SERVERS. It is persisted,
+// so all API instances place new parts on it without a restart.
+func (s *Service) AddServer(ctx context.Context, serverURL string) error {
+	parsed, err := url.Parse(serverURL)
+
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%w: %q", ErrInvalidServer, serverURL)
+	}
+
+	servers, err := s.storageService.GetAvailableServers(ctx)
+
+	if err != nil {
+		return fmt.Errorf("unable to get servers: %w", err)
+	}
+
+	if slices.Contains(servers, serverURL) {
+		return fmt.Errorf("%w: %s", ErrServerExists, serverURL)
+	}
+
+	added, err := s.serverRepo.Add(ctx, serverURL)
+
+	if err != nil {
+		return fmt.Errorf("unable to add server: %w", err)
+	}
+
+	if !added {
+		return fmt.Errorf("%w: %s", ErrServerExists, serverURL)
+	}
+
+	slog.Info("Storage server added", "server", serverURL)
+
+	return nil
+}
+
+func (s *Service) setDraining(ctx context.Context, serverURL string, draining bool) error {
+	servers, err := s.storageService.ServersHealth(ctx)
+
+	if err != nil {
+		return fmt.Errorf("unable to get servers: %w", err)
+	}
+
+	known := slices.ContainsFunc(servers, func(server storage.ServerHealth) bool {
+		return server.ServerURL == serverURL
+	})
+
+	if !known {
+		return fmt.Errorf("%w: %s", ErrServerNotFound, serverURL)
+	}
+
+	if err := s.serverRepo.SetDraining(ctx, serverURL, draining); err != nil {
+		return fmt.Errorf("unable to set server draining: %w", err)
+	}
+
+	slog.Info("Storage server updated", "server", serverURL, "draining", draining)
+
+	return nil
+}
+
+// drainingServers returns the set of servers that get no new parts.
+func (s *Service) drainingServers(ctx context.Context) (map[string]bool, error) {
+	servers, err := s.serverRepo.GetAll(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to get storage servers: %w", err)
+	}
+
+	draining := make(map[string]bool, len(servers))
+	for _, server := range servers {
+		draining[server.URL] = server.Draining
+	}
+
+	return draining, nil
+}
+
+// Rebalance moves parts and volumes of draining servers to the other servers round-robin.
+// A blob or volume that fails to move is skipped and counted in the report.
+func (s *Service) Rebalance(ctx context.Context, opts RebalanceOptions) (RebalanceReport, error) {
+	var report RebalanceReport
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultRebalanceBatchSize
+	}
+
+	draining, err := s.drainingServers(ctx)
+
+	if err != nil {
+		return report, err
+	}
+
+	for serverURL, isDraining := range draining {
+		if isDraining {
+			report.Servers = append(report.Servers, serverURL)
+		}
+	}
+
+	if len(report.Servers) == 0 {
+		return report, nil
+	}
+
+	slices.Sort(report.Servers)
+
+	targets, err := s.shuffledServers(ctx)
+
+	if err != nil {
+		return report, err
+	}
+
+	next := 0
+	target := func() string {
+		next++
+
+		return targets[next%len(targets)]
+	}
+
+	for _, serverURL := range report.Servers {
+		if err := s.drainBlobs(ctx, serverURL, target, opts, &report); err != nil {
+			return report, err
+		}
+
+		if err := s.drainVolumes(ctx, serverURL, target, opts, &report); err != nil {
+			return report, err
+		}
+	}
+
+	return report, nil
+}
+
+func (s *Service) drainBlobs(
+	ctx context.Context, serverURL string, target func() string, opts RebalanceOptions, report *RebalanceReport,
+) error {
+	after := uuid.Nil
+
+	for {
+		ids, err := s.partRepo.GetServerBlobIDs(ctx, serverURL, after, opts.BatchSize)
+
+		if err != nil {
+			return fmt.Errorf("unable to get server blobs: %w", err)
+		}
+
+		if len(ids) == 0 {
+			return nil
+		}
+
+		for _, id := range ids {
+			targetURL := target()
+
+			if opts.DryRun {
+				slog.Info("Blob to move", "blob", id, "server", serverURL, "target", targetURL)
+				report.MovedBlobs++
+
+				continue
+			}
+
+			moved, size, err := s.moveBlob(ctx, id, serverURL, targetURL)
+
+			if err != nil {
+				if ctx.Err() != nil {
+					return fmt.Errorf("unable to move blob %s: %w", id, err)
+				}
+
+				slog.Error("Unable to move blob", "blob", id, "server", serverURL, "target", targetURL, "error", err)
+				report.Failed++
+
+				continue
+			}
+
+			if moved > 0 {
+				report.MovedBlobs++
+				report.MovedParts += moved
+				report.MovedBytes += size
+			}
+		}
+
+		after = ids[len(ids)-1]
+	}
+}
+
+// moveBlob copies the data to the target server and points parts to the copy. The data on the old server
+// is cleaned after the parts are moved, the GC removes it if cleaning fails. Returns the number of moved parts.
+func (s *Service) moveBlob(ctx context.Context, id uuid.UUID, serverURL, targetURL string) (int64, int64, error) {
+	var data bytes.Buffer
+
+	if err := s.storageService.ReadPart(ctx, id, serverURL, &data); err != nil {
+		return 0, 0, fmt.Errorf("unable to read blob: %w", err)
+	}
+
+	size := int64(data.Len())
+
+	if err := s.storageService.UploadPart(ctx, id, targetURL, &data); err != nil {
+		return 0, 0, fmt.Errorf("unable to copy blob: %w", err)
+	}
+
+	var moved int64
+
+	err := s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		// Updating the blob first locks it, so it can't be referenced by a new part while parts are moved
+		if err := s.blobRepo.WithTx(tx).SetServer(ctx, id, targetURL); err != nil {
+			return fmt.Errorf("unable to set blob server: %w", err)
+		}
+
+		var err error
+		moved, err = s.partRepo.WithTx(tx).MoveBlob(ctx, id, serverURL, targetURL)
+
+		if err != nil {
+			return fmt.Errorf("unable to move parts: %w", err)
+		}
+
+		if moved == 0 {
+			return errBlobGone
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		if cleanErr := s.storageService.CleanPart(ctx, id, targetURL); cleanErr != nil {
+			slog.Warn("Unable to clean blob copy", "blob", id, "server", targetURL, "error", cleanErr)
+		}
+
+		if errors.Is(err, errBlobGone) {
+			return 0, 0, nil
+		}
+
+		return 0, 0, err
+	}
+
+	if err := s.storageService.CleanPart(ctx, id, serverURL); err != nil {
+		slog.Warn("Unable to clean moved blob", "blob", id, "server", serverURL, "error", err)
+	}
+
+	return moved, size, nil
+}
+
+func (s *Service) drainVolumes(
+	ctx context.Context, serverURL string, target func() string, opts RebalanceOptions, report *RebalanceReport,
+) error {
+	volumes, err := s.volumeRepo.GetServerVolumes(ctx, serverURL)
+
+	if err != nil {
+		return fmt.Errorf("unable to get server volumes: %w", err)
+	}
+
+	for _, volume := range volumes {
+		targetURL := target()
+
+		slog.Info("Moving volume", "volume", volume.ID, "server", serverURL, "target", targetURL, "dry_run", opts.DryRun)
+
+		if opts.DryRun {
+			report.MovedVolumes++
+			report.MovedBytes += volume.LiveSize
+
+			continue
+		}
+
+		if err := s.relocateVolume(ctx, volume, targetURL); err != nil {
+			if ctx.Err() != nil {
+				return fmt.Errorf("unable to move volume %s: %w", volume.ID, err)
+			}
+
+			slog.Error("Unable to move volume", "volume", volume.ID, "target", targetURL, "error", err)
+			report.Failed++
+
+			continue
+		}
+
+		report.MovedVolumes++
+		report.MovedBytes += volume.LiveSize
+	}
+
+	return nil
+}
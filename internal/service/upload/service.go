@@ -1,7 +1,9 @@
 package upload
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5" //nolint:gosec
 	"fmt"
 	"io"
 	"log/slog"
@@ -9,221 +11,343 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
+	"github.com/quolpr/distributeds3/internal/apperror"
+	"github.com/quolpr/distributeds3/internal/metrics"
+	"github.com/quolpr/distributeds3/internal/service/kms"
 	"github.com/quolpr/distributeds3/internal/service/storage"
 	"github.com/quolpr/distributeds3/internal/service/upload/model"
 	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+	"github.com/quolpr/distributeds3/pkg/chunker"
+	"github.com/quolpr/distributeds3/pkg/compression"
 	"github.com/quolpr/distributeds3/pkg/transaction"
-	"golang.org/x/exp/maps"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const (
-	defaultParts         = 6
-	defaultMaxUploadTime = time.Hour * 24
+// DefaultBucket is used for uploads that don't specify a bucket.
+const DefaultBucket = "default"
+
+const tracerName = "github.com/quolpr/distributeds3/internal/service/upload"
+
+var (
+	ErrObjectNotFound   = apperror.New(apperror.KindNotFound, "object_not_found", "object not found")
+	ErrObjectLocked     = apperror.New(apperror.KindConflict, "object_locked", "object is locked")
+	ErrChecksumMismatch = apperror.New(apperror.KindChecksumMismatch, "checksum_mismatch", "checksum doesn't match")
+	ErrSizeMismatch     = apperror.New(apperror.KindBadRequest, "size_mismatch", "body size doesn't match declared size")
 )
 
 type Service struct {
 	partRepo       *repo.PartRepo
 	uploadRepo     *repo.UploadRepo
+	blobRepo       *repo.BlobRepo
+	volumeRepo     *repo.VolumeRepo
+	cleanupRepo    *repo.CleanupRepo
+	serverRepo     *repo.ServerRepo
 	storageService *storage.Service
 	transaction    *transaction.Transaction
+	// kms wraps per-upload data keys, parts are stored in plaintext if it is nil.
+	kms kms.KMS
+	// codec is used to compress parts if it is not set in UploadOptions.
+	codec compression.Codec
+	// chunking splits uploads into parts
+	chunking chunker.Config
+	packing  PackingConfig
+	pipeline PipelineConfig
+	recovery RecoveryConfig
+	metrics  *metrics.Metrics
 
-	parts         int64
 	maxUploadTime time.Duration
 }
 
 func NewService(
-	partRepo *repo.PartRepo, uploadRepo *repo.UploadRepo, storageService *storage.Service, tr *transaction.Transaction,
+	partRepo *repo.PartRepo, uploadRepo *repo.UploadRepo, blobRepo *repo.BlobRepo, volumeRepo *repo.VolumeRepo,
+	cleanupRepo *repo.CleanupRepo, serverRepo *repo.ServerRepo, storageService *storage.Service,
+	tr *transaction.Transaction, kms kms.KMS,
+	codec compression.Codec, chunking chunker.Config, packing PackingConfig, pipeline PipelineConfig,
+	recovery RecoveryConfig, metrics *metrics.Metrics, maxUploadTime time.Duration,
 ) *Service {
 	return &Service{
 		partRepo:       partRepo,
 		uploadRepo:     uploadRepo,
+		blobRepo:       blobRepo,
+		volumeRepo:     volumeRepo,
+		cleanupRepo:    cleanupRepo,
+		serverRepo:     serverRepo,
 		storageService: storageService,
-		parts:          defaultParts,
 		transaction:    tr,
-		maxUploadTime:  defaultMaxUploadTime,
+		kms:            kms,
+		codec:          codec,
+		chunking:       chunking,
+		packing:        packing,
+		pipeline:       pipeline,
+		recovery:       recovery,
+		metrics:        metrics,
+		maxUploadTime:  maxUploadTime,
 	}
 }
 
-func (s *Service) ReadUpload(ctx context.Context, id uuid.UUID, writer io.Writer) error {
-	parts, err := s.partRepo.GetParts(ctx, id)
+// UploadOptions are optional parameters of a new upload.
+type UploadOptions struct {
+	// CustomerKey encrypts parts instead of the server-side encryption (SSE-C).
+	CustomerKey []byte
+	// Codec overrides the default compression codec.
+	Codec compression.Codec
+	// ContentMD5 is the MD5 digest of the data, the upload fails with ErrChecksumMismatch if it doesn't match.
+	ContentMD5 []byte
+}
+
+// ReadOptions are optional parameters of reading an upload.
+type ReadOptions struct {
+	// CustomerKey must be the same key the upload was created with.
+	CustomerKey []byte
+	// AcceptGzip allows to stream gzip compressed parts as is, without decompression.
+	AcceptGzip bool
+}
+
+func (s *Service) ReadUpload(ctx context.Context, id uuid.UUID, writer io.Writer, opts ReadOptions) error {
+	reader, err := s.OpenUpload(ctx, id, opts)
 
 	if err != nil {
-		return fmt.Errorf("unable to get parts: %w", err)
+		return err
 	}
 
-	if len(parts) == 0 {
-		return fmt.Errorf("no parts found")
-	}
+	return reader.Stream(ctx, writer)
+}
 
-	for _, part := range parts {
-		err := s.storageService.ReadPart(ctx, part.ID, part.ServerURL, writer)
+// CreateUpload stores a new version of the bucket/fileName object. The version becomes
+// the latest one only after all of its parts are uploaded. Locked objects can't be overwritten.
+// Progress of the upload is persisted, so an upload interrupted by a crash is recovered on restart.
+func (s *Service) CreateUpload(
+	ctx context.Context, bucket string, fileSize int64,
+	fileName string, reader io.Reader, opts UploadOptions,
+) (model.Upload, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "upload.CreateUpload", trace.WithAttributes(
+		attribute.String("upload.bucket", bucket),
+		attribute.String("upload.name", fileName),
+		attribute.Int64("upload.size", fileSize),
+	))
+	defer span.End()
+
+	if err := checkNotLocked(ctx, s.uploadRepo, bucket, fileName); err != nil {
+		return model.Upload{}, err
+	}
 
-		slog.Info("Reading part", "part", part, "parts", len(parts))
+	key, err := s.newDataKey(ctx, opts.CustomerKey)
 
-		if err != nil {
-			return fmt.Errorf("unable to get part: %w", err)
-		}
+	if err != nil {
+		return model.Upload{}, err
 	}
 
-	return nil
-}
+	codec := opts.Codec
+	if codec == "" {
+		codec = s.codec
+	}
 
-func (s *Service) CreateUpload(
-	ctx context.Context, fileSize int64,
-	fileName string, reader io.Reader,
-) (model.Upload, error) {
-	upload, parts, err := s.persistUpload(ctx, fileSize, fileName)
+	servers, err := s.shuffledServers(ctx)
 
 	if err != nil {
 		return model.Upload{}, err
 	}
 
-	for _, part := range parts {
-		reader := io.LimitReader(reader, part.Size)
+	upload, err := s.persistUpload(ctx, bucket, fileSize, fileName, key)
 
-		slog.Info("Uploading part", "part", part, "parts", len(parts))
+	if err != nil {
+		return model.Upload{}, err
+	}
 
-		err := s.storageService.UploadPart(ctx, part.ID, part.ServerURL, reader)
+	span.SetAttributes(attribute.String("upload.id", upload.ID.String()))
 
-		if err != nil {
-			return model.Upload{}, fmt.Errorf("unable to upload part: %w", err)
-		}
+	s.metrics.ActiveUploads.Inc()
+	defer s.metrics.ActiveUploads.Dec()
 
-		err = s.partRepo.MarkPartAsDone(ctx, part.ID)
+	upload.ContentMD5, err = s.writeUpload(ctx, upload, servers, key, codec, reader, opts.ContentMD5)
 
-		if err != nil {
-			return model.Upload{}, fmt.Errorf("unable to mark part as done: %w", err)
-		}
+	if err == nil {
+		err = s.commitVersion(ctx, upload)
 	}
 
-	err = s.uploadRepo.MarkUploadAsDone(ctx, upload.ID)
-
 	if err != nil {
-		return model.Upload{}, fmt.Errorf("unable to mark upload as done: %w", err)
+		span.SetStatus(codes.Error, err.Error())
+		s.failUpload(ctx, upload, err)
+
+		return model.Upload{}, err
 	}
 
+	upload.Status = model.UploadStatusCommitted
+	upload.IsLatest = true
+
+	s.metrics.UploadedBytes.Add(float64(upload.Size))
+
 	return upload, nil
 }
 
-func (s *Service) CleanDangleUploads(ctx context.Context) error {
-	parts, err := s.partRepo.GetOldInProgressParts(ctx, time.Now().Add(-s.maxUploadTime))
+// writeUpload stores the upload data and marks the upload as written. Returns the MD5 digest of the data,
+// it must match contentMD5 if it is set. The reader must hold exactly upload.Size bytes, otherwise
+// the upload fails with ErrSizeMismatch.
+func (s *Service) writeUpload(
+	ctx context.Context, upload model.Upload, servers []string, key dataKey, codec compression.Codec, reader io.Reader,
+	contentMD5 []byte,
+) ([]byte, error) {
+	err := s.uploadRepo.SetStatus(ctx, upload.ID, model.UploadStatusPending, model.UploadStatusWriting)
 
 	if err != nil {
-		return fmt.Errorf("unable to get old in progress uploads: %w", err)
+		return nil, fmt.Errorf("unable to mark upload as writing: %w", err)
 	}
 
-	for _, part := range parts {
-		err := s.storageService.CleanPart(ctx, part.ID, part.ServerURL)
+	reader = s.newTouchingReader(ctx, upload, reader)
+	digest := md5.New() //nolint:gosec
+	counter := &countingWriter{writer: digest, n: 0}
+	body := io.TeeReader(io.LimitReader(reader, upload.Size), counter)
 
-		if err != nil {
-			return fmt.Errorf("unable to clean part: %w", err)
-		}
+	if upload.Size < s.packing.Threshold {
+		err = s.uploadPacked(ctx, upload, servers[0], key, codec, body)
+	} else {
+		err = s.uploadChunks(ctx, upload, servers, key, codec, body)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if counter.n != upload.Size {
+		return nil, fmt.Errorf("%w: got %d of %d bytes", ErrSizeMismatch, counter.n, upload.Size)
+	}
+
+	if n, _ := io.ReadFull(reader, make([]byte, 1)); n > 0 {
+		return nil, fmt.Errorf("%w: body is longer than %d bytes", ErrSizeMismatch, upload.Size)
 	}
 
-	ids := make(map[uuid.UUID]struct{})
+	sum := digest.Sum(nil)
 
-	for _, part := range parts {
-		ids[part.UploadID] = struct{}{}
+	if contentMD5 != nil && !bytes.Equal(sum, contentMD5) {
+		return nil, fmt.Errorf("%w: Content-MD5 differs from the MD5 of the data", ErrChecksumMismatch)
 	}
 
-	// Due to cascade deletes in parts table, parts will be deleted too
-	err = s.uploadRepo.DeleteUploadByIDs(ctx, maps.Keys(ids))
+	if err := s.uploadRepo.MarkUploadAsWritten(ctx, upload.ID, s.recovery.InstanceID, sum); err != nil {
+		return nil, fmt.Errorf("unable to mark upload as written: %w", err)
+	}
+
+	return sum, nil
+}
+
+// uploadPacked stores a small upload as a single part packed into a volume of the server.
+func (s *Service) uploadPacked(
+	ctx context.Context, upload model.Upload, serverURL string, key dataKey, codec compression.Codec, reader io.Reader,
+) error {
+	part, err := s.persistPart(ctx, upload, 0, upload.Size, serverURL, codec)
 
 	if err != nil {
-		return fmt.Errorf("unable to delete uploads: %w", err)
+		return err
 	}
 
-	return nil
+	slog.Info("Packing part", "part", part)
+
+	return s.uploadPackedPart(ctx, part, key, reader)
+}
+
+// AbortUploads removes not committed uploads together with their already uploaded parts.
+// Locked uploads are skipped.
+func (s *Service) AbortUploads(ctx context.Context, uploads []model.Upload) error {
+	_, err := s.deleteUploads(ctx, unlockedIDs(uploads))
+
+	return err
 }
 
 func (s *Service) persistUpload(
-	ctx context.Context, fileSize int64, fileName string,
-) (model.Upload, []model.Part, error) {
+	ctx context.Context, bucket string, fileSize int64, fileName string, key dataKey,
+) (model.Upload, error) {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "upload.persistUpload")
+	defer span.End()
+
 	upload := model.Upload{
-		ID:        uuid.New(),
-		Name:      fileName,
-		Size:      fileSize,
-		CreatedAt: time.Now(),
-		Status:    model.UploadStatusInProgress,
+		ID:                     uuid.New(),
+		Bucket:                 bucket,
+		Name:                   fileName,
+		Size:                   fileSize,
+		CreatedAt:              time.Now(),
+		Status:                 model.UploadStatusPending,
+		IsLatest:               false,
+		IsDeleteMarker:         false,
+		RetainUntil:            time.Time{},
+		LegalHold:              false,
+		EncryptedDataKey:       key.encrypted,
+		MasterKeyID:            key.masterKeyID,
+		CustomerKeyFingerprint: key.customerKeyFingerprint,
+		ContentMD5:             nil,
+		Owner:                  s.recovery.InstanceID,
+		UpdatedAt:              time.Time{},
 	}
-	parts := make([]model.Part, s.parts)
 
-	servers, err := s.storageService.GetAvailableServers(ctx)
+	err := s.uploadRepo.Create(ctx, upload)
 
 	if err != nil {
-		return upload, parts, fmt.Errorf("unable to get available servers: %w", err)
+		return upload, fmt.Errorf("unable to create upload: %w", err)
 	}
 
-	randomServers, err := takeRandomServers(servers, int(s.parts))
+	return upload, nil
+}
 
-	if err != nil {
-		return upload, parts, err
+// persistPart creates a pending part of the upload, so its data is cleaned if the upload is never finished.
+func (s *Service) persistPart(
+	ctx context.Context, upload model.Upload, number int32, size int64, serverURL string, codec compression.Codec,
+) (model.Part, error) {
+	id := uuid.New()
+	part := model.Part{
+		ID:             id,
+		ServerURL:      serverURL,
+		UploadID:       upload.ID,
+		Number:         number,
+		Size:           size,
+		Codec:          codec,
+		CompressedSize: 0,
+		BlobID:         id,
+		VolumeID:       uuid.Nil,
+		VolumeOffset:   0,
+		VolumeLength:   0,
+		Status:         model.UploadStatusPending,
+		CreatedAt:      time.Now(),
 	}
 
-	for i := range s.parts {
-		size := fileSize / s.parts
+	err := s.partRepo.Create(ctx, part)
 
-		if i == s.parts-1 {
-			size += fileSize % s.parts
-		}
-
-		parts[i] = model.Part{
-			ID:        uuid.New(),
-			ServerURL: randomServers[i],
-			UploadID:  upload.ID,
-			Number:    int32(i),
-			Size:      size,
-			Status:    model.UploadStatusInProgress,
-			CreatedAt: time.Now(),
-		}
+	if err != nil {
+		return part, fmt.Errorf("unable to create part: %w", err)
 	}
 
-	err = s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
-		err := s.uploadRepo.WithTx(tx).Create(ctx, upload)
-		if err != nil {
-			return fmt.Errorf("unable to create upload: %w", err)
-		}
-
-		for i := range parts {
-			err := s.partRepo.WithTx(tx).Create(ctx, parts[i])
-			if err != nil {
-				return fmt.Errorf("unable to create part: %w", err)
-			}
-		}
+	return part, nil
+}
 
-		return nil
-	})
+// shuffledServers returns available not draining servers in random order, parts are spread over them round-robin.
+func (s *Service) shuffledServers(ctx context.Context) ([]string, error) {
+	servers, err := s.storageService.GetAvailableServers(ctx)
 
 	if err != nil {
-		return upload, parts, fmt.Errorf("unable to create upload: %w", err)
+		return nil, fmt.Errorf("unable to get available servers: %w", err)
 	}
 
-	return upload, parts, nil
-}
+	draining, err := s.drainingServers(ctx)
 
-func takeRandomServers(initialServers []string, n int) ([]string, error) {
-	if len(initialServers) == 0 {
-		return nil, fmt.Errorf("no servers available")
+	if err != nil {
+		return nil, err
 	}
 
-	result := make([]string, 0, n)
-	servers := make([]string, len(initialServers))
-	copy(servers, initialServers)
+	shuffled := make([]string, 0, len(servers))
 
-	for range n {
-		if len(servers) == 0 {
-			servers = servers[:len(initialServers)]
+	for _, server := range servers {
+		if !draining[server] {
+			shuffled = append(shuffled, server)
 		}
+	}
 
-		idx := rand.Intn(len(servers)) //nolint:gosec
-		server := servers[idx]
-
-		servers[idx] = servers[len(servers)-1]
-		servers = servers[:len(servers)-1]
-
-		result = append(result, server)
+	if len(shuffled) == 0 {
+		return nil, storage.ErrNoServers
 	}
 
-	return result, nil
+	rand.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+
+	return shuffled, nil
 }
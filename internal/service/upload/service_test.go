@@ -0,0 +1,111 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/quolpr/distributeds3/internal/metrics"
+	"github.com/quolpr/distributeds3/internal/queries/pg"
+	"github.com/quolpr/distributeds3/internal/service/kms"
+	"github.com/quolpr/distributeds3/internal/service/storage"
+	storageRepo "github.com/quolpr/distributeds3/internal/service/storage/repo"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+	"github.com/quolpr/distributeds3/internal/testdb"
+	"github.com/quolpr/distributeds3/pkg/chunker"
+	"github.com/quolpr/distributeds3/pkg/compression"
+	"github.com/quolpr/distributeds3/pkg/transaction"
+)
+
+const (
+	testServer   = "http://storage-1"
+	testInstance = "test-instance"
+	testBucket   = "bucket"
+	// testChunkSize is small, so short test data is split into several parts
+	testChunkSize = 16
+)
+
+// testEnv is the upload service backed by the test database and the given storage.
+type testEnv struct {
+	svc  *Service
+	pool *pgxpool.Pool
+}
+
+// newTestEnv skips the test if the test database is not configured. Parts are stored in plaintext
+// if keyManager is nil.
+func newTestEnv(t *testing.T, storageRepository storageRepo.Repo, keyManager kms.KMS) *testEnv {
+	t.Helper()
+
+	pool := testdb.New(t)
+	queries := pg.NewTxQueries(pg.New(pool))
+	serverRepo := repo.NewServerRepo(queries)
+	serviceMetrics := metrics.New()
+
+	storageService := storage.NewService(storageRepository, serverRepo, storage.Policy{
+		Timeout:    0,
+		Retries:    0,
+		Backoff:    time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	}, storage.BreakerConfig{Window: 100, FailureRate: 0, SlowCall: 0, OpenTimeout: time.Minute}, serviceMetrics)
+
+	partRepo := repo.NewPartRepo(queries)
+	svc := NewService(
+		partRepo, repo.NewUploadRepo(queries, partRepo), repo.NewBlobRepo(queries), repo.NewVolumeRepo(queries),
+		repo.NewCleanupRepo(queries), serverRepo, storageService,
+		transaction.New(pool), keyManager, compression.CodecNone,
+		chunker.Config{Algorithm: chunker.AlgorithmFixed, MinSize: 0, AvgSize: testChunkSize, MaxSize: 0},
+		PackingConfig{Threshold: 0, VolumeMaxSize: 0, CompactionRatio: 1, DeleteDelay: 0},
+		PipelineConfig{Concurrency: 1, MemoryBudget: testChunkSize},
+		RecoveryConfig{InstanceID: testInstance, StaleAfter: time.Minute, Interval: time.Minute},
+		serviceMetrics, time.Hour,
+	)
+
+	return &testEnv{svc: svc, pool: pool}
+}
+
+// upload stores data as a new version of the key.
+func (e *testEnv) upload(t *testing.T, key, data string, opts UploadOptions) model.Upload {
+	t.Helper()
+
+	upload, err := e.svc.CreateUpload(
+		context.Background(), testBucket, int64(len(data)), key, strings.NewReader(data), opts,
+	)
+
+	if err != nil {
+		t.Fatalf("unable to upload %s: %v", key, err)
+	}
+
+	return upload
+}
+
+// read returns the data of the upload.
+func (e *testEnv) read(t *testing.T, id uuid.UUID) string {
+	t.Helper()
+
+	var data bytes.Buffer
+
+	//nolint:exhaustruct
+	if err := e.svc.ReadUpload(context.Background(), id, &data, ReadOptions{}); err != nil {
+		t.Fatalf("unable to read upload %s: %v", id, err)
+	}
+
+	return data.String()
+}
+
+// count returns the number of rows matching the query.
+func (e *testEnv) count(t *testing.T, query string, args ...any) int {
+	t.Helper()
+
+	var n int
+
+	if err := e.pool.QueryRow(context.Background(), query, args...).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+
+	return n
+}
@@ -0,0 +1,296 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+)
+
+// GetObjectVersion resolves the version of the object. uuid.Nil versionID means the latest version.
+// Delete markers are reported as ErrObjectNotFound.
+func (s *Service) GetObjectVersion(
+	ctx context.Context, bucket, key string, versionID uuid.UUID,
+) (model.Upload, error) {
+	var (
+		upload model.Upload
+		err    error
+	)
+
+	if versionID == uuid.Nil {
+		upload, err = s.uploadRepo.GetLatestUpload(ctx, bucket, key)
+	} else {
+		upload, err = s.getVersion(ctx, s.uploadRepo, bucket, key, versionID)
+	}
+
+	if errors.Is(err, repo.ErrNotFound) {
+		return model.Upload{}, ErrObjectNotFound
+	}
+
+	if err != nil {
+		return model.Upload{}, fmt.Errorf("unable to get object version: %w", err)
+	}
+
+	if upload.IsDeleteMarker {
+		return model.Upload{}, ErrObjectNotFound
+	}
+
+	return upload, nil
+}
+
+// ListObjectVersions returns all finished versions of the object, newest first.
+func (s *Service) ListObjectVersions(ctx context.Context, bucket, key string) ([]model.Upload, error) {
+	uploads, err := s.uploadRepo.ListUploadVersions(ctx, bucket, key)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to list object versions: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// ListObjects returns up to maxCount latest versions of objects with names starting with prefix, ordered by name
+// starting after the given one. Deleted objects are not listed.
+func (s *Service) ListObjects(ctx context.Context, bucket, prefix, after string, maxCount int32) ([]model.Upload, error) {
+	uploads, err := s.uploadRepo.ListObjects(ctx, bucket, prefix, after, maxCount)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to list objects: %w", err)
+	}
+
+	return uploads, nil
+}
+
+// DeleteObject hides the object behind a delete marker. Previous versions are kept
+// and still can be read by their version ID.
+func (s *Service) DeleteObject(ctx context.Context, bucket, key string) (model.Upload, error) {
+	return s.putDeleteMarker(ctx, bucket, key, uuid.Nil)
+}
+
+// ExpireObject puts a delete marker on top of the upload if it is still the latest version.
+// Locked versions are left untouched.
+func (s *Service) ExpireObject(ctx context.Context, upload model.Upload) error {
+	_, err := s.putDeleteMarker(ctx, upload.Bucket, upload.Name, upload.ID)
+
+	if err != nil && !errors.Is(err, ErrObjectNotFound) && !errors.Is(err, ErrObjectLocked) {
+		return err
+	}
+
+	return nil
+}
+
+// putDeleteMarker makes a delete marker the latest version. If expectedLatestID is set,
+// the marker is put only when it is the current latest version.
+func (s *Service) putDeleteMarker(
+	ctx context.Context, bucket, key string, expectedLatestID uuid.UUID,
+) (model.Upload, error) {
+	marker := model.Upload{
+		ID:                     uuid.New(),
+		Bucket:                 bucket,
+		Name:                   key,
+		Size:                   0,
+		Status:                 model.UploadStatusCommitted,
+		IsLatest:               true,
+		IsDeleteMarker:         true,
+		CreatedAt:              time.Now(),
+		RetainUntil:            time.Time{},
+		LegalHold:              false,
+		EncryptedDataKey:       nil,
+		MasterKeyID:            "",
+		CustomerKeyFingerprint: nil,
+		ContentMD5:             nil,
+		Owner:                  s.recovery.InstanceID,
+		UpdatedAt:              time.Time{},
+	}
+
+	err := s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		uploadRepo := s.uploadRepo.WithTx(tx)
+
+		if err := uploadRepo.LockKey(ctx, bucket, key); err != nil {
+			return fmt.Errorf("unable to lock object: %w", err)
+		}
+
+		latest, err := uploadRepo.GetLatestUpload(ctx, bucket, key)
+
+		if errors.Is(err, repo.ErrNotFound) || (err == nil && latest.IsDeleteMarker) {
+			return ErrObjectNotFound
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to get latest version: %w", err)
+		}
+
+		if expectedLatestID != uuid.Nil && latest.ID != expectedLatestID {
+			return ErrObjectNotFound
+		}
+
+		if latest.IsLocked(time.Now()) {
+			return ErrObjectLocked
+		}
+
+		if err := uploadRepo.UnsetLatest(ctx, bucket, key); err != nil {
+			return fmt.Errorf("unable to unset latest version: %w", err)
+		}
+
+		if err := uploadRepo.Create(ctx, marker); err != nil {
+			return fmt.Errorf("unable to create upload: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return model.Upload{}, fmt.Errorf("unable to create delete marker: %w", err)
+	}
+
+	return marker, nil
+}
+
+// DeleteObjectVersion permanently removes the version with its parts. If it was the latest
+// version, the next newest one becomes latest. Locked versions can't be deleted.
+func (s *Service) DeleteObjectVersion(ctx context.Context, bucket, key string, versionID uuid.UUID) error {
+	var unreferenced []model.Part
+
+	err := s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		uploadRepo := s.uploadRepo.WithTx(tx)
+
+		if err := uploadRepo.LockKey(ctx, bucket, key); err != nil {
+			return fmt.Errorf("unable to lock object: %w", err)
+		}
+
+		upload, err := s.getVersion(ctx, uploadRepo, bucket, key, versionID)
+
+		if errors.Is(err, repo.ErrNotFound) {
+			return ErrObjectNotFound
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to get object version: %w", err)
+		}
+
+		if upload.IsLocked(time.Now()) {
+			return ErrObjectLocked
+		}
+
+		parts, err := s.partRepo.WithTx(tx).GetParts(ctx, upload.ID)
+
+		if err != nil {
+			return fmt.Errorf("unable to get parts: %w", err)
+		}
+
+		unreferenced, err = s.releaseParts(ctx, tx, parts)
+
+		if err != nil {
+			return err
+		}
+
+		// Due to cascade deletes in parts table, parts will be deleted too
+		if err := uploadRepo.DeleteUploadByIDs(ctx, []uuid.UUID{upload.ID}); err != nil {
+			return fmt.Errorf("unable to delete upload: %w", err)
+		}
+
+		if !upload.IsLatest {
+			return nil
+		}
+
+		newest, err := uploadRepo.GetNewestUploadVersion(ctx, bucket, key)
+
+		if errors.Is(err, repo.ErrNotFound) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to get newest version: %w", err)
+		}
+
+		if err := uploadRepo.MarkUploadAsLatest(ctx, newest.ID); err != nil {
+			return fmt.Errorf("unable to mark upload as latest: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to delete object version: %w", err)
+	}
+
+	// Parts are cleaned only after the version is gone, so a lock can't be raced
+	_, err = s.cleanParts(ctx, unreferenced)
+
+	return err
+}
+
+// commitVersion marks the upload as done and makes it the latest version of its object.
+func (s *Service) commitVersion(ctx context.Context, upload model.Upload) error {
+	err := s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		uploadRepo := s.uploadRepo.WithTx(tx)
+
+		if err := uploadRepo.LockKey(ctx, upload.Bucket, upload.Name); err != nil {
+			return fmt.Errorf("unable to lock object: %w", err)
+		}
+
+		if err := checkNotLocked(ctx, uploadRepo, upload.Bucket, upload.Name); err != nil {
+			return err
+		}
+
+		if err := uploadRepo.UnsetLatest(ctx, upload.Bucket, upload.Name); err != nil {
+			return fmt.Errorf("unable to unset latest version: %w", err)
+		}
+
+		if err := uploadRepo.MarkUploadAsCommitted(ctx, upload.ID, s.recovery.InstanceID); err != nil {
+			return fmt.Errorf("unable to mark upload as committed: %w", err)
+		}
+
+		if err := uploadRepo.MarkUploadAsLatest(ctx, upload.ID); err != nil {
+			return fmt.Errorf("unable to mark upload as latest: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to commit version: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) getVersion(
+	ctx context.Context, uploadRepo *repo.UploadRepo, bucket, key string, versionID uuid.UUID,
+) (model.Upload, error) {
+	upload, err := uploadRepo.GetUpload(ctx, versionID)
+
+	if err != nil {
+		return model.Upload{}, fmt.Errorf("unable to get upload: %w", err)
+	}
+
+	if upload.Bucket != bucket || upload.Name != key || upload.Status != model.UploadStatusCommitted {
+		return model.Upload{}, repo.ErrNotFound
+	}
+
+	return upload, nil
+}
+
+// checkNotLocked fails with ErrObjectLocked if the latest version of the object can't be overwritten.
+func checkNotLocked(ctx context.Context, uploadRepo *repo.UploadRepo, bucket, key string) error {
+	latest, err := uploadRepo.GetLatestUpload(ctx, bucket, key)
+
+	if errors.Is(err, repo.ErrNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to get latest version: %w", err)
+	}
+
+	if latest.IsLocked(time.Now()) {
+		return ErrObjectLocked
+	}
+
+	return nil
+}
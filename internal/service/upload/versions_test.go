@@ -0,0 +1,153 @@
+package upload
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/quolpr/distributeds3/internal/service/storage/repo/inmemstorage"
+)
+
+func TestCommitVersionSwitchesLatest(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+	ctx := context.Background()
+
+	first := env.upload(t, "key", "first version of the object", UploadOptions{})
+	second := env.upload(t, "key", "second version of the object", UploadOptions{})
+
+	latest, err := env.svc.GetObjectVersion(ctx, testBucket, "key", uuid.Nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latest.ID != second.ID {
+		t.Fatalf("expected latest version %s, got %s", second.ID, latest.ID)
+	}
+
+	if got := env.read(t, latest.ID); got != "second version of the object" {
+		t.Errorf("unexpected data of the latest version %q", got)
+	}
+
+	versions, err := env.svc.ListObjectVersions(ctx, testBucket, "key")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(versions) != 2 {
+		t.Fatalf("expected 2 versions, got %d", len(versions))
+	}
+
+	for _, version := range versions {
+		if version.IsLatest != (version.ID == second.ID) {
+			t.Errorf("version %s has is_latest %t", version.ID, version.IsLatest)
+		}
+	}
+
+	if n := env.count(t, "select count(*) from uploads where name = 'key' and is_latest"); n != 1 {
+		t.Errorf("expected 1 latest version, got %d", n)
+	}
+
+	if got := env.read(t, first.ID); got != "first version of the object" {
+		t.Errorf("unexpected data of the previous version %q", got)
+	}
+}
+
+func TestDeleteMarkerHidesObject(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+	ctx := context.Background()
+
+	version := env.upload(t, "key", "data of the object", UploadOptions{})
+
+	marker, err := env.svc.DeleteObject(ctx, testBucket, "key")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !marker.IsDeleteMarker || !marker.IsLatest {
+		t.Errorf("expected latest delete marker, got %+v", marker)
+	}
+
+	if _, err := env.svc.GetObjectVersion(ctx, testBucket, "key", uuid.Nil); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected deleted object to be not found, got %v", err)
+	}
+
+	objects, err := env.svc.ListObjects(ctx, testBucket, "", "", 10)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) != 0 {
+		t.Errorf("expected no listed objects, got %d", len(objects))
+	}
+
+	// The previous version is still readable by its ID
+	if _, err := env.svc.GetObjectVersion(ctx, testBucket, "key", version.ID); err != nil {
+		t.Errorf("expected hidden version to be readable, got %v", err)
+	}
+
+	// Deleting the object again doesn't stack markers
+	if _, err := env.svc.DeleteObject(ctx, testBucket, "key"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected deleted object to be not found, got %v", err)
+	}
+}
+
+func TestDeleteLatestVersionPromotesPrevious(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+	ctx := context.Background()
+
+	first := env.upload(t, "key", "first version of the object", UploadOptions{})
+	second := env.upload(t, "key", "second version of the object", UploadOptions{})
+
+	if err := env.svc.DeleteObjectVersion(ctx, testBucket, "key", second.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := env.svc.GetObjectVersion(ctx, testBucket, "key", uuid.Nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latest.ID != first.ID || !latest.IsLatest {
+		t.Fatalf("expected %s to become latest, got %+v", first.ID, latest)
+	}
+
+	if _, err := env.svc.GetObjectVersion(ctx, testBucket, "key", second.ID); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected deleted version to be not found, got %v", err)
+	}
+
+	if err := env.svc.DeleteObjectVersion(ctx, testBucket, "key", first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := env.svc.GetObjectVersion(ctx, testBucket, "key", uuid.Nil); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("expected object without versions to be not found, got %v", err)
+	}
+}
+
+func TestDeleteNoncurrentVersionKeepsLatest(t *testing.T) {
+	env := newTestEnv(t, inmemstorage.NewInmemRepo([]string{testServer}), nil)
+	ctx := context.Background()
+
+	first := env.upload(t, "key", "first version of the object", UploadOptions{})
+	second := env.upload(t, "key", "second version of the object", UploadOptions{})
+
+	if err := env.svc.DeleteObjectVersion(ctx, testBucket, "key", first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := env.svc.GetObjectVersion(ctx, testBucket, "key", uuid.Nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if latest.ID != second.ID {
+		t.Errorf("expected latest version %s, got %s", second.ID, latest.ID)
+	}
+}
@@ -0,0 +1,280 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/quolpr/distributeds3/internal/service/upload/model"
+	"github.com/quolpr/distributeds3/internal/service/upload/repo"
+)
+
+// PackingConfig configures packing of small objects into volumes.
+type PackingConfig struct {
+	// Threshold is the size below which objects are packed, packing is disabled if it is zero
+	Threshold int64
+	// VolumeMaxSize is the size after which no needles are appended to a volume
+	VolumeMaxSize int64
+	// CompactionRatio is the share of deleted needles after which a volume is compacted
+	CompactionRatio float64
+	// DeleteDelay is the time a compacted or moved volume is kept for reads that started before the move
+	DeleteDelay time.Duration
+}
+
+// uploadPackedPart stores the whole small upload as one needle appended to a volume.
+// Packed parts are not deduplicated.
+func (s *Service) uploadPackedPart(
+	ctx context.Context, part model.Part, key dataKey, reader io.Reader,
+) error {
+	if err := s.startPart(ctx, part); err != nil {
+		return err
+	}
+
+	var needle bytes.Buffer
+
+	compressedSize, err := encodePart(part, key, reader, func(encoded io.Reader) error {
+		_, err := needle.ReadFrom(encoded)
+
+		return err //nolint:wrapcheck
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to encode part: %w", err)
+	}
+
+	part.CompressedSize = compressedSize
+	part.VolumeLength = int64(needle.Len())
+
+	err = s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		volume, err := s.lockWritableVolume(ctx, tx, part.ServerURL, part.VolumeLength)
+
+		if err != nil {
+			return err
+		}
+
+		// Appends are serialized by the volume lock. The offset is taken from the storage server,
+		// a needle of a failed transaction is left as garbage at the end of the volume
+		offset, err := s.storageService.AppendToVolume(ctx, volume.ID, volume.ServerURL, &needle)
+
+		if err != nil {
+			return fmt.Errorf("unable to append to volume: %w", err)
+		}
+
+		err = s.volumeRepo.WithTx(tx).AddNeedle(ctx, volume.ID, offset+part.VolumeLength, part.VolumeLength)
+
+		if err != nil {
+			return fmt.Errorf("unable to add needle: %w", err)
+		}
+
+		part.VolumeID = volume.ID
+		part.VolumeOffset = offset
+
+		return s.partRepo.WithTx(tx).MarkPartAsPacked(ctx, part) //nolint:wrapcheck
+	})
+
+	if err != nil {
+		return fmt.Errorf("unable to pack part: %w", err)
+	}
+
+	return nil
+}
+
+// lockWritableVolume locks a volume of the server with room for the needle, creating a new one if there is none.
+func (s *Service) lockWritableVolume(
+	ctx context.Context, tx pgx.Tx, serverURL string, length int64,
+) (model.Volume, error) {
+	volumeRepo := s.volumeRepo.WithTx(tx)
+
+	volume, err := volumeRepo.GetWritableVolume(ctx, serverURL, length, s.packing.VolumeMaxSize)
+
+	if err == nil {
+		return volume, nil
+	}
+
+	if !errors.Is(err, repo.ErrNotFound) {
+		return model.Volume{}, fmt.Errorf("unable to get writable volume: %w", err)
+	}
+
+	volume = model.Volume{
+		ID:        uuid.New(),
+		ServerURL: serverURL,
+		Size:      0,
+		LiveSize:  0,
+		Sealed:    false,
+		CreatedAt: time.Now(),
+	}
+
+	if err := volumeRepo.Create(ctx, volume); err != nil {
+		return model.Volume{}, fmt.Errorf("unable to create volume: %w", err)
+	}
+
+	// The volume is not visible to others till the commit, so it is locked by the insert
+	return volume, nil
+}
+
+// CompactVolumes rewrites volumes with too many deleted needles, moving live needles into new volumes.
+// It returns the number of compacted volumes.
+func (s *Service) CompactVolumes(ctx context.Context) (int, error) {
+	volumes, err := s.volumeRepo.GetVolumesToCompact(ctx, s.packing.CompactionRatio)
+
+	if err != nil {
+		return 0, fmt.Errorf("unable to get volumes to compact: %w", err)
+	}
+
+	for i, volume := range volumes {
+		slog.Info("Compacting volume", "volume", volume.ID, "garbage_ratio", volume.GarbageRatio())
+
+		if err := s.compactVolume(ctx, volume); err != nil {
+			return i, fmt.Errorf("unable to compact volume %s: %w", volume.ID, err)
+		}
+	}
+
+	return len(volumes), nil
+}
+
+func (s *Service) compactVolume(ctx context.Context, volume model.Volume) error {
+	return s.relocateVolume(ctx, volume, volume.ServerURL)
+}
+
+// relocateVolume copies live needles of the volume into a new volume on the server. The volume is queued
+// for deletion after the delete delay, it is removed by the dangle uploads cleanup.
+func (s *Service) relocateVolume(ctx context.Context, volume model.Volume, serverURL string) error {
+	// Sealed volume gets no new needles, so all its parts are known before copying
+	if err := s.volumeRepo.Seal(ctx, volume.ID); err != nil {
+		return fmt.Errorf("unable to seal volume: %w", err)
+	}
+
+	parts, err := s.partRepo.GetVolumeParts(ctx, volume.ID)
+
+	if err != nil {
+		return fmt.Errorf("unable to get volume parts: %w", err)
+	}
+
+	target := model.Volume{
+		ID:        uuid.New(),
+		ServerURL: serverURL,
+		Size:      0,
+		LiveSize:  0,
+		Sealed:    false,
+		CreatedAt: time.Now(),
+	}
+	offsets := make(map[uuid.UUID]int64, len(parts))
+
+	for _, part := range parts {
+		var needle bytes.Buffer
+
+		err := s.storageService.ReadVolume(ctx, volume.ID, volume.ServerURL, part.VolumeOffset, part.VolumeLength, &needle)
+
+		if err != nil {
+			return s.dropTarget(ctx, target, fmt.Errorf("unable to read needle: %w", err))
+		}
+
+		offset, err := s.storageService.AppendToVolume(ctx, target.ID, target.ServerURL, &needle)
+
+		if err != nil {
+			return s.dropTarget(ctx, target, fmt.Errorf("unable to append needle: %w", err))
+		}
+
+		offsets[part.ID] = offset
+		target.Size = offset + part.VolumeLength
+	}
+
+	err = s.transaction.Exec(ctx, func(ctx context.Context, tx pgx.Tx) error {
+		return s.moveVolumeParts(ctx, tx, volume, target, offsets)
+	})
+
+	if err != nil {
+		return s.dropTarget(ctx, target, err)
+	}
+
+	return nil
+}
+
+// dropTarget deletes the copied needles of a failed relocation and returns err. The GC removes them
+// if deleting fails.
+func (s *Service) dropTarget(ctx context.Context, target model.Volume, err error) error {
+	if target.Size == 0 {
+		return err
+	}
+
+	if deleteErr := s.storageService.DeleteVolume(ctx, target.ID, target.ServerURL); deleteErr != nil {
+		slog.Warn("Unable to delete volume copy", "volume", target.ID, "server", target.ServerURL, "error", deleteErr)
+	}
+
+	return err
+}
+
+// moveVolumeParts points parts of the volume to their copies in the target volume, deletes the volume
+// and queues the cleanup of its data. Parts deleted while copying are left as garbage in the target,
+// if all of them are deleted, the target is queued for cleanup too.
+func (s *Service) moveVolumeParts(
+	ctx context.Context, tx pgx.Tx, volume, target model.Volume, offsets map[uuid.UUID]int64,
+) error {
+	volumeRepo := s.volumeRepo.WithTx(tx)
+	partRepo := s.partRepo.WithTx(tx)
+	cleanupRepo := s.cleanupRepo.WithTx(tx)
+
+	if _, err := volumeRepo.LockVolume(ctx, volume.ID); err != nil {
+		return fmt.Errorf("unable to lock volume: %w", err)
+	}
+
+	parts, err := partRepo.GetVolumeParts(ctx, volume.ID)
+
+	if err != nil {
+		return fmt.Errorf("unable to get volume parts: %w", err)
+	}
+
+	for _, part := range parts {
+		target.LiveSize += part.VolumeLength
+	}
+
+	if len(parts) > 0 {
+		if err := volumeRepo.Create(ctx, target); err != nil {
+			return fmt.Errorf("unable to create volume: %w", err)
+		}
+	} else if target.Size > 0 {
+		// Nothing references the copied needles, nobody reads them either
+		if err := cleanupRepo.EnqueueVolume(ctx, newVolumeCleanup(target, time.Now())); err != nil {
+			return fmt.Errorf("unable to queue volume copy cleanup: %w", err)
+		}
+	}
+
+	for _, part := range parts {
+		part.ServerURL = target.ServerURL
+		part.VolumeID = target.ID
+		part.VolumeOffset = offsets[part.ID]
+
+		if err := partRepo.MovePartToVolume(ctx, part); err != nil {
+			return fmt.Errorf("unable to move part: %w", err)
+		}
+	}
+
+	if err := volumeRepo.Delete(ctx, volume.ID); err != nil {
+		return fmt.Errorf("unable to delete volume: %w", err)
+	}
+
+	if err := cleanupRepo.EnqueueVolume(ctx, newVolumeCleanup(volume, time.Now().Add(s.packing.DeleteDelay))); err != nil {
+		return fmt.Errorf("unable to queue volume cleanup: %w", err)
+	}
+
+	return nil
+}
+
+func newVolumeCleanup(volume model.Volume, deleteAfter time.Time) model.VolumeCleanup {
+	return model.VolumeCleanup{
+		VolumeID:    volume.ID,
+		ServerURL:   volume.ServerURL,
+		Size:        volume.Size,
+		DeleteAfter: deleteAfter,
+		Attempts:    0,
+		LastError:   "",
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+}
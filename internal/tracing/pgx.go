@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer starts a client span for every query. Spans of sqlc queries are named by the query name.
+type QueryTracer struct{}
+
+func (QueryTracer) TraceQueryStart(
+	ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryStartData,
+) context.Context {
+	name := queryName(data.SQL)
+
+	ctx, _ = otel.Tracer(tracerName).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			semconv.DBQueryText(data.SQL),
+		),
+	)
+
+	return ctx
+}
+
+func (QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+}
+
+// queryName returns the name from the sqlc header "-- name: GetUpload :one" or a generic name.
+func queryName(sql string) string {
+	header, _, _ := strings.Cut(strings.TrimSpace(sql), "\n")
+
+	if name, ok := strings.CutPrefix(header, "-- name: "); ok {
+		name, _, _ = strings.Cut(name, " ")
+
+		return "pg." + name
+	}
+
+	return "pg.query"
+}
@@ -0,0 +1,83 @@
+// Package chunker splits a stream into chunks of fixed size or by content (FastCDC).
+package chunker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+type Algorithm string
+
+const (
+	// AlgorithmFixed splits a stream into chunks of AvgSize.
+	AlgorithmFixed Algorithm = "fixed"
+	// AlgorithmFastCDC cuts a stream where its content matches, so an insertion in a stream
+	// changes only the chunks around it.
+	AlgorithmFastCDC Algorithm = "fastcdc"
+)
+
+var ErrInvalidConfig = errors.New("invalid chunker config")
+
+type Config struct {
+	Algorithm Algorithm
+	// MinSize and MaxSize bound the chunk size of content-defined chunking
+	MinSize int
+	AvgSize int
+	MaxSize int
+}
+
+func (c Config) Validate() error {
+	switch c.Algorithm {
+	case AlgorithmFixed:
+		if c.AvgSize <= 0 {
+			return fmt.Errorf("%w: chunk size must be positive", ErrInvalidConfig)
+		}
+	case AlgorithmFastCDC:
+		if c.MinSize <= 0 || c.MinSize > c.AvgSize || c.AvgSize > c.MaxSize {
+			return fmt.Errorf("%w: sizes must satisfy 0 < min <= avg <= max", ErrInvalidConfig)
+		}
+	default:
+		return fmt.Errorf("%w: unknown algorithm %s", ErrInvalidConfig, c.Algorithm)
+	}
+
+	return nil
+}
+
+// Chunker returns chunks of a stream one by one.
+type Chunker interface {
+	// Next returns the next chunk or io.EOF if the stream is over.
+	// The chunk is valid only until the next call.
+	Next() ([]byte, error)
+}
+
+func New(r io.Reader, cfg Config) (Chunker, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	if cfg.Algorithm == AlgorithmFixed {
+		return &fixed{reader: r, buf: make([]byte, cfg.AvgSize)}, nil
+	}
+
+	return newFastCDC(r, cfg), nil
+}
+
+type fixed struct {
+	reader io.Reader
+	buf    []byte
+}
+
+func (c *fixed) Next() ([]byte, error) {
+	n, err := io.ReadFull(c.reader, c.buf)
+
+	if errors.Is(err, io.EOF) {
+		return nil, io.EOF
+	}
+
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err //nolint:wrapcheck
+	}
+
+	return c.buf[:n], nil
+}
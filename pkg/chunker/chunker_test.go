@@ -0,0 +1,150 @@
+package chunker
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+var fastCDCConfig = Config{Algorithm: AlgorithmFastCDC, MinSize: 2 * 1024, AvgSize: 8 * 1024, MaxSize: 32 * 1024}
+
+func TestChunksReassembleStream(t *testing.T) {
+	configs := []Config{
+		{Algorithm: AlgorithmFixed, MinSize: 0, AvgSize: 1000, MaxSize: 0},
+		fastCDCConfig,
+	}
+
+	for _, cfg := range configs {
+		for _, size := range []int{0, 1, 999, 1000, 1001, 300 * 1024} {
+			data := randomData(size, 1)
+			// One byte reads check that the chunker doesn't depend on how the stream is read
+			chunks := split(t, iotest.OneByteReader(bytes.NewReader(data)), cfg)
+
+			if got := bytes.Join(chunks, nil); !bytes.Equal(got, data) {
+				t.Errorf("%s, size %d: chunks don't reassemble the stream", cfg.Algorithm, size)
+			}
+		}
+	}
+}
+
+func TestFixedChunkSizes(t *testing.T) {
+	chunks := split(t, bytes.NewReader(randomData(2500, 1)), Config{
+		Algorithm: AlgorithmFixed, MinSize: 0, AvgSize: 1000, MaxSize: 0,
+	})
+
+	if len(chunks) != 3 || len(chunks[0]) != 1000 || len(chunks[1]) != 1000 || len(chunks[2]) != 500 {
+		t.Errorf("unexpected chunks of %d", chunkSizes(chunks))
+	}
+}
+
+func TestFastCDCChunkSizes(t *testing.T) {
+	chunks := split(t, bytes.NewReader(randomData(1024*1024, 2)), fastCDCConfig)
+
+	for i, chunk := range chunks {
+		last := i == len(chunks)-1
+
+		if len(chunk) > fastCDCConfig.MaxSize || (!last && len(chunk) < fastCDCConfig.MinSize) {
+			t.Fatalf("chunk %d is %d bytes, out of bounds", i, len(chunk))
+		}
+	}
+
+	avg := 1024 * 1024 / len(chunks)
+
+	if avg < fastCDCConfig.AvgSize/2 || avg > fastCDCConfig.AvgSize*2 {
+		t.Errorf("average chunk is %d bytes, expected about %d", avg, fastCDCConfig.AvgSize)
+	}
+}
+
+func TestFastCDCInsertionChangesFewChunks(t *testing.T) {
+	data := randomData(1024*1024, 3)
+	edited := bytes.Clone(data[:500*1024])
+	edited = append(edited, []byte("inserted bytes")...)
+	edited = append(edited, data[500*1024:]...)
+
+	before := split(t, bytes.NewReader(data), fastCDCConfig)
+	after := split(t, bytes.NewReader(edited), fastCDCConfig)
+
+	known := make(map[string]bool, len(before))
+	for _, chunk := range before {
+		known[string(chunk)] = true
+	}
+
+	changed := 0
+
+	for _, chunk := range after {
+		if !known[string(chunk)] {
+			changed++
+		}
+	}
+
+	if changed > 3 {
+		t.Errorf("insertion changed %d of %d chunks", changed, len(after))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "unknown algorithm", cfg: Config{Algorithm: "rabin", MinSize: 1, AvgSize: 2, MaxSize: 3}},
+		{name: "zero fixed size", cfg: Config{Algorithm: AlgorithmFixed, MinSize: 0, AvgSize: 0, MaxSize: 0}},
+		{name: "zero min size", cfg: Config{Algorithm: AlgorithmFastCDC, MinSize: 0, AvgSize: 2, MaxSize: 3}},
+		{name: "min above avg", cfg: Config{Algorithm: AlgorithmFastCDC, MinSize: 3, AvgSize: 2, MaxSize: 4}},
+		{name: "avg above max", cfg: Config{Algorithm: AlgorithmFastCDC, MinSize: 1, AvgSize: 5, MaxSize: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(bytes.NewReader(nil), tt.cfg); !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("expected ErrInvalidConfig, got %v", err)
+			}
+		})
+	}
+}
+
+// split returns copies of all chunks of the stream.
+func split(t *testing.T, r io.Reader, cfg Config) [][]byte {
+	t.Helper()
+
+	c, err := New(r, cfg)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks [][]byte
+
+	for {
+		chunk, err := c.Next()
+
+		if errors.Is(err, io.EOF) {
+			return chunks
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		chunks = append(chunks, bytes.Clone(chunk))
+	}
+}
+
+func chunkSizes(chunks [][]byte) []int {
+	sizes := make([]int, 0, len(chunks))
+	for _, chunk := range chunks {
+		sizes = append(sizes, len(chunk))
+	}
+
+	return sizes
+}
+
+func randomData(size int, seed int64) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data) //nolint:gosec
+
+	return data
+}
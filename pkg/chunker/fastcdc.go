@@ -0,0 +1,132 @@
+package chunker
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// gear maps bytes to random values for the rolling hash. It must never change:
+// chunk boundaries, and so deduplication of already stored data, depend on it.
+var gear = func() [256]uint64 {
+	var table [256]uint64
+
+	// splitmix64 with a fixed seed
+	state := uint64(0x5eed_c0de_f00d_cafe)
+
+	for i := range table {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+
+	return table
+}()
+
+// fastCDC implements FastCDC with normalized chunking: before AvgSize a cut needs more matching
+// bits of the hash, after it fewer, so chunk sizes concentrate around AvgSize.
+type fastCDC struct {
+	reader io.Reader
+	cfg    Config
+	// maskS and maskL are masks of the hash bits that must be zero before and after AvgSize
+	maskS uint64
+	maskL uint64
+
+	buf   []byte
+	start int
+	end   int
+	eof   bool
+}
+
+func newFastCDC(r io.Reader, cfg Config) *fastCDC {
+	avgBits := bits.Len(uint(cfg.AvgSize)) - 1
+
+	return &fastCDC{
+		reader: r,
+		cfg:    cfg,
+		maskS:  topBitsMask(avgBits + 1),
+		maskL:  topBitsMask(max(avgBits-1, 0)),
+		buf:    make([]byte, cfg.MaxSize),
+		start:  0,
+		end:    0,
+		eof:    false,
+	}
+}
+
+// topBitsMask uses the highest bits of the hash, they depend on the last 64 bytes.
+func topBitsMask(n int) uint64 {
+	if n == 0 {
+		return 0
+	}
+
+	return ^uint64(0) << (64 - n)
+}
+
+func (c *fastCDC) Next() ([]byte, error) {
+	if err := c.fill(); err != nil {
+		return nil, err
+	}
+
+	if c.start == c.end {
+		return nil, io.EOF
+	}
+
+	data := c.buf[c.start:c.end]
+	n := c.cut(data)
+	c.start += n
+
+	return data[:n], nil
+}
+
+// fill moves the not returned data to the beginning of the buffer and reads the stream till the buffer is full.
+func (c *fastCDC) fill() error {
+	c.end = copy(c.buf, c.buf[c.start:c.end])
+	c.start = 0
+
+	for !c.eof && c.end < len(c.buf) {
+		n, err := c.reader.Read(c.buf[c.end:])
+		c.end += n
+
+		if errors.Is(err, io.EOF) {
+			c.eof = true
+		} else if err != nil {
+			return err //nolint:wrapcheck
+		}
+	}
+
+	return nil
+}
+
+// cut returns the length of the chunk at the beginning of data.
+func (c *fastCDC) cut(data []byte) int {
+	if len(data) <= c.cfg.MinSize {
+		return len(data)
+	}
+
+	normal := min(c.cfg.AvgSize, len(data))
+	limit := min(c.cfg.MaxSize, len(data))
+
+	var hash uint64
+
+	i := c.cfg.MinSize
+
+	for ; i < normal; i++ {
+		hash = (hash << 1) + gear[data[i]]
+
+		if hash&c.maskS == 0 {
+			return i + 1
+		}
+	}
+
+	for ; i < limit; i++ {
+		hash = (hash << 1) + gear[data[i]]
+
+		if hash&c.maskL == 0 {
+			return i + 1
+		}
+	}
+
+	return limit
+}
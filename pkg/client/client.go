@@ -0,0 +1,260 @@
+// Package client is a Go client of the distributeds3 HTTP API.
+//
+// Requests are retried on network errors and 429, 502, 503 and 504 responses. Uploads are retried only
+// if the body is an io.Seeker, downloads that fail midway continue after the data already written.
+// Deletes are retried only if a version ID is passed.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetries    = 3
+	defaultBackoff    = 200 * time.Millisecond
+	defaultMaxBackoff = 5 * time.Second
+
+	requestIDHeader = "X-Request-Id"
+)
+
+var (
+	// ErrNotFound matches 404 responses: the object, the version or the upload doesn't exist.
+	ErrNotFound = errors.New("object not found")
+	// ErrChecksumMismatch is returned when the data doesn't match the ETag of the server.
+	ErrChecksumMismatch = errors.New("checksum doesn't match ETag of the server")
+	// ErrRangeIgnored is returned when the server sends the whole object instead of the requested range.
+	ErrRangeIgnored = errors.New("server ignored the requested range")
+)
+
+// Error is an error response of the server.
+type Error struct {
+	StatusCode int
+	// Code is the stable error code, e.g. object_locked. It is empty if the response has no JSON body
+	Code      string
+	Message   string
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	// HEAD responses have no body
+	if e.Code == "" && e.StatusCode == http.StatusNotFound {
+		return ErrNotFound.Error()
+	}
+
+	if e.Code == "" {
+		return fmt.Sprintf("server responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("%s: %s (request %s)", e.Code, e.Message, e.RequestID)
+}
+
+// Is makes errors.Is(err, ErrNotFound) match 404 responses.
+func (e *Error) Is(target error) bool {
+	return target == ErrNotFound && e.StatusCode == http.StatusNotFound //nolint:errorlint
+}
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id"`
+}
+
+// temporaryError is a failure to reach the server or to read its response, such calls are retried.
+type temporaryError struct {
+	err error
+}
+
+func (e *temporaryError) Error() string { return e.err.Error() }
+
+func (e *temporaryError) Unwrap() error { return e.err }
+
+// Config configures a Client, DefaultConfig is a good base.
+type Config struct {
+	// HTTPClient sends requests, http.DefaultClient is used if nil
+	HTTPClient *http.Client
+	// Retries is the number of extra attempts of failed calls, zero disables retries
+	Retries int
+	// Backoff is the delay before the first retry, it is doubled for every next one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		HTTPClient: nil,
+		Retries:    defaultRetries,
+		Backoff:    defaultBackoff,
+		MaxBackoff: defaultMaxBackoff,
+	}
+}
+
+// Client calls the API of the server. It is safe for concurrent use.
+type Client struct {
+	endpoint string
+	http     *http.Client
+	cfg      Config
+}
+
+// New returns a client of the server at endpoint, e.g. http://localhost:8080.
+func New(endpoint string, cfg Config) *Client {
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultBackoff
+	}
+
+	cfg.MaxBackoff = max(cfg.MaxBackoff, cfg.Backoff)
+
+	return &Client{
+		endpoint: strings.TrimRight(endpoint, "/"),
+		http:     httpClient,
+		cfg:      cfg,
+	}
+}
+
+// retry runs attempts of the call till one succeeds, fails permanently, retries are over or ctx is done.
+func (c *Client) retry(ctx context.Context, call func() error) error {
+	backoff := c.cfg.Backoff
+
+	for attempt := 0; ; attempt++ {
+		err := call()
+
+		if err == nil || attempt >= c.cfg.Retries || ctx.Err() != nil || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+
+			return fmt.Errorf("%w, last error: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+
+		backoff = min(backoff*2, c.cfg.MaxBackoff) //nolint:gomnd
+	}
+}
+
+// withBody runs attempts of a call sending the body, every attempt reads it from the current position.
+// Bodies that are not io.Seeker can be read once, so the call is not retried.
+func (c *Client) withBody(ctx context.Context, body io.Reader, call func() error) error {
+	seeker, ok := body.(io.Seeker)
+
+	if !ok {
+		return call()
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+
+	if err != nil {
+		return fmt.Errorf("unable to seek body: %w", err)
+	}
+
+	return c.retry(ctx, func() error {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return fmt.Errorf("unable to rewind body: %w", err)
+		}
+
+		return call()
+	})
+}
+
+func retryable(err error) bool {
+	var apiErr *Error
+
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		default:
+			return false
+		}
+	}
+
+	var tempErr *temporaryError
+
+	return errors.As(err, &tempErr)
+}
+
+// send makes a single attempt of the request and turns error responses into *Error.
+// Size is the length of the body, -1 if it is unknown.
+func (c *Client) send(
+	ctx context.Context, method, url string, header http.Header, body io.Reader, size int64,
+) (*http.Response, error) {
+	if body != nil && size == 0 {
+		body = http.NoBody
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	if body != nil {
+		req.ContentLength = size
+	}
+
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	res, err := c.http.Do(req)
+
+	if err != nil {
+		return nil, &temporaryError{err: fmt.Errorf("unable to send request: %w", err)}
+	}
+
+	if res.StatusCode < http.StatusBadRequest {
+		return res, nil
+	}
+
+	defer res.Body.Close()
+
+	apiErr := &Error{
+		StatusCode: res.StatusCode,
+		Code:       "",
+		Message:    "",
+		RequestID:  res.Header.Get(requestIDHeader),
+	}
+
+	var resp errorResponse
+
+	if err := json.NewDecoder(res.Body).Decode(&resp); err == nil {
+		apiErr.Code, apiErr.Message = resp.Code, resp.Error
+	}
+
+	return nil, apiErr
+}
+
+// sendJSON sends the request without a body and decodes the JSON response into out.
+func (c *Client) sendJSON(ctx context.Context, method, url string, out any) error {
+	return c.retry(ctx, func() error {
+		res, err := c.send(ctx, method, url, nil, nil, 0)
+
+		if err != nil {
+			return err
+		}
+
+		defer res.Body.Close()
+
+		if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+			return &temporaryError{err: fmt.Errorf("unable to decode response: %w", err)}
+		}
+
+		return nil
+	})
+}
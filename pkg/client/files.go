@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"fmt"
+	"io"
+	"os"
+)
+
+// UploadFile uploads the file as a new version of the object. The MD5 of the file is sent as ContentMD5
+// unless it is passed, so the server rejects the data if the file changes while it is uploaded.
+func (c *Client) UploadFile(ctx context.Context, bucket, key, path string, opts UploadOptions) (ObjectInfo, error) {
+	if opts.ContentMD5 == nil {
+		contentMD5, err := FileMD5(path)
+
+		if err != nil {
+			return ObjectInfo{}, err
+		}
+
+		opts.ContentMD5 = contentMD5
+	}
+
+	file, err := os.Open(path)
+
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("unable to open file: %w", err)
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("unable to stat file: %w", err)
+	}
+
+	return c.Upload(ctx, bucket, key, file, info.Size(), opts)
+}
+
+// DownloadFile writes the object or the range of it to the file, replacing the file.
+// If the download fails, the partial file can be completed by ResumeDownload.
+func (c *Client) DownloadFile(
+	ctx context.Context, bucket, key, path string, opts DownloadOptions,
+) (ObjectInfo, error) {
+	file, err := os.Create(path)
+
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("unable to create file: %w", err)
+	}
+
+	object, err := c.Download(ctx, bucket, key, file, opts)
+
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("unable to write file: %w", closeErr)
+	}
+
+	return object, err
+}
+
+// ResumeDownload appends the rest of the object to the partial file and checks the whole file against
+// the ETag. Without VersionID the latest version is downloaded, it must be the one the file was started from.
+// A missing file is downloaded from the start, Offset and Length are ignored.
+func (c *Client) ResumeDownload(
+	ctx context.Context, bucket, key, path string, opts DownloadOptions,
+) (ObjectInfo, error) {
+	// The version is pinned, so the file doesn't mix data of several versions
+	object, err := c.Stat(ctx, bucket, key, opts.VersionID)
+
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644) //nolint:gomnd
+
+	if err != nil {
+		return object, fmt.Errorf("unable to open file: %w", err)
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+
+		return object, fmt.Errorf("unable to stat file: %w", err)
+	}
+
+	if info.Size() > object.Size {
+		file.Close()
+
+		return object, fmt.Errorf("%s is larger than the object, it is not a partial download", path)
+	}
+
+	if info.Size() < object.Size {
+		opts.VersionID, opts.Offset, opts.Length = object.VersionID, info.Size(), 0
+		_, err = c.Download(ctx, bucket, key, file, opts)
+	}
+
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("unable to write file: %w", closeErr)
+	}
+
+	if err != nil {
+		return object, err
+	}
+
+	return object, verifyFile(path, object)
+}
+
+// FileMD5 returns the MD5 digest of the file, ETag of the digest is the one the server reports for the file.
+func FileMD5(path string) ([]byte, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to open file: %w", err)
+	}
+
+	defer file.Close()
+
+	digest := md5.New() //nolint:gosec
+
+	if _, err := io.Copy(digest, file); err != nil {
+		return nil, fmt.Errorf("unable to read file: %w", err)
+	}
+
+	return digest.Sum(nil), nil
+}
+
+// verifyFile checks the MD5 of the file against the ETag of the object, objects without ETag are not checked.
+func verifyFile(path string, object ObjectInfo) error {
+	if object.ETag == "" {
+		return nil
+	}
+
+	sum, err := FileMD5(path)
+
+	if err != nil {
+		return err
+	}
+
+	if ETag(sum) != object.ETag {
+		return fmt.Errorf("%w: %s has MD5 %s, ETag is %s", ErrChecksumMismatch, path, ETag(sum), object.ETag)
+	}
+
+	return nil
+}
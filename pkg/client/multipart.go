@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// UploadResult identifies an upload made by UploadMultipart.
+type UploadResult struct {
+	UploadID  string `json:"upload_id"`
+	VersionID string `json:"version_id"`
+}
+
+// UploadMultipart sends the body as a multipart form to /uploads, the object is stored in the default bucket
+// under the name. The form is streamed, the body is not buffered in memory. ContentMD5 is not checked by
+// the endpoint, so it is not sent.
+func (c *Client) UploadMultipart(
+	ctx context.Context, name string, body io.Reader, size int64, opts UploadOptions,
+) (UploadResult, error) {
+	var result UploadResult
+
+	err := c.withBody(ctx, body, func() error {
+		reader, writer := io.Pipe()
+		form := multipart.NewWriter(writer)
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+
+			writer.CloseWithError(writeForm(form, name, newProgressReader(body, opts.Progress), size))
+		}()
+
+		header := uploadHeader(opts)
+		header.Set("Content-Type", form.FormDataContentType())
+
+		res, err := c.send(ctx, http.MethodPost, c.endpoint+"/uploads", header, reader, -1)
+
+		// The form writer must be done with the body before it is rewound for a retry
+		reader.Close()
+		<-done
+
+		if err != nil {
+			return err
+		}
+
+		defer res.Body.Close()
+
+		if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+			return &temporaryError{err: fmt.Errorf("unable to decode response: %w", err)}
+		}
+
+		return nil
+	})
+
+	return result, err
+}
+
+// DownloadUpload writes the upload made by UploadMultipart to the writer. VersionID of opts is ignored,
+// the upload ID is the version.
+func (c *Client) DownloadUpload(
+	ctx context.Context, uploadID string, writer io.Writer, opts DownloadOptions,
+) (ObjectInfo, error) {
+	return c.download(ctx, "", writer, opts, func(string) string {
+		return c.endpoint + "/uploads/" + url.PathEscape(uploadID)
+	})
+}
+
+// writeForm writes the fields in the order the server reads them: file_size, then file.
+func writeForm(form *multipart.Writer, name string, body io.Reader, size int64) error {
+	if err := form.WriteField("file_size", strconv.FormatInt(size, 10)); err != nil {
+		return fmt.Errorf("unable to write file_size: %w", err)
+	}
+
+	part, err := form.CreateFormFile("file", name)
+
+	if err != nil {
+		return fmt.Errorf("unable to create file field: %w", err)
+	}
+
+	if _, err := io.Copy(part, io.LimitReader(body, size)); err != nil {
+		return fmt.Errorf("unable to write file: %w", err)
+	}
+
+	return form.Close() //nolint:wrapcheck
+}
@@ -0,0 +1,407 @@
+package client
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/quolpr/distributeds3/pkg/compression"
+)
+
+const (
+	versionIDHeader         = "X-Version-Id"
+	codecHeader             = "X-Compression-Codec"
+	customerAlgorithmHeader = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	customerKeyHeader       = "X-Amz-Server-Side-Encryption-Customer-Key"
+	customerKeyMD5Header    = "X-Amz-Server-Side-Encryption-Customer-Key-MD5"
+)
+
+// ObjectInfo describes a version of an object.
+type ObjectInfo struct {
+	Key       string `json:"key"`
+	VersionID string `json:"version_id"`
+	Size      int64  `json:"size"`
+	// ETag is the quoted hex MD5 of the data, it is empty for objects written before the server recorded it
+	ETag         string    `json:"etag"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Version is an entry of the version history of an object.
+type Version struct {
+	VersionID      string     `json:"version_id"`
+	Size           int64      `json:"size"`
+	IsLatest       bool       `json:"is_latest"`
+	IsDeleteMarker bool       `json:"is_delete_marker"`
+	RetainUntil    *time.Time `json:"retain_until,omitempty"`
+	LegalHold      bool       `json:"legal_hold"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type UploadOptions struct {
+	// ContentMD5 is the MD5 digest of the body, the server rejects data that doesn't match it
+	ContentMD5 []byte
+	// CustomerKey encrypts the object with the customer-provided key (SSE-C), it is required to read it back
+	CustomerKey []byte
+	// Codec overrides the compression codec of the server
+	Codec compression.Codec
+	// Progress is called with the number of bytes of the body sent so far, it starts over on retries
+	Progress func(sent int64)
+}
+
+type DownloadOptions struct {
+	// VersionID selects the version instead of the latest one
+	VersionID string
+	// Offset and Length select a byte range, zero Length reads till the end of the object
+	Offset int64
+	Length int64
+	// CustomerKey decrypts objects uploaded with SSE-C
+	CustomerKey []byte
+	// Progress is called with the number of bytes written to the writer so far
+	Progress func(written int64)
+}
+
+type ListOptions struct {
+	// Prefix limits the objects to keys starting with it
+	Prefix string
+	// After is the key to start after, NextAfter of the previous page
+	After string
+	// MaxKeys is the size of the page, the server default if zero
+	MaxKeys int
+}
+
+type ListPage struct {
+	Objects []ObjectInfo `json:"objects"`
+	// NextAfter is passed as After to get the next page, it is empty on the last page
+	NextAfter string `json:"next_after"`
+}
+
+// Upload stores the body as a new version of the object. Size is the exact length of the body.
+func (c *Client) Upload(
+	ctx context.Context, bucket, key string, body io.Reader, size int64, opts UploadOptions,
+) (ObjectInfo, error) {
+	header := uploadHeader(opts)
+
+	if opts.ContentMD5 != nil {
+		header.Set("Content-MD5", base64.StdEncoding.EncodeToString(opts.ContentMD5))
+	}
+
+	var object ObjectInfo
+
+	err := c.withBody(ctx, body, func() error {
+		res, err := c.send(
+			ctx, http.MethodPut, c.objectURL(bucket, key, ""), header, newProgressReader(body, opts.Progress), size,
+		)
+
+		if err != nil {
+			return err
+		}
+
+		defer res.Body.Close()
+
+		object = objectFromHeaders(key, res.Header, size)
+
+		return nil
+	})
+
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	if opts.ContentMD5 != nil && object.ETag != "" && object.ETag != ETag(opts.ContentMD5) {
+		return object, fmt.Errorf("%w: %s, expected %s", ErrChecksumMismatch, object.ETag, ETag(opts.ContentMD5))
+	}
+
+	return object, nil
+}
+
+// Download writes the object to the writer. Retries continue after the data already written and are
+// pinned to the version of the first response. The whole object is checked against its ETag.
+func (c *Client) Download(
+	ctx context.Context, bucket, key string, writer io.Writer, opts DownloadOptions,
+) (ObjectInfo, error) {
+	return c.download(ctx, key, writer, opts, func(versionID string) string {
+		return c.objectURL(bucket, key, versionID)
+	})
+}
+
+// Stat returns the latest version of the object or the one with versionID.
+func (c *Client) Stat(ctx context.Context, bucket, key, versionID string) (ObjectInfo, error) {
+	var object ObjectInfo
+
+	err := c.retry(ctx, func() error {
+		res, err := c.send(ctx, http.MethodHead, c.objectURL(bucket, key, versionID), nil, nil, 0)
+
+		if err != nil {
+			return err
+		}
+
+		defer res.Body.Close()
+
+		object = objectFromHeaders(key, res.Header, res.ContentLength)
+
+		return nil
+	})
+
+	return object, err
+}
+
+// Delete puts a delete marker on the object or, if versionID is passed, removes the version permanently.
+// It returns the ID of the delete marker or of the removed version.
+//
+// Only deletes of a version are retried: a retry of a delete without version ID would put one more
+// delete marker if the first attempt reached the server. A version missing on a retry is taken as removed
+// by the attempt that failed.
+func (c *Client) Delete(ctx context.Context, bucket, key, versionID string) (string, error) {
+	var deleted string
+
+	deleteOnce := func() error {
+		res, err := c.send(ctx, http.MethodDelete, c.objectURL(bucket, key, versionID), nil, nil, 0)
+
+		if err != nil {
+			return err
+		}
+
+		deleted = res.Header.Get(versionIDHeader)
+
+		return res.Body.Close() //nolint:wrapcheck
+	}
+
+	if versionID == "" {
+		return deleted, deleteOnce()
+	}
+
+	retried := false
+
+	err := c.retry(ctx, func() error {
+		err := deleteOnce()
+
+		if retried && errors.Is(err, ErrNotFound) {
+			deleted = versionID
+
+			return nil
+		}
+
+		retried = true
+
+		return err
+	})
+
+	return deleted, err
+}
+
+// List returns a page of latest versions of objects of the bucket ordered by key.
+func (c *Client) List(ctx context.Context, bucket string, opts ListOptions) (ListPage, error) {
+	query := url.Values{}
+	query.Set("prefix", opts.Prefix)
+	query.Set("after", opts.After)
+
+	if opts.MaxKeys > 0 {
+		query.Set("max-keys", strconv.Itoa(opts.MaxKeys))
+	}
+
+	var page ListPage
+
+	err := c.sendJSON(ctx, http.MethodGet, c.endpoint+"/objects/"+url.PathEscape(bucket)+"?"+query.Encode(), &page)
+
+	return page, err
+}
+
+// ListAll passes every latest version of objects with keys starting with prefix to fn, page by page.
+// An error of fn stops listing and is returned.
+func (c *Client) ListAll(ctx context.Context, bucket, prefix string, fn func(object ObjectInfo) error) error {
+	opts := ListOptions{Prefix: prefix, After: "", MaxKeys: 0}
+
+	for {
+		page, err := c.List(ctx, bucket, opts)
+
+		if err != nil {
+			return err
+		}
+
+		for _, object := range page.Objects {
+			if err := fn(object); err != nil {
+				return err
+			}
+		}
+
+		if page.NextAfter == "" {
+			return nil
+		}
+
+		opts.After = page.NextAfter
+	}
+}
+
+// ListVersions returns all versions of the object, including delete markers.
+func (c *Client) ListVersions(ctx context.Context, bucket, key string) ([]Version, error) {
+	var resp struct {
+		Versions []Version `json:"versions"`
+	}
+
+	err := c.sendJSON(ctx, http.MethodGet, c.endpoint+"/versions/"+url.PathEscape(bucket)+"/"+escapeKey(key), &resp)
+
+	return resp.Versions, err
+}
+
+// download writes the object served at the URL of the version to the writer. Empty versionID is the latest one.
+func (c *Client) download(
+	ctx context.Context, key string, writer io.Writer, opts DownloadOptions, urlOf func(versionID string) string,
+) (ObjectInfo, error) {
+	out := &progressWriter{writer: writer, written: 0, progress: opts.Progress}
+	object := ObjectInfo{Key: key, VersionID: opts.VersionID, Size: -1, ETag: "", LastModified: time.Time{}}
+
+	var digest hash.Hash
+
+	if opts.Offset == 0 && opts.Length == 0 {
+		digest = md5.New() //nolint:gosec
+		out.writer = io.MultiWriter(writer, digest)
+	}
+
+	err := c.retry(ctx, func() error {
+		offset := opts.Offset + out.written
+		header := customerKeyHeaders(opts.CustomerKey)
+
+		switch {
+		case opts.Length > 0:
+			header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, opts.Offset+opts.Length-1))
+		case offset > 0:
+			header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		}
+
+		res, err := c.send(ctx, http.MethodGet, urlOf(object.VersionID), header, nil, 0)
+
+		if err != nil {
+			return err
+		}
+
+		defer res.Body.Close()
+
+		if header.Get("Range") != "" && res.StatusCode != http.StatusPartialContent {
+			return ErrRangeIgnored
+		}
+
+		if out.written == 0 {
+			object = objectFromHeaders(key, res.Header, responseSize(res))
+		}
+
+		if _, err := io.Copy(out, &temporaryReader{reader: res.Body}); err != nil {
+			return fmt.Errorf("unable to download object: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return object, err
+	}
+
+	if object.Size < 0 {
+		object.Size = out.written
+	}
+
+	if digest != nil && object.ETag != "" && ETag(digest.Sum(nil)) != object.ETag {
+		return object, fmt.Errorf("%w: data has MD5 %s, ETag is %s", ErrChecksumMismatch, ETag(digest.Sum(nil)), object.ETag)
+	}
+
+	return object, nil
+}
+
+// temporaryReader marks failures of reading the response as temporary, so the download is retried.
+// Failures of the writer are not.
+type temporaryReader struct {
+	reader io.Reader
+}
+
+func (r *temporaryReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, &temporaryError{err: err}
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// responseSize returns the object size of a full or partial response, -1 if it is unknown,
+// e.g. gzip responses decompressed by the transport.
+func responseSize(res *http.Response) int64 {
+	if res.StatusCode == http.StatusPartialContent {
+		_, total, _ := strings.Cut(res.Header.Get("Content-Range"), "/")
+
+		if size, err := strconv.ParseInt(total, 10, 64); err == nil {
+			return size
+		}
+
+		return -1
+	}
+
+	return res.ContentLength
+}
+
+func (c *Client) objectURL(bucket, key, versionID string) string {
+	objectURL := c.endpoint + "/objects/" + url.PathEscape(bucket) + "/" + escapeKey(key)
+
+	if versionID != "" {
+		objectURL += "?versionId=" + url.QueryEscape(versionID)
+	}
+
+	return objectURL
+}
+
+// escapeKey escapes the key keeping slashes, keys are matched by the rest of the path.
+func escapeKey(key string) string {
+	return (&url.URL{Path: key}).EscapedPath() //nolint:exhaustruct
+}
+
+func objectFromHeaders(key string, header http.Header, size int64) ObjectInfo {
+	modified, _ := http.ParseTime(header.Get("Last-Modified"))
+
+	return ObjectInfo{
+		Key:          key,
+		VersionID:    header.Get(versionIDHeader),
+		Size:         size,
+		ETag:         header.Get("ETag"),
+		LastModified: modified,
+	}
+}
+
+func uploadHeader(opts UploadOptions) http.Header {
+	header := customerKeyHeaders(opts.CustomerKey)
+
+	if opts.Codec != "" {
+		header.Set(codecHeader, string(opts.Codec))
+	}
+
+	return header
+}
+
+func customerKeyHeaders(key []byte) http.Header {
+	header := http.Header{}
+
+	if key == nil {
+		return header
+	}
+
+	sum := md5.Sum(key) //nolint:gosec
+
+	header.Set(customerAlgorithmHeader, "AES256")
+	header.Set(customerKeyHeader, base64.StdEncoding.EncodeToString(key))
+	header.Set(customerKeyMD5Header, base64.StdEncoding.EncodeToString(sum[:]))
+
+	return header
+}
+
+// ETag returns the ETag the server reports for data with the MD5 digest.
+func ETag(contentMD5 []byte) string {
+	return `"` + hex.EncodeToString(contentMD5) + `"`
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errTransport = errors.New("connection reset")
+
+// roundTripFunc serves requests with the function, its calls are counted.
+type roundTripFunc struct {
+	fn    func(req *http.Request) (*http.Response, error)
+	calls int
+}
+
+func (f *roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.calls++
+
+	return f.fn(req)
+}
+
+func newTestClient(transport http.RoundTripper) *Client {
+	return New("http://ds3.test", Config{
+		HTTPClient: &http.Client{Transport: transport}, //nolint:exhaustruct
+		Retries:    3,
+		Backoff:    time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	})
+}
+
+func newResponse(req *http.Request, status int, header http.Header, body string) *http.Response {
+	return &http.Response{ //nolint:exhaustruct
+		StatusCode: status,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestDeleteWithoutVersionIsNotRetried(t *testing.T) {
+	transport := &roundTripFunc{fn: func(*http.Request) (*http.Response, error) {
+		return nil, errTransport
+	}}
+
+	_, err := newTestClient(transport).Delete(context.Background(), "bucket", "key", "")
+
+	if !errors.Is(err, errTransport) {
+		t.Fatalf("expected transport error, got %v", err)
+	}
+
+	if transport.calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", transport.calls)
+	}
+}
+
+func TestDeleteVersionRetriedAfterLostResponse(t *testing.T) {
+	const versionID = "0190b7b4-5d5e-7a3c-9a4e-2f6c8d1e0b3a"
+
+	transport := &roundTripFunc{}
+	transport.fn = func(req *http.Request) (*http.Response, error) {
+		if transport.calls == 1 {
+			// The server removed the version, but the response is lost
+			return nil, errTransport
+		}
+
+		header := http.Header{"Content-Type": {"application/json"}}
+
+		return newResponse(req, http.StatusNotFound, header, `{"error":"not found","code":"upload_not_found"}`), nil
+	}
+
+	deleted, err := newTestClient(transport).Delete(context.Background(), "bucket", "key", versionID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != versionID || transport.calls != 2 {
+		t.Errorf("expected %s after 2 attempts, got %q after %d", versionID, deleted, transport.calls)
+	}
+}
+
+func TestDeleteMissingVersion(t *testing.T) {
+	transport := &roundTripFunc{fn: func(req *http.Request) (*http.Response, error) {
+		return newResponse(req, http.StatusNotFound, http.Header{}, ""), nil
+	}}
+
+	_, err := newTestClient(transport).Delete(context.Background(), "bucket", "key", "missing")
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	if transport.calls != 1 {
+		t.Errorf("expected 1 attempt, got %d", transport.calls)
+	}
+}
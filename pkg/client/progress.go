@@ -0,0 +1,50 @@
+package client
+
+import "io"
+
+// progressReader reports the number of bytes read so far to the progress callback.
+type progressReader struct {
+	reader   io.Reader
+	read     int64
+	progress func(n int64)
+}
+
+// newProgressReader returns the reader itself if there is no callback, so the transport sees the original body.
+func newProgressReader(reader io.Reader, progress func(n int64)) io.Reader {
+	if progress == nil {
+		return reader
+	}
+
+	progress(0)
+
+	return &progressReader{reader: reader, read: 0, progress: progress}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+
+	if n > 0 {
+		r.read += int64(n)
+		r.progress(r.read)
+	}
+
+	return n, err //nolint:wrapcheck
+}
+
+// progressWriter counts bytes written and reports them to the progress callback if it is set.
+type progressWriter struct {
+	writer   io.Writer
+	written  int64
+	progress func(n int64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.written += int64(n)
+
+	if w.progress != nil && n > 0 {
+		w.progress(w.written)
+	}
+
+	return n, err //nolint:wrapcheck
+}
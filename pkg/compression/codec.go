@@ -0,0 +1,160 @@
+// Package compression provides streaming compression with pluggable codecs.
+package compression
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type Codec string
+
+const (
+	CodecNone Codec = "none"
+	CodecGzip Codec = "gzip"
+	CodecZstd Codec = "zstd"
+)
+
+var ErrUnknownCodec = errors.New("unknown compression codec")
+
+func ParseCodec(s string) (Codec, error) {
+	switch codec := Codec(s); codec {
+	case CodecNone, CodecGzip, CodecZstd:
+		return codec, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownCodec, s)
+	}
+}
+
+// NewCompressReader returns a reader of the compressed src. Close must be called
+// if the reader is not read till the end.
+func NewCompressReader(src io.Reader, codec Codec) (io.ReadCloser, error) {
+	if codec == CodecNone {
+		return io.NopCloser(src), nil
+	}
+
+	pr, pw := io.Pipe()
+
+	encoder, err := newEncoder(pw, codec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		_, err := io.Copy(encoder, src)
+
+		if closeErr := encoder.Close(); err == nil {
+			err = closeErr
+		}
+
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
+
+type decompressWriter struct {
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// NewDecompressWriter returns a writer that decompresses everything written to it into dst.
+// Close must be called to flush the data and check that the stream is complete.
+func NewDecompressWriter(dst io.Writer, codec Codec) (io.WriteCloser, error) {
+	if codec == CodecNone {
+		return nopWriteCloser{dst}, nil
+	}
+
+	if _, err := ParseCodec(string(codec)); err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	w := &decompressWriter{
+		pw:   pw,
+		done: make(chan error, 1),
+	}
+
+	go func() {
+		err := decode(dst, pr, codec)
+
+		// Unblocks writers if decoding stopped before the stream end
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+
+	return w, nil
+}
+
+func (w *decompressWriter) Write(p []byte) (int, error) {
+	return w.pw.Write(p) //nolint:wrapcheck
+}
+
+func (w *decompressWriter) Close() error {
+	if err := w.pw.Close(); err != nil {
+		return fmt.Errorf("failed to close pipe: %w", err)
+	}
+
+	return <-w.done
+}
+
+func decode(dst io.Writer, src io.Reader, codec Codec) error {
+	switch codec {
+	case CodecGzip:
+		decoder, err := gzip.NewReader(src)
+
+		if err != nil {
+			return fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+
+		if _, err := io.Copy(dst, decoder); err != nil { //nolint:gosec
+			return fmt.Errorf("failed to decompress gzip: %w", err)
+		}
+
+		return nil
+	case CodecZstd:
+		decoder, err := zstd.NewReader(src)
+
+		if err != nil {
+			return fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		defer decoder.Close()
+
+		if _, err := io.Copy(dst, decoder); err != nil {
+			return fmt.Errorf("failed to decompress zstd: %w", err)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownCodec, codec)
+	}
+}
+
+func newEncoder(dst io.Writer, codec Codec) (io.WriteCloser, error) {
+	switch codec {
+	case CodecGzip:
+		return gzip.NewWriter(dst), nil
+	case CodecZstd:
+		encoder, err := zstd.NewWriter(dst)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+
+		return encoder, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnknownCodec, codec)
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
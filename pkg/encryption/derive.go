@@ -0,0 +1,16 @@
+package encryption
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// DeriveKey derives a KeySize key from the secret for the label and the context,
+// so one secret can be used for different purposes and objects.
+func DeriveKey(secret []byte, label string, context []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(label))
+	mac.Write(context)
+
+	return mac.Sum(nil)
+}
@@ -0,0 +1,261 @@
+// Package encryption implements chunked AES-GCM streams.
+//
+// Plaintext is split into ChunkSize chunks, every chunk is sealed separately with
+// nonce = stream ID (4 bytes) + chunk index (8 bytes) and the "final chunk" flag as
+// additional data, so streams can be processed without buffering and truncation is detected.
+// Chunk boundaries are known upfront which allows to decrypt any range of the stream.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	KeySize   = 32
+	ChunkSize = 64 * 1024
+
+	nonceSize  = 12
+	tagSize    = 16
+	sealedSize = ChunkSize + tagSize
+)
+
+var ErrCorrupted = errors.New("encrypted stream is corrupted")
+
+// NewDataKey generates a random key suitable for the stream encryption.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	return key, nil
+}
+
+// EncryptedSize returns the size of the encrypted stream for the plaintext size.
+func EncryptedSize(size int64) int64 {
+	chunks := (size + ChunkSize - 1) / ChunkSize
+	if chunks == 0 {
+		chunks = 1
+	}
+
+	return size + chunks*tagSize
+}
+
+type encryptReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	nonce   [nonceSize]byte
+	counter uint64
+
+	cur, next []byte
+	curLen    int
+	srcEOF    bool
+	started   bool
+	done      bool
+	out       []byte
+}
+
+// NewEncryptReader encrypts src with the key. streamID must be unique for the key.
+func NewEncryptReader(src io.Reader, key []byte, streamID uint32) (io.Reader, error) {
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r := &encryptReader{ //nolint:exhaustruct
+		src:  src,
+		aead: aead,
+		cur:  make([]byte, ChunkSize),
+		next: make([]byte, ChunkSize),
+	}
+	binary.BigEndian.PutUint32(r.nonce[:4], streamID)
+
+	return r, nil
+}
+
+func (r *encryptReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if err := r.sealNext(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+
+	return n, nil
+}
+
+func (r *encryptReader) sealNext() error {
+	if !r.started {
+		r.started = true
+
+		n, err := r.fill(r.cur)
+		if err != nil {
+			return err
+		}
+
+		r.curLen = n
+	}
+
+	final := r.srcEOF
+	nextLen := 0
+
+	if !final {
+		n, err := r.fill(r.next)
+		if err != nil {
+			return err
+		}
+
+		nextLen = n
+		final = n == 0 && r.srcEOF
+	}
+
+	r.out = r.seal(r.cur[:r.curLen], final)
+
+	if final {
+		r.done = true
+
+		return nil
+	}
+
+	r.cur, r.next = r.next, r.cur
+	r.curLen = nextLen
+
+	return nil
+}
+
+func (r *encryptReader) fill(buf []byte) (int, error) {
+	n, err := io.ReadFull(r.src, buf)
+
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		r.srcEOF = true
+
+		return n, nil
+	}
+
+	if err != nil {
+		return n, fmt.Errorf("failed to read plaintext: %w", err)
+	}
+
+	return n, nil
+}
+
+func (r *encryptReader) seal(chunk []byte, final bool) []byte {
+	binary.BigEndian.PutUint64(r.nonce[4:], r.counter)
+	r.counter++
+
+	return r.aead.Seal(nil, r.nonce[:], chunk, additionalData(final))
+}
+
+// DecryptWriter decrypts the stream written to it. Close must be called to check
+// that the stream is complete and to flush the last chunk.
+type DecryptWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	nonce   [nonceSize]byte
+	counter uint64
+	buf     []byte
+	plain   []byte
+}
+
+func NewDecryptWriter(dst io.Writer, key []byte, streamID uint32) (*DecryptWriter, error) {
+	aead, err := newAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	w := &DecryptWriter{ //nolint:exhaustruct
+		dst:   dst,
+		aead:  aead,
+		buf:   make([]byte, 0, sealedSize*2),
+		plain: make([]byte, 0, ChunkSize),
+	}
+	binary.BigEndian.PutUint32(w.nonce[:4], streamID)
+
+	return w, nil
+}
+
+func (w *DecryptWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	offset := 0
+
+	// The chunk is not the final one only if something follows it
+	for len(w.buf)-offset > sealedSize {
+		if err := w.open(w.buf[offset:offset+sealedSize], false); err != nil {
+			return 0, err
+		}
+
+		offset += sealedSize
+	}
+
+	// The incomplete rest is moved to the start once per Write, not after every chunk
+	w.buf = append(w.buf[:0], w.buf[offset:]...)
+
+	return len(p), nil
+}
+
+func (w *DecryptWriter) Close() error {
+	if len(w.buf) < tagSize {
+		return fmt.Errorf("%w: stream is truncated", ErrCorrupted)
+	}
+
+	err := w.open(w.buf, true)
+	w.buf = w.buf[:0]
+
+	return err
+}
+
+func (w *DecryptWriter) open(sealed []byte, final bool) error {
+	binary.BigEndian.PutUint64(w.nonce[4:], w.counter)
+	w.counter++
+
+	plain, err := w.aead.Open(w.plain[:0], w.nonce[:], sealed, additionalData(final))
+
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrCorrupted, err)
+	}
+
+	if _, err := w.dst.Write(plain); err != nil {
+		return fmt.Errorf("failed to write plaintext: %w", err)
+	}
+
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gcm: %w", err)
+	}
+
+	return aead, nil
+}
+
+func additionalData(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+
+	return []byte{0}
+}
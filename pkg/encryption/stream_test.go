@@ -0,0 +1,167 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	key := newKey(t)
+
+	for _, size := range []int{0, 1, ChunkSize - 1, ChunkSize, ChunkSize + 1, 3*ChunkSize + 17} {
+		plain := randomBytes(t, size)
+		sealed := encrypt(t, plain, key, 7)
+
+		if int64(len(sealed)) != EncryptedSize(int64(size)) {
+			t.Errorf("size %d: encrypted %d bytes, EncryptedSize is %d", size, len(sealed), EncryptedSize(int64(size)))
+		}
+
+		got, err := decrypt(sealed, key, 7, len(sealed))
+
+		if err != nil {
+			t.Fatalf("size %d: decrypt: %v", size, err)
+		}
+
+		if !bytes.Equal(got, plain) {
+			t.Errorf("size %d: decrypted data differs", size)
+		}
+	}
+}
+
+func TestStreamWriteSizes(t *testing.T) {
+	key := newKey(t)
+	plain := randomBytes(t, 5*ChunkSize+123)
+	sealed := encrypt(t, plain, key, 1)
+
+	// Single write of the whole stream, as io.Copy from a bytes.Buffer does, and odd small writes
+	for _, writeSize := range []int{len(sealed), 1, 1000, sealedSize, sealedSize + 1} {
+		got, err := decrypt(sealed, key, 1, writeSize)
+
+		if err != nil {
+			t.Fatalf("write size %d: decrypt: %v", writeSize, err)
+		}
+
+		if !bytes.Equal(got, plain) {
+			t.Errorf("write size %d: decrypted data differs", writeSize)
+		}
+	}
+}
+
+func TestStreamDetectsTampering(t *testing.T) {
+	key := newKey(t)
+	plain := randomBytes(t, 2*ChunkSize+10)
+	sealed := encrypt(t, plain, key, 3)
+
+	tests := []struct {
+		name   string
+		sealed []byte
+		key    []byte
+		stream uint32
+	}{
+		{name: "flipped bit", sealed: flipBit(sealed, ChunkSize+5), key: key, stream: 3},
+		{name: "flipped tag", sealed: flipBit(sealed, len(sealed)-1), key: key, stream: 3},
+		{name: "truncated at chunk boundary", sealed: sealed[:2*sealedSize], key: key, stream: 3},
+		{name: "truncated mid chunk", sealed: sealed[:sealedSize+100], key: key, stream: 3},
+		{name: "too short", sealed: sealed[:tagSize-1], key: key, stream: 3},
+		{name: "reordered chunks", sealed: swapChunks(sealed), key: key, stream: 3},
+		{name: "other stream ID", sealed: sealed, key: key, stream: 4},
+		{name: "other key", sealed: sealed, key: newKey(t), stream: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decrypt(tt.sealed, tt.key, tt.stream, len(tt.sealed))
+
+			if !errors.Is(err, ErrCorrupted) {
+				t.Errorf("expected ErrCorrupted, got %v", err)
+			}
+		})
+	}
+}
+
+func encrypt(t *testing.T, plain, key []byte, streamID uint32) []byte {
+	t.Helper()
+
+	reader, err := NewEncryptReader(bytes.NewReader(plain), key, streamID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := io.ReadAll(reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sealed
+}
+
+// decrypt writes the sealed stream to a DecryptWriter by writeSize bytes at once.
+func decrypt(sealed, key []byte, streamID uint32, writeSize int) ([]byte, error) {
+	var out bytes.Buffer
+
+	writer, err := NewDecryptWriter(&out, key, streamID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for len(sealed) > 0 {
+		n := min(writeSize, len(sealed))
+
+		if _, err := writer.Write(sealed[:n]); err != nil {
+			return nil, err
+		}
+
+		sealed = sealed[n:]
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+func newKey(t *testing.T) []byte {
+	t.Helper()
+
+	key, err := NewDataKey()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+
+	data := make([]byte, n)
+
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func flipBit(data []byte, i int) []byte {
+	flipped := bytes.Clone(data)
+	flipped[i] ^= 1
+
+	return flipped
+}
+
+func swapChunks(data []byte) []byte {
+	swapped := bytes.Clone(data)
+	copy(swapped[:sealedSize], data[sealedSize:2*sealedSize])
+	copy(swapped[sealedSize:2*sealedSize], data[:sealedSize])
+
+	return swapped
+}
@@ -0,0 +1,43 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// TryLock runs f holding a session advisory lock of the key, so f runs in one process at a time.
+// If the lock is held by another session, f is not run and false is returned.
+func (t *Transaction) TryLock(ctx context.Context, key string, f func(ctx context.Context) error) (bool, error) {
+	// Session locks belong to a connection, so the same connection must release it
+	conn, err := t.db.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Release()
+
+	var locked bool
+
+	err = conn.QueryRow(ctx, "select pg_try_advisory_lock(hashtextextended($1, 0))", key).Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock: %w", err)
+	}
+
+	if !locked {
+		return false, nil
+	}
+
+	defer func() {
+		ctx := context.WithoutCancel(ctx)
+
+		_, err := conn.Exec(ctx, "select pg_advisory_unlock(hashtextextended($1, 0))", key)
+		if err != nil {
+			slog.Error("Failed to unlock, closing connection", "key", key, "err", err)
+
+			// Closed connection is not returned to the pool, its locks are released by the server
+			_ = conn.Conn().Close(ctx)
+		}
+	}()
+
+	return true, f(ctx)
+}
@@ -6,8 +6,12 @@ import (
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
+const tracerName = "github.com/quolpr/distributeds3/pkg/transaction"
+
 type Transaction struct {
 	db *pgxpool.Pool
 }
@@ -23,6 +27,9 @@ func (t *Transaction) Exec(
 	ctx context.Context,
 	f func(ctx context.Context, tx pgx.Tx) error,
 ) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "transaction")
+	defer span.End()
+
 	tx, err := t.db.Begin(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -32,6 +39,8 @@ func (t *Transaction) Exec(
 	if err != nil {
 		_ = tx.Rollback(ctx)
 
+		span.SetStatus(codes.Error, err.Error())
+
 		return fmt.Errorf("failed to execute transaction: %w", err)
 	}
 
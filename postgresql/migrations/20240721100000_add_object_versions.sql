@@ -0,0 +1,23 @@
+-- +goose Up
+alter table uploads add column bucket text not null default 'default';
+alter table uploads add column is_latest boolean not null default false;
+alter table uploads add column is_delete_marker boolean not null default false;
+
+update uploads set is_latest = true
+where id in (
+	select distinct on (bucket, name) id
+	from uploads
+	where status = 'done'
+	order by bucket, name, created_at desc
+);
+
+create unique index uploads_latest_version_idx on uploads (bucket, name) where is_latest;
+create index uploads_versions_idx on uploads (bucket, name, created_at desc);
+
+-- +goose Down
+drop index uploads_versions_idx;
+drop index uploads_latest_version_idx;
+
+alter table uploads drop column is_delete_marker;
+alter table uploads drop column is_latest;
+alter table uploads drop column bucket;
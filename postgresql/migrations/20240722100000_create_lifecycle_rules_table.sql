@@ -0,0 +1,15 @@
+-- +goose Up
+create table lifecycle_rules (
+	id uuid primary key,
+	bucket text not null,
+	prefix text not null default '',
+	expiration_days int not null default 0,
+	noncurrent_expiration_days int not null default 0,
+	abort_incomplete_upload_days int not null default 0,
+	created_at timestamptz not null default now()
+);
+
+create index lifecycle_rules_bucket_idx on lifecycle_rules (bucket);
+
+-- +goose Down
+drop table lifecycle_rules;
@@ -0,0 +1,7 @@
+-- +goose Up
+alter table uploads add column retain_until timestamptz;
+alter table uploads add column legal_hold boolean not null default false;
+
+-- +goose Down
+alter table uploads drop column legal_hold;
+alter table uploads drop column retain_until;
@@ -0,0 +1,11 @@
+-- +goose Up
+alter table uploads add column encrypted_data_key bytea;
+alter table uploads add column master_key_id text not null default '';
+
+create index uploads_master_key_id_idx on uploads (master_key_id) where encrypted_data_key is not null;
+
+-- +goose Down
+drop index uploads_master_key_id_idx;
+
+alter table uploads drop column master_key_id;
+alter table uploads drop column encrypted_data_key;
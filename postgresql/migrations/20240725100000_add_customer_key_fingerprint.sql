@@ -0,0 +1,5 @@
+-- +goose Up
+alter table uploads add column customer_key_fingerprint bytea;
+
+-- +goose Down
+alter table uploads drop column customer_key_fingerprint;
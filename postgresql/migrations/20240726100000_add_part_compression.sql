@@ -0,0 +1,9 @@
+-- +goose Up
+alter table parts add column codec text not null default 'none';
+alter table parts add column compressed_size bigint not null default 0;
+
+update parts set compressed_size = size;
+
+-- +goose Down
+alter table parts drop column compressed_size;
+alter table parts drop column codec;
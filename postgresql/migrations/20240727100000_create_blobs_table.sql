@@ -0,0 +1,23 @@
+-- +goose Up
+create table blobs (
+	id uuid primary key,
+	server_url text not null,
+	hash bytea not null,
+	codec text not null,
+	size bigint not null,
+	compressed_size bigint not null,
+	ref_count int not null,
+	created_at timestamptz not null default now()
+);
+
+create unique index blobs_hash_codec_idx on blobs (hash, codec);
+
+alter table parts add column blob_id uuid;
+update parts set blob_id = id;
+alter table parts alter column blob_id set not null;
+
+create index parts_blob_id_idx on parts (blob_id);
+
+-- +goose Down
+alter table parts drop column blob_id;
+drop table blobs;
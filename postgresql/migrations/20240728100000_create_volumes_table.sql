@@ -0,0 +1,23 @@
+-- +goose Up
+create table volumes (
+	id uuid primary key,
+	server_url text not null,
+	size bigint not null default 0,
+	live_size bigint not null default 0,
+	sealed boolean not null default false,
+	created_at timestamptz not null default now()
+);
+
+create index volumes_server_url_idx on volumes (server_url) where not sealed;
+
+alter table parts add column volume_id uuid not null default '00000000-0000-0000-0000-000000000000';
+alter table parts add column volume_offset bigint not null default 0;
+alter table parts add column volume_length bigint not null default 0;
+
+create index parts_volume_id_idx on parts (volume_id) where volume_id <> '00000000-0000-0000-0000-000000000000';
+
+-- +goose Down
+alter table parts drop column volume_length;
+alter table parts drop column volume_offset;
+alter table parts drop column volume_id;
+drop table volumes;
@@ -0,0 +1,31 @@
+-- +goose Up
+alter type upload_status rename to upload_status_old;
+create type upload_status as enum ('pending', 'writing', 'written', 'committed', 'failed', 'aborting');
+
+alter table uploads alter column status type upload_status
+	using (case status::text when 'done' then 'committed' else 'writing' end)::upload_status;
+alter table parts alter column status type upload_status
+	using (case status::text when 'done' then 'committed' else 'writing' end)::upload_status;
+
+drop type upload_status_old;
+
+alter table uploads add column owner text not null default '';
+alter table uploads add column updated_at timestamptz not null default now();
+
+create index uploads_in_flight_idx on uploads (owner, updated_at) where status <> 'committed';
+
+-- +goose Down
+drop index uploads_in_flight_idx;
+
+alter table uploads drop column updated_at;
+alter table uploads drop column owner;
+
+alter type upload_status rename to upload_status_new;
+create type upload_status as enum ('in_progress', 'done');
+
+alter table uploads alter column status type upload_status
+	using (case status::text when 'committed' then 'done' else 'in_progress' end)::upload_status;
+alter table parts alter column status type upload_status
+	using (case status::text when 'written' then 'done' when 'committed' then 'done' else 'in_progress' end)::upload_status;
+
+drop type upload_status_new;
@@ -0,0 +1,13 @@
+-- +goose Up
+create table part_cleanups (
+	blob_id uuid primary key,
+	server_url text not null,
+	size bigint not null,
+	attempts int not null default 0,
+	last_error text not null default '',
+	created_at timestamptz not null default now(),
+	updated_at timestamptz not null default now()
+);
+
+-- +goose Down
+drop table part_cleanups;
@@ -0,0 +1,13 @@
+-- +goose Up
+create table storage_servers (
+	url text primary key,
+	draining boolean not null default false,
+	created_at timestamptz not null default now(),
+	updated_at timestamptz not null default now()
+);
+
+create index parts_server_url_blob_id_idx on parts (server_url, blob_id);
+
+-- +goose Down
+drop index parts_server_url_blob_id_idx;
+drop table storage_servers;
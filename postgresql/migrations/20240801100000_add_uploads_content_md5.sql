@@ -0,0 +1,5 @@
+-- +goose Up
+alter table uploads add column content_md5 bytea;
+
+-- +goose Down
+alter table uploads drop column content_md5;
@@ -0,0 +1,14 @@
+-- +goose Up
+create table volume_cleanups (
+	volume_id uuid primary key,
+	server_url text not null,
+	size bigint not null,
+	delete_after timestamptz not null,
+	attempts int not null default 0,
+	last_error text not null default '',
+	created_at timestamptz not null default now(),
+	updated_at timestamptz not null default now()
+);
+
+-- +goose Down
+drop table volume_cleanups;
@@ -0,0 +1,34 @@
+package postgresql
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+)
+
+// NewMigrator returns a provider of the embedded migrations. Migrations are applied under
+// a Postgres advisory lock, so instances started at the same time don't run them concurrently.
+func NewMigrator(db *sql.DB) (*goose.Provider, error) {
+	migrations, err := fs.Sub(EmbedMigrations, "migrations")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open migrations: %w", err)
+	}
+
+	locker, err := lock.NewPostgresSessionLocker()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrations locker: %w", err)
+	}
+
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations, goose.WithSessionLocker(locker))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create migrations provider: %w", err)
+	}
+
+	return provider, nil
+}
@@ -0,0 +1,29 @@
+-- name: ReferenceBlob :one
+update blobs set ref_count = ref_count + 1
+where hash = @hash and codec = @codec
+returning *;
+
+-- name: AcquireBlob :one
+insert into blobs (id, server_url, hash, codec, size, compressed_size, ref_count, created_at)
+values (@id, @server_url, @hash, @codec, @size, @compressed_size, 1, @created_at)
+on conflict (hash, codec) do update set ref_count = blobs.ref_count + 1
+returning *;
+
+-- name: ReleaseBlob :one
+update blobs set ref_count = ref_count - 1 where id = @id returning *;
+
+-- name: DeleteUnreferencedBlob :exec
+delete from blobs where id = @id and ref_count = 0;
+
+-- name: GetStoredBlobIDs :many
+select blob_id from parts
+where server_url = @server_url and volume_id = '00000000-0000-0000-0000-000000000000'
+	and blob_id = ANY(@ids::uuid[])
+union
+select id from blobs where server_url = @server_url and id = ANY(@ids::uuid[]);
+
+-- name: GetBlob :one
+select * from blobs where id = @id;
+
+-- name: UpdateBlobServer :exec
+update blobs set server_url = @new_server_url where id = @id;
@@ -0,0 +1,37 @@
+-- name: InsertPartCleanup :exec
+insert into part_cleanups (blob_id, server_url, size, created_at, updated_at)
+values (@blob_id, @server_url, @size, @created_at, @created_at)
+on conflict (blob_id) do nothing;
+
+-- name: DeletePartCleanup :exec
+delete from part_cleanups where blob_id = @blob_id;
+
+-- name: UpdatePartCleanupFailure :exec
+update part_cleanups
+set attempts = attempts + 1, last_error = @last_error, updated_at = now()
+where blob_id = @blob_id;
+
+-- name: GetPartCleanups :many
+select * from part_cleanups
+where attempts < @max_attempts and blob_id > @after
+order by blob_id
+limit @max_count;
+
+-- name: InsertVolumeCleanup :exec
+insert into volume_cleanups (volume_id, server_url, size, delete_after, created_at, updated_at)
+values (@volume_id, @server_url, @size, @delete_after, @created_at, @created_at)
+on conflict (volume_id) do nothing;
+
+-- name: DeleteVolumeCleanup :exec
+delete from volume_cleanups where volume_id = @volume_id;
+
+-- name: UpdateVolumeCleanupFailure :exec
+update volume_cleanups
+set attempts = attempts + 1, last_error = @last_error, updated_at = now()
+where volume_id = @volume_id;
+
+-- name: GetDueVolumeCleanups :many
+select * from volume_cleanups
+where delete_after <= @now and attempts < @max_attempts and volume_id > @after
+order by volume_id
+limit @max_count;
@@ -0,0 +1,55 @@
+-- name: ListLifecycleRules :many
+select * from lifecycle_rules order by bucket, prefix;
+
+-- name: GetBucketLifecycleRules :many
+select * from lifecycle_rules where bucket = @bucket order by prefix;
+
+-- name: InsertLifecycleRule :exec
+insert into lifecycle_rules (
+	id, bucket, prefix, expiration_days, noncurrent_expiration_days, abort_incomplete_upload_days, created_at
+)
+values (
+	@id, @bucket, @prefix, @expiration_days, @noncurrent_expiration_days, @abort_incomplete_upload_days, @created_at
+);
+
+-- name: DeleteBucketLifecycleRules :exec
+delete from lifecycle_rules where bucket = @bucket;
+
+-- name: GetExpiredCurrentUploads :many
+select * from uploads
+where bucket = @bucket and starts_with(name, @prefix::text)
+	and is_latest and not is_delete_marker and created_at < @created_at and id > @after
+order by id
+limit @max_count;
+
+-- name: GetExpiredNoncurrentUploads :many
+select u.* from uploads u
+where u.bucket = @bucket and starts_with(u.name, @prefix::text)
+	and u.status = 'committed' and not u.is_latest
+	and exists (
+		select 1 from uploads n
+		where n.bucket = u.bucket and n.name = u.name and n.status = 'committed'
+			and n.created_at > u.created_at and n.created_at < @noncurrent_since
+	)
+	and u.id > @after
+order by u.id
+limit @max_count;
+
+-- name: GetExpiredDeleteMarkers :many
+select u.* from uploads u
+where u.bucket = @bucket and starts_with(u.name, @prefix::text)
+	and u.is_latest and u.is_delete_marker
+	and not exists (
+		select 1 from uploads o
+		where o.bucket = u.bucket and o.name = u.name and o.id <> u.id
+	)
+	and u.id > @after
+order by u.id
+limit @max_count;
+
+-- name: GetOldIncompleteUploads :many
+select * from uploads
+where bucket = @bucket and starts_with(name, @prefix::text)
+	and status <> 'committed' and created_at < @created_at and id > @after
+order by id
+limit @max_count;
@@ -0,0 +1,10 @@
+-- name: GetStorageServers :many
+select * from storage_servers order by url;
+
+-- name: InsertStorageServer :execrows
+insert into storage_servers (url) values (@url) on conflict (url) do nothing;
+
+-- name: UpsertStorageServer :exec
+insert into storage_servers (url, draining)
+values (@url, @draining)
+on conflict (url) do update set draining = excluded.draining, updated_at = now();
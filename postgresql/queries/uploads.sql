@@ -1,28 +1,133 @@
 -- name: InsertUpload :exec
-insert into uploads (id, name, size, status, created_at)
-values (@id, @name, @size, @status, @created_at);
+insert into uploads (
+	id, bucket, name, size, status, is_latest, is_delete_marker,
+	encrypted_data_key, master_key_id, customer_key_fingerprint, owner, created_at, updated_at
+)
+values (
+	@id, @bucket, @name, @size, @status, @is_latest, @is_delete_marker,
+	@encrypted_data_key, @master_key_id, @customer_key_fingerprint, @owner, @created_at, @created_at
+);
 
 -- name: InsertPart :exec
-insert into parts (id, server_url, upload_id, number, size, status)
-values (@id, @server_url, @upload_id, @number, @size, @status);
+insert into parts (id, server_url, upload_id, number, size, codec, blob_id, status)
+values (@id, @server_url, @upload_id, @number, @size, @codec, @blob_id, @status);
 
 -- name: GetUpload :one
 select * from uploads where id = @id;
 
--- name: GetUploadParts :many
-select * from parts where upload_id = @id;
+-- name: GetLatestUpload :one
+select * from uploads where bucket = @bucket and name = @name and is_latest;
+
+-- name: GetNewestUploadVersion :one
+select * from uploads
+where bucket = @bucket and name = @name and status = 'committed'
+order by created_at desc, id desc
+limit 1;
+
+-- name: ListUploadVersions :many
+select * from uploads
+where bucket = @bucket and name = @name and status = 'committed'
+order by created_at desc, id desc;
+
+-- name: LockUploadKey :exec
+select pg_advisory_xact_lock(hashtextextended(@bucket::text || '/' || @name::text, 0));
+
+-- name: UnsetLatestUpload :exec
+update uploads set is_latest = false where bucket = @bucket and name = @name and is_latest;
+
+-- name: UpdateUploadAsLatest :exec
+update uploads set is_latest = true where id = @id;
 
--- name: GetOldInProgressUploads :many
-select * from uploads where created_at < @created_at and status = 'in_progress';
+-- name: GetUploadParts :many
+select * from parts where upload_id = @id order by number;
 
--- name: GetOldInProgressParts :many
-select * from parts where created_at < @created_at and status = 'in_progress';
+-- name: GetOldInFlightUploads :many
+select * from uploads
+where created_at < @created_at and status <> 'committed' and id > @after
+order by id
+limit @max_count;
 
 -- name: DeleteUploadsByIds :exec
 delete from uploads where id = ANY(@ids::uuid[]);
 
--- name: UpdatePartAsDone :exec
-update parts set status = 'done' where id = @id;
+-- name: UpdateUploadRetention :exec
+update uploads set retain_until = @retain_until where id = @id;
+
+-- name: UpdateUploadLegalHold :exec
+update uploads set legal_hold = @legal_hold where id = @id;
+
+-- name: GetUploadsWithStaleDataKey :many
+select * from uploads
+where encrypted_data_key is not null and master_key_id <> @master_key_id
+limit @max_count;
+
+-- name: UpdateUploadDataKey :exec
+update uploads set encrypted_data_key = @encrypted_data_key, master_key_id = @master_key_id where id = @id;
+
+-- name: UpdatePartAsWritten :exec
+update parts
+set status = 'written', compressed_size = @compressed_size, blob_id = @blob_id, server_url = @server_url
+where id = @id;
+
+-- name: UpdatePartStatus :exec
+update parts set status = @status where id = @id;
+
+-- name: UpdateUploadStatus :execrows
+update uploads set status = @status, updated_at = now() where id = @id and status = @expected_status;
+
+-- name: UpdateOwnedUploadAsFailed :execrows
+update uploads set status = 'failed', updated_at = now()
+where id = @id and owner = @owner and status in ('pending', 'writing', 'written');
+
+-- name: UpdateUploadAsWritten :execrows
+update uploads set status = 'written', content_md5 = @content_md5, updated_at = now()
+where id = @id and owner = @owner and status = 'writing';
+
+-- name: UpdateUploadAsCommitted :execrows
+update uploads set status = 'committed', updated_at = now() where id = @id and owner = @owner and status = 'written';
+
+-- name: UpdateUploadPartsAsCommitted :exec
+update parts set status = 'committed' where upload_id = @upload_id;
+
+-- name: TouchUpload :execrows
+update uploads set updated_at = now() where id = @id and owner = @owner and status = 'writing';
+
+-- name: GetOwnedInFlightUploads :many
+select * from uploads where owner = @owner and status <> 'committed';
+
+-- name: ClaimStaleInFlightUploads :many
+update uploads set owner = @owner, updated_at = now()
+where id in (
+    select id from uploads
+    where owner <> @owner and status <> 'committed' and updated_at < @updated_at
+    for update skip locked
+)
+returning *;
+
+-- name: GetServerPartStats :many
+select server_url, count(*) as parts, coalesce(sum(compressed_size), 0)::bigint as bytes
+from parts
+group by server_url;
+
+-- name: ListUploads :many
+select * from uploads
+where (@bucket::text = '' or bucket = @bucket) and starts_with(name, @prefix::text) and id > @after
+order by id
+limit @max_count;
+
+-- name: GetServerBlobIDs :many
+select distinct blob_id from parts
+where server_url = @server_url and volume_id = '00000000-0000-0000-0000-000000000000'
+	and status in ('written', 'committed') and blob_id > @after
+order by blob_id
+limit @max_count;
+
+-- name: UpdatePartsServer :execrows
+update parts set server_url = @new_server_url
+where blob_id = @blob_id and server_url = @server_url and volume_id = '00000000-0000-0000-0000-000000000000';
 
--- name: UpdateUploadAsDone :exec
-update uploads set status = 'done' where id = @id; 
+-- name: ListObjects :many
+select * from uploads
+where bucket = @bucket and is_latest and not is_delete_marker and starts_with(name, @prefix::text) and name > @after::text
+order by name
+limit @max_count;
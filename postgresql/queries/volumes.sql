@@ -0,0 +1,47 @@
+-- name: GetWritableVolume :one
+select * from volumes
+where server_url = @server_url and not sealed and size <= @max_size
+order by created_at
+limit 1
+for update skip locked;
+
+-- name: InsertVolume :exec
+insert into volumes (id, server_url, size, live_size, sealed, created_at)
+values (@id, @server_url, @size, @live_size, @sealed, @created_at);
+
+-- name: LockVolume :one
+select * from volumes where id = @id for update;
+
+-- name: AddVolumeNeedle :exec
+update volumes set size = @size, live_size = live_size + @length where id = @id;
+
+-- name: ReleaseVolumeNeedle :exec
+update volumes set live_size = live_size - @length where id = @id;
+
+-- name: SealVolume :exec
+update volumes set sealed = true where id = @id;
+
+-- name: DeleteVolume :exec
+delete from volumes where id = @id;
+
+-- name: GetVolumesToCompact :many
+select * from volumes
+where size > 0 and (size - live_size)::float8 / size >= @garbage_ratio::float8;
+
+-- name: GetVolumeParts :many
+select * from parts where volume_id = @volume_id order by volume_offset;
+
+-- name: UpdatePartAsPacked :exec
+update parts
+set status = 'written', compressed_size = @compressed_size,
+	volume_id = @volume_id, volume_offset = @volume_offset, volume_length = @volume_length
+where id = @id;
+
+-- name: UpdatePartVolume :exec
+update parts set server_url = @server_url, volume_id = @volume_id, volume_offset = @volume_offset where id = @id;
+
+-- name: GetStoredVolumeIDs :many
+select id from volumes where server_url = @server_url and id = ANY(@ids::uuid[]);
+
+-- name: GetServerVolumes :many
+select * from volumes where server_url = @server_url order by id;